@@ -0,0 +1,307 @@
+/*
+Package cache stores the results of slow discoverers and filters on disk, so that later invocations of easyssh can
+reuse them until they expire.
+*/
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/abesto/easyssh/fromsexp"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// Refresh makes Get ignore cached entries, and replace them with fresh results. Set by the --refresh flag.
+var Refresh = false
+
+/*
+How long Get waits for another process computing the same entry, and after how long a lock is assumed to be abandoned.
+Locks are broken no later than the wait times out, so that an abandoned lock never makes Get give up on it.
+*/
+var (
+	lockTimeout    = time.Minute
+	lockStaleAfter = time.Minute
+	lockPollPeriod = 50 * time.Millisecond
+)
+
+// pending tracks the entries being refreshed in the background
+var pending sync.WaitGroup
+
+// held is the set of lock files taken by this process and not released yet, removed by Close
+var (
+	held      = map[string]bool{}
+	heldMutex sync.Mutex
+)
+
+// now is time.Now, replaced in tests
+var now = time.Now
+
+/*
+Dir returns the directory cache entries are stored in: $XDG_CACHE_HOME/easyssh, falling back to ~/.cache/easyssh.
+*/
+func Dir() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		cacheHome = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cacheHome, "easyssh")
+}
+
+// Key derives the name of a cache entry from everything its value depends on, like a definition and an input
+func Key(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		// Length-prefixed, so that ("ab", "c") and ("a", "bc") are different keys
+		hash.Write([]byte(strconv.Itoa(len(part)) + ":" + part))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type entry struct {
+	Created time.Time       `json:"created"`
+	Targets []target.Target `json:"targets"`
+}
+
+func path(key string) string {
+	return filepath.Join(Dir(), key+".json")
+}
+
+// load reads the entry stored under key. A missing or unreadable entry is reported as not found.
+func load(key string) (entry, bool) {
+	var e entry
+	data, err := ioutil.ReadFile(path(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			util.Logger.Debugf("Failed to read cache entry %s: %s", key, err)
+		}
+		return e, false
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		util.Logger.Debugf("Ignoring invalid cache entry %s: %s", key, err)
+		return e, false
+	}
+	return e, true
+}
+
+// store writes targets under key, atomically, so that concurrent readers never see a partial entry
+func store(key string, targets []target.Target) error {
+	data, err := json.Marshal(entry{Created: now(), Targets: targets})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(Dir(), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(Dir(), key+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path(key))
+}
+
+/*
+lock takes the lock of the entry under key, waiting for other easyssh processes holding it. The lock is a file created
+exclusively, which works the same way on every platform, holding the PID of its owner. Locks whose owner is gone, or
+older than lockStaleAfter where that can't be told, are left over from crashed processes, and are broken. Returns a
+function releasing the lock, or nil if the lock couldn't be taken in time.
+*/
+func lock(key string) func() {
+	lockPath := filepath.Join(Dir(), key+".lock")
+	if err := os.MkdirAll(Dir(), 0700); err != nil {
+		util.Logger.Debugf("Failed to create cache directory: %s", err)
+		return nil
+	}
+	deadline := now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.WriteString(strconv.Itoa(os.Getpid()))
+			file.Close()
+			heldMutex.Lock()
+			held[lockPath] = true
+			heldMutex.Unlock()
+			return func() { release(lockPath) }
+		}
+		if !os.IsExist(err) {
+			util.Logger.Debugf("Failed to lock cache entry %s: %s", key, err)
+			return nil
+		}
+		if ownerGone(lockPath) {
+			util.Logger.Debugf("Breaking lock of cache entry %s left over by a process that is gone", key)
+			os.Remove(lockPath)
+			continue
+		}
+		if info, err := os.Stat(lockPath); err == nil && now().Sub(info.ModTime()) > lockStaleAfter {
+			util.Logger.Debugf("Breaking stale lock of cache entry %s", key)
+			os.Remove(lockPath)
+			continue
+		}
+		if !now().Before(deadline) {
+			util.Logger.Debugf("Timed out waiting for the lock of cache entry %s", key)
+			return nil
+		}
+		time.Sleep(lockPollPeriod)
+	}
+}
+
+func release(lockPath string) {
+	heldMutex.Lock()
+	defer heldMutex.Unlock()
+	if held[lockPath] {
+		os.Remove(lockPath)
+		delete(held, lockPath)
+	}
+}
+
+/*
+ownerGone tells whether the process that took the lock at lockPath has exited. Where that can't be told, like on
+Windows, or if the lock can't be read, it reports false, and the lock is only broken once it's stale.
+*/
+func ownerGone(lockPath string) bool {
+	data, err := ioutil.ReadFile(lockPath)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == os.ErrProcessDone
+}
+
+// update computes the value of the entry under key, and stores it. Failing to store it is not an error.
+func update(key string, compute func() ([]target.Target, error)) ([]target.Target, error) {
+	targets, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	if err := store(key, targets); err != nil {
+		util.Logger.Debugf("Failed to write cache entry %s: %s", key, err)
+	}
+	return targets, nil
+}
+
+/*
+Get returns the targets stored under key if they are younger than ttl. Otherwise it calls compute, and stores its result
+under key, unless compute fails. Concurrent easyssh processes wait for each other instead of computing the same entry.
+
+If maxStale is longer than ttl, entries older than ttl but younger than maxStale are returned right away, and refreshed
+in the background; call Close before exiting to let that finish. Refresh disables both, and calls compute every time.
+*/
+func Get(key string, ttl, maxStale time.Duration, compute func() ([]target.Target, error)) ([]target.Target, error) {
+	if Refresh {
+		util.Logger.Debugf("Refreshing cache entry %s", key)
+		if unlock := lock(key); unlock != nil {
+			defer unlock()
+		}
+		return update(key, compute)
+	}
+
+	if e, ok := load(key); ok {
+		age := now().Sub(e.Created)
+		if age < ttl {
+			util.Logger.Debugf("Using cache entry %s from %s ago", key, age)
+			return e.Targets, nil
+		}
+		if age < maxStale {
+			util.Logger.Debugf("Using stale cache entry %s from %s ago, refreshing it in the background", key, age)
+			pending.Add(1)
+			go func() {
+				defer pending.Done()
+				if unlock := lock(key); unlock != nil {
+					defer unlock()
+				}
+				if _, err := update(key, compute); err != nil {
+					util.Logger.Debugf("Failed to refresh cache entry %s: %s", key, err)
+				}
+			}()
+			return e.Targets, nil
+		}
+	}
+
+	unlock := lock(key)
+	if unlock != nil {
+		defer unlock()
+		// Another process may have computed the entry while we were waiting for the lock
+		if e, ok := load(key); ok && now().Sub(e.Created) < ttl {
+			util.Logger.Debugf("Using cache entry %s computed by another process", key)
+			return e.Targets, nil
+		}
+	}
+	return update(key, compute)
+}
+
+// Wait waits for the entries being refreshed in the background to be stored
+func Wait() {
+	pending.Wait()
+}
+
+/*
+Close waits for the entries being refreshed in the background to be stored, then releases the locks still held, like
+the ones of lookups that were abandoned. Call it before exiting, including when exiting with an error.
+*/
+func Close() {
+	Wait()
+	heldMutex.Lock()
+	defer heldMutex.Unlock()
+	for lockPath := range held {
+		os.Remove(lockPath)
+		delete(held, lockPath)
+	}
+}
+
+/*
+Args parses the arguments of the cached discoverer and filter: a TTL, an optional maximum age of stale entries
+returned while they are refreshed, and the definition of the wrapped component, which is made by ExpandedDefinition.
+*/
+func Args(args []interface{}) (ttl, maxStale time.Duration, err error) {
+	if ttl, err = util.DurationArg(args[0]); err != nil {
+		return
+	}
+	if len(args) == 3 {
+		if maxStale, err = util.DurationArg(args[1]); err != nil {
+			return
+		}
+		if maxStale <= ttl {
+			err = util.ParseErrorf("The maximum age of stale entries (%s) must be longer than the TTL (%s)", maxStale, ttl)
+			return
+		}
+	}
+	return
+}
+
+/*
+ExpandedDefinition calls make, which makes the wrapped component, and returns its definition with all aliases and macros
+expanded, to be used in keys. That way redefining a macro doesn't reuse the entries made with its old definition.
+*/
+func ExpandedDefinition(make func() error) (string, error) {
+	var err error
+	roots := fromsexp.Trace(func() { err = make() })
+	if err != nil {
+		return "", err
+	}
+	return fromsexp.Format(roots[0].ExpandedDefinition()), nil
+}
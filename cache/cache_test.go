@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// withCacheDir runs f with an empty cache directory, and a clock that f can move forward
+func withCacheDir(t *testing.T, f func(dir string, advance func(time.Duration))) {
+	dir, err := ioutil.TempDir("", "easyssh-cache-test")
+	util.ExpectNoError(t, err)
+	defer os.RemoveAll(dir)
+	originalCacheHome, originalNow := os.Getenv("XDG_CACHE_HOME"), now
+	defer func() {
+		os.Setenv("XDG_CACHE_HOME", originalCacheHome)
+		now = originalNow
+	}()
+	os.Setenv("XDG_CACHE_HOME", dir)
+	current := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	f(filepath.Join(dir, "easyssh"), func(d time.Duration) { current = current.Add(d) })
+}
+
+// counter returns a compute function that returns a different target on each call
+func counter() (func() ([]target.Target, error), *int) {
+	calls := 0
+	return func() ([]target.Target, error) {
+		calls++
+		return target.MustFromStrings(string(rune('a' + calls - 1))), nil
+	}, &calls
+}
+
+func TestDir(t *testing.T) {
+	originalCacheHome, originalHome := os.Getenv("XDG_CACHE_HOME"), os.Getenv("HOME")
+	defer func() {
+		os.Setenv("XDG_CACHE_HOME", originalCacheHome)
+		os.Setenv("HOME", originalHome)
+	}()
+	os.Setenv("XDG_CACHE_HOME", "/xdg")
+	if Dir() != "/xdg/easyssh" {
+		t.Error(Dir())
+	}
+	os.Setenv("XDG_CACHE_HOME", "")
+	os.Setenv("HOME", "/home/test")
+	if Dir() != "/home/test/.cache/easyssh" {
+		t.Error(Dir())
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("a", "bc") == Key("ab", "c") {
+		t.Error("keys of different parts must differ")
+	}
+	if Key("a", "b") != Key("a", "b") {
+		t.Error("keys of the same parts must be the same")
+	}
+}
+
+func TestGetUsesFreshEntries(t *testing.T) {
+	withCacheDir(t, func(dir string, advance func(time.Duration)) {
+		compute, calls := counter()
+		for i := 0; i < 2; i++ {
+			targets, err := Get("key", time.Minute, 0, compute)
+			util.ExpectNoError(t, err)
+			target.AssertTargetListEquals(t, target.MustFromStrings("a"), targets)
+			advance(30 * time.Second)
+		}
+		if *calls != 1 {
+			t.Error(*calls)
+		}
+
+		advance(time.Minute)
+		targets, err := Get("key", time.Minute, 0, compute)
+		util.ExpectNoError(t, err)
+		target.AssertTargetListEquals(t, target.MustFromStrings("b"), targets)
+
+		if _, err := os.Stat(filepath.Join(dir, "key.json")); err != nil {
+			t.Error(err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "key.lock")); !os.IsNotExist(err) {
+			t.Error("lock not released", err)
+		}
+	})
+}
+
+func TestGetDoesNotStoreErrors(t *testing.T) {
+	withCacheDir(t, func(dir string, advance func(time.Duration)) {
+		_, err := Get("key", time.Minute, 0, func() ([]target.Target, error) { return nil, errors.New("knife failed") })
+		util.ExpectError(t, "knife failed", err)
+		compute, _ := counter()
+		targets, err := Get("key", time.Minute, 0, compute)
+		util.ExpectNoError(t, err)
+		target.AssertTargetListEquals(t, target.MustFromStrings("a"), targets)
+	})
+}
+
+func TestGetStaleWhileRevalidate(t *testing.T) {
+	withCacheDir(t, func(dir string, advance func(time.Duration)) {
+		compute, calls := counter()
+		Get("key", time.Minute, time.Hour, compute)
+
+		advance(10 * time.Minute)
+		targets, err := Get("key", time.Minute, time.Hour, compute)
+		util.ExpectNoError(t, err)
+		target.AssertTargetListEquals(t, target.MustFromStrings("a"), targets)
+		Wait()
+		if *calls != 2 {
+			t.Error(*calls)
+		}
+		targets, err = Get("key", time.Minute, time.Hour, compute)
+		util.ExpectNoError(t, err)
+		target.AssertTargetListEquals(t, target.MustFromStrings("b"), targets)
+
+		advance(2 * time.Hour)
+		targets, err = Get("key", time.Minute, time.Hour, compute)
+		util.ExpectNoError(t, err)
+		target.AssertTargetListEquals(t, target.MustFromStrings("c"), targets)
+	})
+}
+
+func TestGetRefresh(t *testing.T) {
+	withCacheDir(t, func(dir string, advance func(time.Duration)) {
+		defer func() { Refresh = false }()
+		compute, _ := counter()
+		Get("key", time.Hour, 0, compute)
+		Refresh = true
+		targets, err := Get("key", time.Hour, 0, compute)
+		util.ExpectNoError(t, err)
+		target.AssertTargetListEquals(t, target.MustFromStrings("b"), targets)
+		Refresh = false
+		targets, err = Get("key", time.Hour, 0, compute)
+		util.ExpectNoError(t, err)
+		target.AssertTargetListEquals(t, target.MustFromStrings("b"), targets)
+	})
+}
+
+func TestGetBreaksStaleLocks(t *testing.T) {
+	withCacheDir(t, func(dir string, advance func(time.Duration)) {
+		util.ExpectNoError(t, os.MkdirAll(dir, 0700))
+		lockPath := filepath.Join(dir, "key.lock")
+		util.ExpectNoError(t, ioutil.WriteFile(lockPath, []byte("1"), 0600))
+		advance(time.Since(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) + lockStaleAfter + time.Minute)
+		compute, _ := counter()
+		targets, err := Get("key", time.Hour, 0, compute)
+		util.ExpectNoError(t, err)
+		target.AssertTargetListEquals(t, target.MustFromStrings("a"), targets)
+	})
+}
+
+func TestGetBreaksLocksOfExitedProcesses(t *testing.T) {
+	withCacheDir(t, func(dir string, advance func(time.Duration)) {
+		cmd := exec.Command("true")
+		util.ExpectNoError(t, cmd.Run())
+		util.ExpectNoError(t, os.MkdirAll(dir, 0700))
+		lockPath := filepath.Join(dir, "key.lock")
+		util.ExpectNoError(t, ioutil.WriteFile(lockPath, []byte(strconv.Itoa(cmd.Process.Pid)), 0600))
+		// The lock is fresh, but its owner is gone: Get doesn't wait for it
+		originalTimeout := lockTimeout
+		defer func() { lockTimeout = originalTimeout }()
+		lockTimeout = 0
+		compute, _ := counter()
+		Get("key", time.Hour, 0, compute)
+		if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+			t.Error("lock not broken", err)
+		}
+	})
+}
+
+func TestCloseReleasesLocks(t *testing.T) {
+	withCacheDir(t, func(dir string, advance func(time.Duration)) {
+		if unlock := lock("abandoned"); unlock == nil {
+			t.Fatal("lock not taken")
+		}
+		unlock := lock("released")
+		unlock()
+		Close()
+		for _, key := range []string{"abandoned", "released"} {
+			if _, err := os.Stat(filepath.Join(dir, key+".lock")); !os.IsNotExist(err) {
+				t.Error("lock not released", key, err)
+			}
+		}
+	})
+}
+
+func TestArgs(t *testing.T) {
+	ttl, maxStale, err := Args([]interface{}{[]byte("10m"), []byte("1h"), []interface{}{[]byte("knife")}})
+	util.ExpectNoError(t, err)
+	if ttl != 10*time.Minute || maxStale != time.Hour {
+		t.Error(ttl, maxStale)
+	}
+	_, _, err = Args([]interface{}{[]byte("soon"), []interface{}{[]byte("knife")}})
+	util.ExpectError(t, "Expected a positive duration like 10m or 1h30m, got soon", err)
+	_, _, err = Args([]interface{}{[]byte("1h"), []byte("10m"), []interface{}{[]byte("knife")}})
+	util.ExpectError(t, "The maximum age of stale entries (10m0s) must be longer than the TTL (1h0m0s)", err)
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/abesto/easyssh/fromsexp"
+	"github.com/abesto/easyssh/util"
+	"github.com/abesto/sexp"
+)
+
+// EnvVar overrides the location of the config file
+const EnvVar = "EASYSSH_CONFIG"
+
+// DefaultProfileName is the profile used when no profile is explicitly selected
+const DefaultProfileName = "default"
+
+/*
+Profile is a named set of defaults for the discoverer, filter, executor and user flags.
+Empty fields mean "not set by this profile".
+*/
+type Profile struct {
+	Name       string
+	Discoverer string
+	Filter     string
+	Executor   string
+	User       string
+}
+
+/*
+Config is the parsed content of an easyssh config file.
+*/
+type Config struct {
+	Profiles map[string]Profile
+	Macros   []fromsexp.Macro
+}
+
+/*
+Path returns the location of the config file: $EASYSSH_CONFIG if set, otherwise
+$XDG_CONFIG_HOME/easyssh/config, falling back to ~/.config/easyssh/config.
+*/
+func Path() string {
+	if path := os.Getenv(EnvVar); path != "" {
+		return path
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(configHome, "easyssh", "config")
+}
+
+/*
+Load reads and parses the config file at Path(). A missing config file results in an empty Config, unless
+its location was set explicitly via $EASYSSH_CONFIG.
+*/
+func Load() (Config, error) {
+	path := Path()
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && os.Getenv(EnvVar) == "" {
+		util.Logger.Debugf("Config file %s doesn't exist, using empty config", path)
+		return Config{Profiles: map[string]Profile{}}, nil
+	}
+	if err != nil {
+		return Config{}, util.ConfigErrorf("Failed to read config file: %s", err)
+	}
+	util.Logger.Debugf("Loading config file %s", path)
+	c, err := Parse(content)
+	if err != nil {
+		return c, util.ConfigErrorf("%s: %s", path, err)
+	}
+	return c, nil
+}
+
+/*
+Parse parses the content of a config file. The file is a sequence of S-Expressions; lines starting with ; or #
+are comments. The supported top-level forms are
+
+	(profile name (discoverer ...) (filter ...) (executor ...) (user ...))
+	(defmacro (name $param...) body)
+
+where each of discoverer, filter, executor and user is optional in a profile.
+*/
+func Parse(content []byte) (Config, error) {
+	c := Config{Profiles: map[string]Profile{}}
+	forms, err := parseForms(content)
+	if err != nil {
+		return c, err
+	}
+	for _, form := range forms {
+		exp, ok := form.([]interface{})
+		if !ok || len(exp) == 0 {
+			return c, util.ConfigErrorf("Expected a list as a top-level form in the config file, got: %s", form)
+		}
+		head, err := atom(exp[0])
+		if err != nil {
+			return c, err
+		}
+		switch head {
+		case "profile":
+			p, err := parseProfile(exp[1:])
+			if err != nil {
+				return c, err
+			}
+			if _, exists := c.Profiles[p.Name]; exists {
+				return c, util.ConfigErrorf("Profile %s is defined more than once", p.Name)
+			}
+			c.Profiles[p.Name] = p
+		case "defmacro":
+			m, err := fromsexp.ParseMacro(exp[1:])
+			if err != nil {
+				return c, util.ConfigErrorf("%s", err)
+			}
+			for _, existing := range c.Macros {
+				if existing.Name == m.Name {
+					return c, util.ConfigErrorf("Macro %s is defined more than once", m.Name)
+				}
+			}
+			c.Macros = append(c.Macros, m)
+		default:
+			err := util.ConfigErrorf("Unknown top-level form in the config file: %s", head)
+			if suggestion := util.Suggest(head, []string{"profile", "defmacro"}); suggestion != "" {
+				err.Msg += fmt.Sprintf("; did you mean %s?", suggestion)
+			}
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+/*
+Profile returns the profile called name. If name is empty, the profile called "default" is returned if it exists,
+and an empty profile otherwise.
+*/
+func (c Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		return c.Profiles[DefaultProfileName], nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		err := util.ConfigErrorf("Profile %s is not defined. Defined profiles: %s", name, strings.Join(c.ProfileNames(), ", "))
+		if suggestion := util.Suggest(name, c.ProfileNames()); suggestion != "" {
+			err.Msg += fmt.Sprintf("; did you mean %s?", suggestion)
+		}
+		return p, err
+	}
+	return p, nil
+}
+
+// DefineMacros makes the macros defined in the config file available to the discoverers, filters and executors
+func (c Config) DefineMacros() {
+	fromsexp.Exclusive(func() {
+		for _, m := range c.Macros {
+			fromsexp.DefineMacro(m)
+		}
+	})
+}
+
+// ProfileNames returns the sorted list of profile names
+func (c Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func normalize(content []byte) []byte {
+	lines := strings.Split(string(content), "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	// The sexp lexer only treats spaces as token separators
+	return []byte(strings.NewReplacer("\t", " ", "\r", " ").Replace(strings.Join(kept, " ")))
+}
+
+func parseForms(content []byte) ([]interface{}, error) {
+	// sexp.Unmarshal returns only the first expression, so wrap the whole file into a single list
+	wrapped := append(append([]byte("("), normalize(content)...), ')')
+	forms, err := sexp.Unmarshal(wrapped)
+	if err != nil {
+		return nil, util.ConfigErrorf("Failed to parse config file: %s", err)
+	}
+	return forms, nil
+}
+
+func atom(data interface{}) (string, error) {
+	bytes, ok := data.([]byte)
+	if !ok {
+		return "", util.ConfigErrorf("Expected a string in the config file, got a list: %s", data)
+	}
+	return string(bytes), nil
+}
+
+func marshal(data interface{}) (string, error) {
+	bytes, err := sexp.Marshal(data, false)
+	if err != nil {
+		return "", util.ConfigErrorf("Failed to serialize %s: %s", data, err)
+	}
+	return string(bytes), nil
+}
+
+func parseProfile(args []interface{}) (Profile, error) {
+	if len(args) == 0 {
+		return Profile{}, util.ConfigErrorf("profile requires a name")
+	}
+	name, err := atom(args[0])
+	if err != nil {
+		return Profile{}, err
+	}
+	p := Profile{Name: name}
+	for _, arg := range args[1:] {
+		setting, ok := arg.([]interface{})
+		if !ok || len(setting) != 2 {
+			return p, util.ConfigErrorf("Settings of profile %s must be of the form (key value), got: %s", p.Name, arg)
+		}
+		key, err := atom(setting[0])
+		if err != nil {
+			return p, err
+		}
+		var value string
+		if key == "user" {
+			value, err = atom(setting[1])
+		} else {
+			value, err = marshal(setting[1])
+		}
+		if err != nil {
+			return p, err
+		}
+		switch key {
+		case "discoverer":
+			p.Discoverer = value
+		case "filter":
+			p.Filter = value
+		case "executor":
+			p.Executor = value
+		case "user":
+			p.User = value
+		default:
+			return p, util.ConfigErrorf("Unknown setting %s in profile %s", key, p.Name)
+		}
+	}
+	return p, nil
+}
+
+func (p Profile) String() string {
+	return fmt.Sprintf("<profile %s>", p.Name)
+}
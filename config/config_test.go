@@ -0,0 +1,163 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/abesto/easyssh/util"
+)
+
+func TestParse(t *testing.T) {
+	input := `
+; Shared config
+(profile default
+  (discoverer (first-matching (knife) (comma-separated)))
+  (user root))
+# Production
+(profile prod
+  (executor (if-command (ssh-exec-parallel) (ssh-login)))
+  (filter (list (ec2-instance-id us-east-1))))
+`
+	c, err := Parse([]byte(input))
+	util.ExpectNoError(t, err)
+	expected := map[string]Profile{
+		"default": {Name: "default", Discoverer: "(first-matching (knife) (comma-separated))", User: "root"},
+		"prod":    {Name: "prod", Executor: "(if-command (ssh-exec-parallel) (ssh-login))", Filter: "(list (ec2-instance-id us-east-1))"},
+	}
+	if !reflect.DeepEqual(expected, c.Profiles) {
+		t.Errorf("Expected %v, got %v", expected, c.Profiles)
+	}
+	util.AssertStringListEquals(t, []string{"default", "prod"}, c.ProfileNames())
+}
+
+func TestParseQuotesAtomsThatNeedIt(t *testing.T) {
+	c, _ := Parse([]byte("(profile p (discoverer (separated-by ,)))"))
+	if c.Profiles["p"].Discoverer != "(separated-by \",\")" {
+		t.Error(c.Profiles["p"].Discoverer)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		err   string
+	}{
+		{"foo", "Expected a list as a top-level form in the config file, got: foo"},
+		{"(macro x)", "Unknown top-level form in the config file: macro"},
+		{"(profle x)", "Unknown top-level form in the config file: profle; did you mean profile?"},
+		{"(profile)", "profile requires a name"},
+		{"(profile (x))", "Expected a string in the config file, got a list: [x]"},
+		{"(profile p (user))", "Settings of profile p must be of the form (key value), got: [user]"},
+		{"(profile p (color blue))", "Unknown setting color in profile p"},
+		{"(profile p) (profile p)", "Profile p is defined more than once"},
+		{"(defmacro (x))", "defmacro requires exactly 2 arguments (signature and body), got 1: [[x]]"},
+	}
+	for _, c := range cases {
+		_, err := Parse([]byte(c.input))
+		util.ExpectError(t, c.err, err)
+		if util.ExitCode(err) != util.ExitCodeConfig {
+			t.Errorf("Exit code for %s is %d", err, util.ExitCode(err))
+		}
+	}
+	if _, err := Parse([]byte("(profile p")); util.ExitCode(err) != util.ExitCodeConfig {
+		t.Errorf("Unexpected error for a syntax error: %v", err)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	c, _ := Parse([]byte("(profile default (user root)) (profile prod (user deploy))"))
+	if p, _ := c.Profile(""); p.User != "root" {
+		t.Error("default", p)
+	}
+	if p, _ := c.Profile("prod"); p.User != "deploy" {
+		t.Error("prod", p)
+	}
+	_, err := c.Profile("staging")
+	util.ExpectError(t, "Profile staging is not defined. Defined profiles: default, prod", err)
+	_, err = c.Profile("prd")
+	util.ExpectError(t, "Profile prd is not defined. Defined profiles: default, prod; did you mean prod?", err)
+	if p, _ := (Config{}).Profile(""); !reflect.DeepEqual(p, Profile{}) {
+		t.Error("missing default", p)
+	}
+}
+
+func withEnv(key, value string, f func()) {
+	original, wasSet := os.LookupEnv(key)
+	os.Setenv(key, value)
+	defer func() {
+		if wasSet {
+			os.Setenv(key, original)
+		} else {
+			os.Unsetenv(key)
+		}
+	}()
+	f()
+}
+
+func TestPath(t *testing.T) {
+	withEnv(EnvVar, "", func() {
+		withEnv("XDG_CONFIG_HOME", "/xdg", func() {
+			if Path() != "/xdg/easyssh/config" {
+				t.Error(Path())
+			}
+		})
+		withEnv("XDG_CONFIG_HOME", "", func() {
+			withEnv("HOME", "/home/test", func() {
+				if Path() != "/home/test/.config/easyssh/config" {
+					t.Error(Path())
+				}
+			})
+		})
+	})
+	withEnv(EnvVar, "/etc/easyssh.conf", func() {
+		if Path() != "/etc/easyssh.conf" {
+			t.Error(Path())
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "easyssh-config-test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config")
+	ioutil.WriteFile(path, []byte("(profile default (user root))"), 0600)
+
+	withEnv(EnvVar, path, func() {
+		c, err := Load()
+		util.ExpectNoError(t, err)
+		if p, _ := c.Profile(""); p.User != "root" {
+			t.Error(c)
+		}
+	})
+	withEnv(EnvVar, filepath.Join(dir, "missing"), func() {
+		if _, err := Load(); util.ExitCode(err) != util.ExitCodeConfig {
+			t.Errorf("Unexpected error for a missing config file: %v", err)
+		}
+	})
+	withEnv(EnvVar, "", func() {
+		withEnv("XDG_CONFIG_HOME", dir, func() {
+			if c, err := Load(); err != nil || len(c.Profiles) != 0 {
+				t.Error(c, err)
+			}
+		})
+	})
+
+	ioutil.WriteFile(path, []byte("(profile default (colour blue))"), 0600)
+	withEnv(EnvVar, path, func() {
+		_, err := Load()
+		util.ExpectError(t, path+": Unknown setting colour in profile default", err)
+	})
+}
+
+func TestParseMacros(t *testing.T) {
+	c, err := Parse([]byte("(defmacro (prod-exec $n) (assert-command (external-parallel $n))) (defmacro (other) (id))"))
+	util.ExpectNoError(t, err)
+	if len(c.Macros) != 2 || c.Macros[0].Name != "prod-exec" || c.Macros[1].Name != "other" {
+		t.Error(c.Macros)
+	}
+	_, err = Parse([]byte("(defmacro (x) (id)) (defmacro (x) (first))"))
+	util.ExpectError(t, "Macro x is defined more than once", err)
+}
@@ -0,0 +1,730 @@
+package discoverers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+	"gopkg.in/yaml.v2"
+)
+
+type ansibleGroup struct {
+	hosts    []string
+	children []string
+	vars     map[string]string
+}
+
+/*
+ansibleInventory holds the hosts and groups of one or more inventory files.
+The all group contains every host; ungrouped contains the hosts that are in no other group.
+*/
+type ansibleInventory struct {
+	hosts      []string
+	hostVars   map[string]map[string]string
+	groups     map[string]*ansibleGroup
+	groupOrder []string
+}
+
+func newAnsibleInventory() *ansibleInventory {
+	inv := &ansibleInventory{hostVars: map[string]map[string]string{}, groups: map[string]*ansibleGroup{}}
+	inv.group("all")
+	inv.group("ungrouped")
+	return inv
+}
+
+func (inv *ansibleInventory) group(name string) *ansibleGroup {
+	g, ok := inv.groups[name]
+	if !ok {
+		g = &ansibleGroup{vars: map[string]string{}}
+		inv.groups[name] = g
+		inv.groupOrder = append(inv.groupOrder, name)
+	}
+	return g
+}
+
+func (inv *ansibleInventory) addHost(group, host string, vars map[string]string) {
+	if _, ok := inv.hostVars[host]; !ok {
+		inv.hosts = append(inv.hosts, host)
+		inv.hostVars[host] = map[string]string{}
+	}
+	for key, value := range vars {
+		inv.hostVars[host][key] = value
+	}
+	g := inv.group(group)
+	for _, existing := range g.hosts {
+		if existing == host {
+			return
+		}
+	}
+	g.hosts = append(g.hosts, host)
+}
+
+func (inv *ansibleInventory) addChild(parent, child string) {
+	inv.group(child)
+	g := inv.group(parent)
+	for _, existing := range g.children {
+		if existing == child {
+			return
+		}
+	}
+	g.children = append(g.children, child)
+}
+
+// groupHosts returns the hosts of a group and its descendants, in definition order
+func (inv *ansibleInventory) groupHosts(name string) []string {
+	if name == "all" {
+		return inv.hosts
+	}
+	if name == "ungrouped" {
+		var hosts []string
+		for _, host := range inv.hosts {
+			if groups := inv.hostGroups(host); len(groups) == 0 || (len(groups) == 1 && groups[0] == "ungrouped") {
+				hosts = append(hosts, host)
+			}
+		}
+		return hosts
+	}
+	member := map[string]bool{}
+	inv.collectGroupHosts(name, member, map[string]bool{})
+	var hosts []string
+	for _, host := range inv.hosts {
+		if member[host] {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+func (inv *ansibleInventory) collectGroupHosts(name string, member, visited map[string]bool) {
+	if visited[name] {
+		return
+	}
+	visited[name] = true
+	g := inv.groups[name]
+	for _, host := range g.hosts {
+		member[host] = true
+	}
+	for _, child := range g.children {
+		inv.collectGroupHosts(child, member, visited)
+	}
+}
+
+// hostGroups returns the names of the groups host is a member of, directly or through children, except all
+func (inv *ansibleInventory) hostGroups(host string) []string {
+	var groups []string
+	for _, name := range inv.groupOrder {
+		if name == "all" || name == "ungrouped" {
+			continue
+		}
+		member := map[string]bool{}
+		inv.collectGroupHosts(name, member, map[string]bool{})
+		if member[host] {
+			groups = append(groups, name)
+		}
+	}
+	return groups
+}
+
+// groupDepth is the length of the longest chain of parents above a group, used to order variable precedence
+func (inv *ansibleInventory) groupDepth(name string, visiting map[string]bool) int {
+	if visiting[name] {
+		return 0
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+	depth := 0
+	for _, parent := range inv.groupOrder {
+		for _, child := range inv.groups[parent].children {
+			if child == name && parent != "all" {
+				if d := inv.groupDepth(parent, visiting) + 1; d > depth {
+					depth = d
+				}
+			}
+		}
+	}
+	return depth
+}
+
+/*
+resolvedVars merges the variables of host the way Ansible does: the all group first, then the groups of the host from
+parents to children, then the variables of the host itself.
+*/
+func (inv *ansibleInventory) resolvedVars(host string) map[string]string {
+	vars := map[string]string{}
+	for key, value := range inv.groups["all"].vars {
+		vars[key] = value
+	}
+	groups := inv.hostGroups(host)
+	depths := map[string]int{}
+	for _, group := range groups {
+		depths[group] = inv.groupDepth(group, map[string]bool{})
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return depths[groups[i]] < depths[groups[j]] })
+	for _, group := range groups {
+		for key, value := range inv.groups[group].vars {
+			vars[key] = value
+		}
+	}
+	for key, value := range inv.hostVars[host] {
+		vars[key] = value
+	}
+	return vars
+}
+
+func firstAnsibleVar(vars map[string]string, names ...string) string {
+	for _, name := range names {
+		if value, ok := vars[name]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func (inv *ansibleInventory) target(host string) (target.Target, error) {
+	var t target.Target
+	vars := inv.resolvedVars(host)
+	address := firstAnsibleVar(vars, "ansible_host", "ansible_ssh_host")
+	if address == "" {
+		address = host
+	} else if address != host {
+		t.Hostname = host
+	}
+	if net.ParseIP(address) != nil {
+		t.IP = address
+		if t.Hostname != "" {
+			t.Host, t.Hostname = t.Hostname, ""
+		}
+	} else {
+		t.Host = address
+	}
+	t.User = firstAnsibleVar(vars, "ansible_user", "ansible_ssh_user")
+	if port := firstAnsibleVar(vars, "ansible_port", "ansible_ssh_port"); port != "" {
+		number, err := strconv.Atoi(port)
+		if err != nil || number < 1 || number > 65535 {
+			return t, util.ConfigErrorf("Invalid ansible_port %s for host %s", port, host)
+		}
+		t.Port = number
+	}
+	t.SetLabel("ansible.host", host)
+	t.SetLabel("ansible.groups", strings.Join(inv.hostGroups(host), ","))
+	return t, nil
+}
+
+var ansibleRangeRegex = regexp.MustCompile(`\[([0-9a-zA-Z]+):([0-9a-zA-Z]+)(?::([0-9]+))?\]`)
+
+// expandAnsibleHostRange expands host patterns like web[01:20].example.com or db-[a:c], including several ranges
+func expandAnsibleHostRange(pattern string) ([]string, error) {
+	loc := ansibleRangeRegex.FindStringSubmatchIndex(pattern)
+	if loc == nil {
+		return []string{pattern}, nil
+	}
+	prefix, suffix := pattern[:loc[0]], pattern[loc[1]:]
+	begin, end := pattern[loc[2]:loc[3]], pattern[loc[4]:loc[5]]
+	step := 1
+	if loc[6] != -1 {
+		step, _ = strconv.Atoi(pattern[loc[6]:loc[7]])
+		if step < 1 {
+			return nil, fmt.Errorf("invalid step in host range %s", pattern)
+		}
+	}
+	var values []string
+	if beginNumber, err := strconv.Atoi(begin); err == nil {
+		endNumber, err := strconv.Atoi(end)
+		if err != nil || endNumber < beginNumber {
+			return nil, fmt.Errorf("invalid host range %s", pattern)
+		}
+		format := "%d"
+		if len(begin) > 1 && begin[0] == '0' {
+			format = fmt.Sprintf("%%0%dd", len(begin))
+		}
+		for i := beginNumber; i <= endNumber; i += step {
+			values = append(values, fmt.Sprintf(format, i))
+		}
+	} else {
+		if len(begin) != 1 || len(end) != 1 || end[0] < begin[0] {
+			return nil, fmt.Errorf("invalid host range %s", pattern)
+		}
+		for c := begin[0]; c <= end[0]; c += byte(step) {
+			values = append(values, string(c))
+			if int(c)+step > 255 {
+				break
+			}
+		}
+	}
+	rest, err := expandAnsibleHostRange(suffix)
+	if err != nil {
+		return nil, err
+	}
+	var hosts []string
+	for _, value := range values {
+		for _, tail := range rest {
+			hosts = append(hosts, prefix+value+tail)
+		}
+	}
+	return hosts, nil
+}
+
+// splitAnsibleHostPort splits the host:port syntax of INI inventories, leaving IPv6 addresses alone
+func splitAnsibleHostPort(host string) (string, string) {
+	if strings.Count(host, ":") != 1 {
+		return host, ""
+	}
+	parts := strings.SplitN(host, ":", 2)
+	if _, err := strconv.Atoi(parts[1]); err != nil {
+		return host, ""
+	}
+	return parts[0], parts[1]
+}
+
+func (inv *ansibleInventory) addHostPattern(group, pattern string, vars map[string]string) error {
+	hostPattern, port := splitAnsibleHostPort(pattern)
+	if port != "" {
+		vars["ansible_port"] = port
+	}
+	hosts, err := expandAnsibleHostRange(hostPattern)
+	if err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		inv.addHost(group, host, vars)
+	}
+	return nil
+}
+
+// splitAnsibleINIFields splits a line at whitespace, keeping quoted strings together and dropping the quotes
+func splitAnsibleINIFields(line string) []string {
+	var fields []string
+	var current strings.Builder
+	var quote rune
+	inField := false
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inField = true
+		case c == ' ' || c == '\t':
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+func (inv *ansibleInventory) parseINI(path string, data []byte) error {
+	section, kind := "ungrouped", "hosts"
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		fail := func(msg string, args ...interface{}) error {
+			return util.ConfigErrorf("%s line %d: %s", path, lineNumber, fmt.Sprintf(msg, args...))
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return fail("invalid section header %s", line)
+			}
+			section, kind = line[1:len(line)-1], "hosts"
+			if i := strings.Index(section, ":"); i != -1 {
+				section, kind = section[:i], section[i+1:]
+			}
+			if kind != "hosts" && kind != "vars" && kind != "children" {
+				return fail("unknown section type %s", kind)
+			}
+			inv.group(section)
+			continue
+		}
+		switch kind {
+		case "vars":
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 {
+				return fail("expected key=value, got %s", line)
+			}
+			inv.group(section).vars[strings.TrimSpace(parts[0])] = strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+		case "children":
+			inv.addChild(section, line)
+		default:
+			fields := splitAnsibleINIFields(line)
+			vars := map[string]string{}
+			for _, field := range fields[1:] {
+				parts := strings.SplitN(field, "=", 2)
+				if len(parts) != 2 {
+					return fail("expected key=value after the host name, got %s", field)
+				}
+				vars[parts[0]] = parts[1]
+			}
+			if err := inv.addHostPattern(section, fields[0], vars); err != nil {
+				return fail("%s", err)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+yamlMapping returns value as a mapping, keeping the order of its keys. Empty values, like hosts without variables, are
+empty mappings.
+*/
+func yamlMapping(path, what string, value interface{}) (yaml.MapSlice, error) {
+	switch mapping := value.(type) {
+	case nil:
+		return nil, nil
+	case yaml.MapSlice:
+		return mapping, nil
+	}
+	return nil, util.ConfigErrorf("%s: expected a mapping as %s, got %v", path, what, value)
+}
+
+// yamlString formats a scalar the way Ansible would pass it on, like 2222 for a port
+func yamlString(value interface{}) string {
+	switch scalar := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return scalar
+	}
+	return fmt.Sprint(value)
+}
+
+func (inv *ansibleInventory) parseYAML(path string, data []byte) error {
+	var root yaml.MapSlice
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return util.ConfigErrorf("%s: %s", path, err)
+	}
+	for _, item := range root {
+		if err := inv.parseYAMLGroup(path, yamlString(item.Key), item.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (inv *ansibleInventory) parseYAMLGroup(path, name string, value interface{}) error {
+	g := inv.group(name)
+	group, err := yamlMapping(path, "group "+name, value)
+	if err != nil {
+		return err
+	}
+	for _, section := range group {
+		key := yamlString(section.Key)
+		switch key {
+		case "hosts":
+			hosts, err := yamlMapping(path, "hosts of group "+name, section.Value)
+			if err != nil {
+				return err
+			}
+			for _, host := range hosts {
+				pattern := yamlString(host.Key)
+				hostVars, err := yamlMapping(path, "variables of host "+pattern, host.Value)
+				if err != nil {
+					return err
+				}
+				vars := map[string]string{}
+				for _, v := range hostVars {
+					vars[yamlString(v.Key)] = yamlString(v.Value)
+				}
+				if err := inv.addHostPattern(name, pattern, vars); err != nil {
+					return util.ConfigErrorf("%s: %s", path, err)
+				}
+			}
+		case "vars":
+			vars, err := yamlMapping(path, "variables of group "+name, section.Value)
+			if err != nil {
+				return err
+			}
+			for _, v := range vars {
+				g.vars[yamlString(v.Key)] = yamlString(v.Value)
+			}
+		case "children":
+			children, err := yamlMapping(path, "children of group "+name, section.Value)
+			if err != nil {
+				return err
+			}
+			for _, child := range children {
+				childName := yamlString(child.Key)
+				inv.addChild(name, childName)
+				if err := inv.parseYAMLGroup(path, childName, child.Value); err != nil {
+					return err
+				}
+			}
+		default:
+			return util.ConfigErrorf("%s: unexpected key %s in group %s, expected hosts, vars or children", path, key, name)
+		}
+	}
+	return nil
+}
+
+var yamlInventoryFirstLine = regexp.MustCompile(`^(---|[^\s=\[#;]+:(\s|$))`)
+
+// isYAMLInventory tells YAML inventories from INI ones by their extension, or by their first significant line
+func isYAMLInventory(path string, data []byte) bool {
+	if ext := filepath.Ext(path); ext == ".yml" || ext == ".yaml" {
+		return true
+	} else if ext == ".ini" {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, ";") {
+			return yamlInventoryFirstLine.MatchString(line)
+		}
+	}
+	return false
+}
+
+func loadAnsibleInventory(paths []string) (*ansibleInventory, error) {
+	inv := newAnsibleInventory()
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(expandHome(path))
+		if err != nil {
+			return nil, util.ConfigErrorf("Failed to read Ansible inventory: %s", err)
+		}
+		if isYAMLInventory(path, data) {
+			err = inv.parseYAML(path, data)
+		} else {
+			err = inv.parseINI(path, data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return inv, nil
+}
+
+var ansibleSubscriptRegex = regexp.MustCompile(`^(.*)\[(-?[0-9]+)(?::(-?[0-9]*))?\]$`)
+
+/*
+matchPattern returns the hosts matched by a single pattern term: a group or host name, a glob with *, or a regular
+expression prefixed with ~. The term may end in a subscript like [0], [-1] or [0:2], which is inclusive as in Ansible.
+*/
+func (inv *ansibleInventory) matchPattern(term string) ([]string, error) {
+	var subscript []string
+	if !strings.HasPrefix(term, "~") {
+		subscript = ansibleSubscriptRegex.FindStringSubmatch(term)
+	}
+	if subscript != nil {
+		term = subscript[1]
+	}
+	var hosts []string
+	if _, isGroup := inv.groups[term]; isGroup {
+		hosts = inv.groupHosts(term)
+	} else if _, isHost := inv.hostVars[term]; isHost {
+		hosts = []string{term}
+	} else if term == "*" {
+		hosts = inv.hosts
+	} else {
+		var matches func(string) bool
+		if strings.HasPrefix(term, "~") {
+			re, err := regexp.Compile(term[1:])
+			if err != nil {
+				return nil, util.UsageErrorf("Invalid regular expression in Ansible pattern %s: %s", term, err)
+			}
+			matches = re.MatchString
+		} else if strings.ContainsAny(term, "*?") {
+			matches = func(s string) bool { return sshConfigPatternMatches(term, s) }
+		} else {
+			util.Logger.Debugf("Ansible pattern %s matches no group or host", term)
+			return nil, nil
+		}
+		member := map[string]bool{}
+		for _, name := range inv.groupOrder {
+			if matches(name) {
+				for _, host := range inv.groupHosts(name) {
+					member[host] = true
+				}
+			}
+		}
+		for _, host := range inv.hosts {
+			if member[host] || matches(host) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	if subscript != nil {
+		return sliceAnsibleHosts(hosts, subscript[2], subscript[3], strings.Contains(subscript[0][len(term):], ":"))
+	}
+	return hosts, nil
+}
+
+func sliceAnsibleHosts(hosts []string, start, end string, isRange bool) ([]string, error) {
+	index := func(s string, fallback int) int {
+		if s == "" {
+			return fallback
+		}
+		i, _ := strconv.Atoi(s)
+		if i < 0 {
+			i += len(hosts)
+		}
+		return i
+	}
+	from := index(start, 0)
+	to := from
+	if isRange {
+		to = index(end, len(hosts)-1)
+	}
+	if from < 0 || from >= len(hosts) {
+		return nil, nil
+	}
+	if to >= len(hosts) {
+		to = len(hosts) - 1
+	}
+	if to < from {
+		return nil, nil
+	}
+	return hosts[from : to+1], nil
+}
+
+/*
+matchPatterns evaluates an Ansible host pattern like webservers:&prod:!web03. Terms are separated by commas, or colons
+if there are no commas. As in Ansible, the union of the plain terms, in the order of the terms, is intersected with the
+terms prefixed with &, and the terms prefixed with ! are removed, regardless of their order.
+*/
+func (inv *ansibleInventory) matchPatterns(pattern string) ([]string, error) {
+	var included []string
+	var intersections, exclusions [][]string
+	for _, term := range splitAnsiblePattern(pattern) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		var list *[][]string
+		if strings.HasPrefix(term, "&") {
+			list, term = &intersections, term[1:]
+		} else if strings.HasPrefix(term, "!") {
+			list, term = &exclusions, term[1:]
+		}
+		hosts, err := inv.matchPattern(term)
+		if err != nil {
+			return nil, err
+		}
+		if list == nil {
+			included = append(included, hosts...)
+		} else {
+			*list = append(*list, hosts)
+		}
+	}
+	if len(included) == 0 && len(intersections) > 0 {
+		included = inv.hosts
+	}
+	var hosts []string
+	seen := map[string]bool{}
+	for _, host := range included {
+		if seen[host] || !inAllAnsibleHostLists(host, intersections) || inAnyAnsibleHostList(host, exclusions) {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// splitAnsiblePattern splits a pattern at commas, or if there are none, at colons outside subscripts
+func splitAnsiblePattern(pattern string) []string {
+	if strings.Contains(pattern, ",") {
+		return strings.Split(pattern, ",")
+	}
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range pattern {
+		switch {
+		case c == '[':
+			depth++
+		case c == ']' && depth > 0:
+			depth--
+		case c == ':' && depth == 0:
+			terms = append(terms, pattern[start:i])
+			start = i + 1
+		}
+	}
+	return append(terms, pattern[start:])
+}
+
+func inAllAnsibleHostLists(host string, lists [][]string) bool {
+	for _, list := range lists {
+		if !inAnyAnsibleHostList(host, [][]string{list}) {
+			return false
+		}
+	}
+	return true
+}
+
+func inAnyAnsibleHostList(host string, lists [][]string) bool {
+	for _, list := range lists {
+		for _, candidate := range list {
+			if candidate == host {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type ansibleInventoryDiscoverer struct {
+	args  []interface{}
+	paths []string
+}
+
+func (d *ansibleInventoryDiscoverer) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	inv, err := loadAnsibleInventory(d.paths)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := inv.matchPatterns(input)
+	if err != nil {
+		return nil, err
+	}
+	var targets []target.Target
+	for _, host := range hosts {
+		t, err := inv.target(host)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func (d *ansibleInventoryDiscoverer) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(d, 1, args); err != nil {
+		return err
+	}
+	paths, err := util.ByteToStringArray(args)
+	if err != nil {
+		return err
+	}
+	d.args = args
+	d.paths = paths
+	return nil
+}
+
+func (d *ansibleInventoryDiscoverer) String() string {
+	return fmt.Sprintf("<%s %s>", nameAnsibleInventory, d.paths)
+}
@@ -0,0 +1,196 @@
+package discoverers
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+const ansibleINIInventory = `
+# ungrouped hosts come first
+jumpbox.example.com ansible_user=admin
+
+[web]
+web[01:03].example.com
+web-canary.example.com:2222 ansible_host=10.0.0.9 "ansible_user=deploy"
+
+[db]
+db-[a:b].example.com ansible_port=5022
+
+[prod:children]
+web
+db
+
+[prod:vars]
+ansible_user=prod-user
+; comment
+[web:vars]
+ansible_user = web-user
+`
+
+const ansibleYAMLInventory = `---
+all:
+  vars:
+    ansible_user: everyone
+  hosts:
+    mail.example.com:
+  children:
+    eu:
+      hosts:
+        web02.example.com:
+        db-a.example.com:
+          ansible_host: "10.1.0.1"   # a comment
+        cache[1:5:2].eu:
+          tags:
+            - redis
+            - primary
+      vars: {}
+    staging:
+      children:
+        staging_web:
+          hosts:
+            web-staging.example.com: {ansible_host: 10.2.0.1, ansible_port: 2200}
+`
+
+func ansibleHosts(targets []target.Target) []string {
+	hosts := []string{}
+	for _, t := range targets {
+		hosts = append(hosts, t.Labels["ansible.host"])
+	}
+	return hosts
+}
+
+func withAnsibleInventory(t *testing.T, f func(d *ansibleInventoryDiscoverer)) {
+	files := map[string]string{"hosts": ansibleINIInventory, "inventory.yml": ansibleYAMLInventory}
+	withTempFiles(t, files, func(dir string) {
+		f(mustMake(t, "(ansible-inventory "+filepath.Join(dir, "hosts")+" "+filepath.Join(dir, "inventory.yml")+")").(*ansibleInventoryDiscoverer))
+	})
+}
+
+func TestAnsibleInventoryMakeWithoutArgument(t *testing.T) {
+	_, err := Make("(ansible-inventory)")
+	util.ExpectError(t, "<ansible-inventory []> requires at least 1 argument(s), got 0: [] in (ansible-inventory) at position 0", err)
+}
+
+func TestAnsibleInventoryPatterns(t *testing.T) {
+	withAnsibleInventory(t, func(d *ansibleInventoryDiscoverer) {
+		cases := []struct {
+			pattern  string
+			expected []string
+		}{
+			{"web", []string{"web01.example.com", "web02.example.com", "web03.example.com", "web-canary.example.com"}},
+			{"prod:!web", []string{"db-a.example.com", "db-b.example.com"}},
+			{"prod:&eu", []string{"web02.example.com", "db-a.example.com"}},
+			{"&eu:prod", []string{"web02.example.com", "db-a.example.com"}},
+			{"web:&prod:!web03.example.com", []string{"web01.example.com", "web02.example.com", "web-canary.example.com"}},
+			{"db,jumpbox.example.com", []string{"db-a.example.com", "db-b.example.com", "jumpbox.example.com"}},
+			{"ungrouped", []string{"jumpbox.example.com", "mail.example.com"}},
+			{"staging", []string{"web-staging.example.com"}},
+			{"*.eu", []string{"cache1.eu", "cache3.eu", "cache5.eu"}},
+			{"staging_*", []string{"web-staging.example.com"}},
+			{"~web0[13]", []string{"web01.example.com", "web03.example.com"}},
+			{"web[0]", []string{"web01.example.com"}},
+			{"web[-1]", []string{"web-canary.example.com"}},
+			{"web[1:2]", []string{"web02.example.com", "web03.example.com"}},
+			{"web[2:]", []string{"web03.example.com", "web-canary.example.com"}},
+			{"nothing", []string{}},
+		}
+		for _, c := range cases {
+			actual := ansibleHosts(mustDiscover(t, d, c.pattern))
+			if !reflect.DeepEqual(c.expected, actual) {
+				t.Errorf("Pattern %s matched %s, expected %s", c.pattern, actual, c.expected)
+			}
+		}
+		if all := mustDiscover(t, d, "all"); len(all) != 12 {
+			t.Errorf("all matched %d hosts: %s", len(all), ansibleHosts(all))
+		}
+	})
+}
+
+func TestAnsibleInventoryTargets(t *testing.T) {
+	withAnsibleInventory(t, func(d *ansibleInventoryDiscoverer) {
+		target.AssertTargetListEquals(t, []target.Target{
+			{Host: "jumpbox.example.com", User: "admin", Labels: map[string]string{"ansible.host": "jumpbox.example.com"}},
+			{Host: "web01.example.com", User: "web-user", Labels: map[string]string{"ansible.host": "web01.example.com", "ansible.groups": "web,prod"}},
+			{Host: "web-canary.example.com", IP: "10.0.0.9", User: "deploy", Port: 2222, Labels: map[string]string{"ansible.host": "web-canary.example.com", "ansible.groups": "web,prod"}},
+			{Host: "db-b.example.com", User: "prod-user", Port: 5022, Labels: map[string]string{"ansible.host": "db-b.example.com", "ansible.groups": "db,prod"}},
+			{Host: "db-a.example.com", IP: "10.1.0.1", User: "prod-user", Port: 5022, Labels: map[string]string{"ansible.host": "db-a.example.com", "ansible.groups": "db,prod,eu"}},
+			{Host: "mail.example.com", User: "everyone", Labels: map[string]string{"ansible.host": "mail.example.com"}},
+			{Host: "web-staging.example.com", IP: "10.2.0.1", User: "everyone", Port: 2200, Labels: map[string]string{"ansible.host": "web-staging.example.com", "ansible.groups": "staging,staging_web"}},
+		}, mustDiscover(t, d, "jumpbox.example.com,web01.example.com,web-canary.example.com,db-b.example.com,db-a.example.com,mail.example.com,web-staging.example.com"))
+	})
+}
+
+func TestExpandAnsibleHostRange(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"plain.example.com", []string{"plain.example.com"}},
+		{"web[8:11]", []string{"web8", "web9", "web10", "web11"}},
+		{"web[08:11]", []string{"web08", "web09", "web10", "web11"}},
+		{"web[1:6:2]", []string{"web1", "web3", "web5"}},
+		{"[a:b]-[1:2]", []string{"a-1", "a-2", "b-1", "b-2"}},
+	}
+	for _, c := range cases {
+		actual, err := expandAnsibleHostRange(c.pattern)
+		util.ExpectNoError(t, err)
+		util.AssertStringListEquals(t, c.expected, actual)
+	}
+	_, err := expandAnsibleHostRange("web[3:1]")
+	util.ExpectError(t, "invalid host range web[3:1]", err)
+	_, err = expandAnsibleHostRange("web[a:zz]")
+	util.ExpectError(t, "invalid host range web[a:zz]", err)
+}
+
+func TestAnsibleInventoryErrors(t *testing.T) {
+	files := map[string]string{
+		"bad.ini":      "[web:hostvars]\n",
+		"bad-var.ini":  "[web]\nweb1 ansible_user\n",
+		"bad-port.ini": "web1 ansible_port=ssh\n",
+		"bad.yml":      "all:\n  hosts:\n      a:\n    b:\n",
+		"list.yml":     "all:\n  hosts:\n    - a\n",
+		"typo.yml":     "all:\n  host:\n    a:\n",
+	}
+	withTempFiles(t, files, func(dir string) {
+		cases := []struct {
+			file string
+			err  string
+		}{
+			{"bad.ini", filepath.Join(dir, "bad.ini") + " line 1: unknown section type hostvars"},
+			{"bad-var.ini", filepath.Join(dir, "bad-var.ini") + " line 2: expected key=value after the host name, got ansible_user"},
+			{"bad-port.ini", "Invalid ansible_port ssh for host web1"},
+			{"bad.yml", filepath.Join(dir, "bad.yml") + ": yaml: line 3: did not find expected key"},
+			{"list.yml", filepath.Join(dir, "list.yml") + ": expected a mapping as hosts of group all, got [a]"},
+			{"typo.yml", filepath.Join(dir, "typo.yml") + ": unexpected key host in group all, expected hosts, vars or children"},
+			{"missing", "Failed to read Ansible inventory: open " + filepath.Join(dir, "missing") + ": no such file or directory"},
+		}
+		for _, c := range cases {
+			_, err := mustMake(t, "(ansible-inventory "+filepath.Join(dir, c.file)+")").Discover("all")
+			util.ExpectError(t, c.err, err)
+		}
+	})
+}
+
+func TestIsYAMLInventory(t *testing.T) {
+	cases := []struct {
+		path     string
+		data     string
+		expected bool
+	}{
+		{"hosts.yaml", "", true},
+		{"hosts.ini", "all:\n", false},
+		{"hosts", "# comment\nall:\n  hosts:\n", true},
+		{"hosts", "---\n", true},
+		{"hosts", "web1.example.com:2222\n", false},
+		{"hosts", "[web]\n", false},
+	}
+	for _, c := range cases {
+		if actual := isYAMLInventory(c.path, []byte(c.data)); actual != c.expected {
+			t.Errorf("isYAMLInventory(%s, %q) = %t", c.path, c.data, actual)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package discoverers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// azureVM is an entry of az vm list
+type azureVM struct {
+	Name          string
+	ResourceGroup string
+	Location      string
+	Tags          map[string]string
+	Zones         []string
+}
+
+// azureVMAddresses is an entry of az vm list-ip-addresses
+type azureVMAddresses struct {
+	VirtualMachine struct {
+		Name          string
+		ResourceGroup string
+		Network       struct {
+			PrivateIPAddresses []string `json:"privateIpAddresses"`
+			PublicIPAddresses  []struct {
+				IPAddress string `json:"ipAddress"`
+			} `json:"publicIpAddresses"`
+		}
+	}
+}
+
+// azureVMKey identifies a VM across the outputs of az; resource group names are case-insensitive
+func azureVMKey(resourceGroup, name string) string {
+	return strings.ToLower(resourceGroup) + "/" + name
+}
+
+/*
+azureTarget makes a target of vm, preferring its public IP address over its private one, the same way knife prefers
+public addresses
+*/
+func azureTarget(vm azureVM, addresses azureVMAddresses, subscription string) target.Target {
+	var publicIP, privateIP string
+	for _, address := range addresses.VirtualMachine.Network.PublicIPAddresses {
+		if publicIP == "" {
+			publicIP = address.IPAddress
+		}
+	}
+	if len(addresses.VirtualMachine.Network.PrivateIPAddresses) > 0 {
+		privateIP = addresses.VirtualMachine.Network.PrivateIPAddresses[0]
+	}
+	t := target.Target{IP: publicIP, Hostname: vm.Name}
+	if t.IP == "" {
+		t.IP = privateIP
+	}
+	t.SetLabel("region", vm.Location)
+	if len(vm.Zones) > 0 {
+		t.SetLabel("zone", vm.Location+"-"+vm.Zones[0])
+	}
+	t.SetLabel("azure.subscription", subscription)
+	t.SetLabel("azure.resource_group", vm.ResourceGroup)
+	t.SetLabel("azure.name", vm.Name)
+	t.SetLabel("azure.public_ip", publicIP)
+	t.SetLabel("azure.private_ip", privateIP)
+	for key, value := range vm.Tags {
+		t.SetLabel("azure.tag."+key, value)
+	}
+	return t
+}
+
+type azure struct {
+	args          []interface{}
+	subscriptions []string
+	commandRunner util.CommandRunner
+}
+
+// az runs az with args in subscription, and parses its JSON output into result
+func (d *azure) az(subscription string, result interface{}, args ...string) error {
+	argv := append(append([]string{}, args...), "-o", "json")
+	if subscription != "" {
+		argv = append(argv, "--subscription", subscription)
+	}
+	outputs := d.commandRunner.Outputs("az", argv)
+	if outputs.Error != nil {
+		return &util.CommandError{Argv: append([]string{"az"}, argv...), Err: outputs.Error, Output: outputs.Combined}
+	}
+	if err := json.Unmarshal(outputs.Stdout, result); err != nil {
+		return &util.InvalidOutputError{Source: "az " + strings.Join(args, " "), Err: err, Output: outputs.Stdout}
+	}
+	return nil
+}
+
+func (d *azure) Discover(input string) ([]target.Target, error) {
+	q := parseComputeQuery(input, "tag:", "rg:")
+	if q == nil {
+		util.Logger.Debugf("Host lookup string is not a list of names, tag:KEY=VALUE and rg:RESOURCE_GROUP terms, it won't match anything in Azure")
+		return []target.Target{}, nil
+	}
+	subscriptions := d.subscriptions
+	if len(subscriptions) == 0 {
+		// The default subscription of az
+		subscriptions = []string{""}
+	}
+
+	util.Logger.Infof("Looking up Azure VMs matching %s", input)
+	listArgs, addressArgs := []string{"vm", "list"}, []string{"vm", "list-ip-addresses"}
+	if name := q.exactName(); name != "" {
+		// A JMESPath raw string literal, in which only quotes need escaping
+		listArgs = append(listArgs, "--query", fmt.Sprintf("[?name=='%s']", strings.Replace(name, "'", `\'`, -1)))
+		addressArgs = append(addressArgs, "--name", name)
+	}
+	targets := []target.Target{}
+	for _, subscription := range subscriptions {
+		var vms, matching []azureVM
+		if err := d.az(subscription, &vms, listArgs...); err != nil {
+			return nil, err
+		}
+		for _, vm := range vms {
+			if q.matches(vm.Name, vm.Tags, vm.ResourceGroup, true) {
+				matching = append(matching, vm)
+			}
+		}
+		if len(matching) == 0 {
+			continue
+		}
+		var addressList []azureVMAddresses
+		if err := d.az(subscription, &addressList, addressArgs...); err != nil {
+			return nil, err
+		}
+		addresses := map[string]azureVMAddresses{}
+		for _, entry := range addressList {
+			addresses[azureVMKey(entry.VirtualMachine.ResourceGroup, entry.VirtualMachine.Name)] = entry
+		}
+		for _, vm := range matching {
+			t := azureTarget(vm, addresses[azureVMKey(vm.ResourceGroup, vm.Name)], subscription)
+			if t.IsEmpty() {
+				util.Logger.Infof("Azure VM %s doesn't have an IP address, ignoring", vm.Name)
+				continue
+			}
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+func (d *azure) SetArgs(args []interface{}) error {
+	subscriptions, err := util.ByteToStringArray(args)
+	if err != nil {
+		return err
+	}
+	d.args = args
+	d.subscriptions = subscriptions
+	return util.RequireOnPath(d, "az")
+}
+
+func (d *azure) SetCommandRunner(r util.CommandRunner) {
+	d.commandRunner = r
+}
+
+func (d *azure) CommandRunner() util.CommandRunner {
+	return d.commandRunner
+}
+
+func (d *azure) RequiredBinaries() []string {
+	return []string{"az"}
+}
+
+func (d *azure) String() string {
+	if len(d.subscriptions) == 0 {
+		return fmt.Sprintf("<%s>", nameAzure)
+	}
+	return fmt.Sprintf("<%s %s>", nameAzure, d.subscriptions)
+}
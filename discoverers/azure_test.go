@@ -0,0 +1,85 @@
+package discoverers
+
+import (
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+const azureVMsJSON = `[
+  {"name": "web-1", "resourceGroup": "SHOP-PROD", "location": "westeurope", "zones": ["2"], "tags": {"role": "web"}},
+  {"name": "web-2", "resourceGroup": "shop-staging", "location": "westeurope", "tags": {"role": "web"}},
+  {"name": "db-1", "resourceGroup": "shop-prod", "location": "northeurope", "tags": null}
+]`
+
+const azureIPAddressesJSON = `[
+  {"virtualMachine": {"name": "web-1", "resourceGroup": "shop-prod", "network": {
+    "privateIpAddresses": ["10.0.0.4"], "publicIpAddresses": [{"ipAddress": "20.1.1.1", "name": "web-1-ip"}]}}},
+  {"virtualMachine": {"name": "web-2", "resourceGroup": "shop-staging", "network": {
+    "privateIpAddresses": ["10.1.0.4"], "publicIpAddresses": []}}}
+]`
+
+func givenAMockedAzure(subscriptions ...string) (*azure, *util.MockCommandRunner) {
+	r := &util.MockCommandRunner{}
+	return &azure{subscriptions: subscriptions, commandRunner: r}, r
+}
+
+func TestAzureStringViaMake(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("MakeFromString %s -> %s", "(azure)", "[azure]")
+		l.ExpectDebugf("Make %s -> %s", "[azure]", "<azure>")
+		mustMake(t, "(azure)")
+		l.ExpectDebugf("MakeFromString %s -> %s", "(azure prod)", "[azure prod]")
+		l.ExpectDebugf("Make %s -> %s", "[azure prod]", "<azure [prod]>")
+		mustMake(t, "(azure prod)")
+	})
+}
+
+func TestAzureDiscover(t *testing.T) {
+	d, r := givenAMockedAzure("prod")
+	r.On("Outputs", "az", []string{"vm", "list", "-o", "json", "--subscription", "prod"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(azureVMsJSON)})
+	r.On("Outputs", "az", []string{"vm", "list-ip-addresses", "-o", "json", "--subscription", "prod"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(azureIPAddressesJSON)})
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectInfof("Looking up Azure VMs matching %s", "tag:role=web")
+		target.AssertTargetListEquals(t, []target.Target{
+			{IP: "20.1.1.1", Hostname: "web-1", Labels: map[string]string{
+				"region": "westeurope", "zone": "westeurope-2", "azure.subscription": "prod", "azure.resource_group": "SHOP-PROD",
+				"azure.name": "web-1", "azure.public_ip": "20.1.1.1", "azure.private_ip": "10.0.0.4", "azure.tag.role": "web"}},
+			{IP: "10.1.0.4", Hostname: "web-2", Labels: map[string]string{
+				"region": "westeurope", "azure.subscription": "prod", "azure.resource_group": "shop-staging",
+				"azure.name": "web-2", "azure.private_ip": "10.1.0.4", "azure.tag.role": "web"}},
+		}, mustDiscover(t, d, "tag:role=web"))
+		l.ExpectInfof("Looking up Azure VMs matching %s", "rg:shop-prod")
+		l.ExpectInfof("Azure VM %s doesn't have an IP address, ignoring", "db-1")
+		discovered := mustDiscover(t, d, "rg:shop-prod")
+		util.AssertStringListEquals(t, []string{"web-1"}, target.FriendlyNames(discovered))
+	})
+}
+
+func TestAzureErrors(t *testing.T) {
+	d, r := givenAMockedAzure()
+	r.On("Outputs", "az", []string{"vm", "list", "-o", "json"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(azureVMsJSON)})
+	r.On("Outputs", "az", []string{"vm", "list-ip-addresses", "-o", "json"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte("ERROR: Please run 'az login'")})
+	_, err := d.Discover("web-*")
+	util.ExpectError(t, "Failed to parse output of az vm list-ip-addresses: invalid character 'E' looking for beginning of value", err)
+}
+
+func TestAzureDiscoverExactName(t *testing.T) {
+	d, r := givenAMockedAzure("prod")
+	r.On("Outputs", "az", []string{"vm", "list", "--query", "[?name=='web-1']", "-o", "json", "--subscription", "prod"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(azureVMsJSON)}).Times(1)
+	r.On("Outputs", "az", []string{"vm", "list-ip-addresses", "--name", "web-1", "-o", "json", "--subscription", "prod"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(azureIPAddressesJSON)}).Times(1)
+	util.AssertStringListEquals(t, []string{"web-1"}, target.FriendlyNames(mustDiscover(t, d, "web-1")))
+
+	// Addresses are not listed if no VM matches
+	r.On("Outputs", "az", []string{"vm", "list", "--query", "[?name=='nothing']", "-o", "json", "--subscription", "prod"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte("[]")}).Times(1)
+	target.AssertTargetListEquals(t, []target.Target{}, mustDiscover(t, d, "nothing"))
+	r.AssertExpectations(t)
+}
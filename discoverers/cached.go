@@ -0,0 +1,68 @@
+package discoverers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/abesto/easyssh/cache"
+	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+type cached struct {
+	args       []interface{}
+	ttl        time.Duration
+	maxStale   time.Duration
+	definition string
+	child      interfaces.Discoverer
+}
+
+func (d *cached) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(d, 2, d.args); err != nil {
+		return nil, err
+	}
+	key := cache.Key("discoverer", d.definition, input)
+	return cache.Get(key, d.ttl, d.maxStale, func() ([]target.Target, error) {
+		return d.child.Discover(input)
+	})
+}
+
+func (d *cached) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(d, 2, args); err != nil {
+		return err
+	}
+	if err := util.RequireArgumentsAtMost(d, 3, args); err != nil {
+		return err
+	}
+	ttl, maxStale, err := cache.Args(args)
+	if err != nil {
+		return err
+	}
+	var child interfaces.Discoverer
+	definition, err := cache.ExpandedDefinition(func() (err error) {
+		child, err = makeFromSExp(args[len(args)-1])
+		return
+	})
+	if err != nil {
+		return err
+	}
+	d.args = args
+	d.ttl, d.maxStale, d.definition = ttl, maxStale, definition
+	d.child = child
+	return nil
+}
+
+func (d *cached) Children() []interface{} {
+	return []interface{}{d.child}
+}
+
+func (d *cached) String() string {
+	if d.child == nil {
+		return fmt.Sprintf("<%s>", nameCached)
+	}
+	if d.maxStale > 0 {
+		return fmt.Sprintf("<%s %s %s %s>", nameCached, d.ttl, d.maxStale, d.child)
+	}
+	return fmt.Sprintf("<%s %s %s>", nameCached, d.ttl, d.child)
+}
@@ -0,0 +1,68 @@
+package discoverers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/abesto/easyssh/fromsexp"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+func TestCachedStringViaMake(t *testing.T) {
+	if s := mustMake(t, "(cached 10m (const a))").String(); s != "<cached 10m0s <fixed [a]>>" {
+		t.Error(s)
+	}
+	if s := mustMake(t, "(cached 10m 1h (const a))").String(); s != "<cached 10m0s 1h0m0s <fixed [a]>>" {
+		t.Error(s)
+	}
+}
+
+func TestCachedMakeErrors(t *testing.T) {
+	cases := []struct {
+		input         string
+		expectedError string
+	}{
+		{"(cached 10m)", "<cached> requires at least 2 argument(s), got 1: [10m] in (cached 10m) at position 0"},
+		{"(cached 10m 1h 2h (const a))", "<cached> takes at most 3 argument(s), got 4: [10m 1h 2h [const a]] in (cached 10m 1h 2h (const a)) at position 0"},
+		{"(cached forever (const a))", "Expected a positive duration like 10m or 1h30m, got forever in (cached forever (const a)) at position 0"},
+		{"(cached 10m a)", "Expected a definition, got a string: a in (cached 10m a) at position 0"},
+	}
+	for _, c := range cases {
+		_, err := Make(c.input)
+		util.ExpectError(t, c.expectedError, err)
+	}
+}
+
+func TestCachedOperation(t *testing.T) {
+	withTempFiles(t, map[string]string{}, func(dir string) {
+		originalCacheHome := os.Getenv("XDG_CACHE_HOME")
+		defer os.Setenv("XDG_CACHE_HOME", originalCacheHome)
+		os.Setenv("XDG_CACHE_HOME", dir)
+
+		d := mustMake(t, "(cached 10m (const a))").(*cached)
+		target.AssertTargetListEquals(t, target.MustFromStrings("a"), mustDiscover(t, d, "x"))
+		d.child.(*fixed).retval = target.MustFromStrings("b")
+		// Same definition and input: cached
+		target.AssertTargetListEquals(t, target.MustFromStrings("a"), mustDiscover(t, d, "x"))
+		// Different input: not cached
+		target.AssertTargetListEquals(t, target.MustFromStrings("b"), mustDiscover(t, d, "y"))
+		// Different definition: not cached
+		target.AssertTargetListEquals(t, target.MustFromStrings("c"), mustDiscover(t, mustMake(t, "(cached 10m (const c))"), "x"))
+	})
+}
+
+func TestCachedKeysOnExpandedDefinition(t *testing.T) {
+	withTempFiles(t, map[string]string{}, func(dir string) {
+		originalCacheHome := os.Getenv("XDG_CACHE_HOME")
+		defer os.Setenv("XDG_CACHE_HOME", originalCacheHome)
+		os.Setenv("XDG_CACHE_HOME", dir)
+		defer fromsexp.UndefineMacro("hosts")
+
+		fromsexp.DefineMacro(fromsexp.Macro{Name: "hosts", Body: []interface{}{[]byte("const"), []byte("a")}})
+		target.AssertTargetListEquals(t, target.MustFromStrings("a"), mustDiscover(t, mustMake(t, "(cached 10m (hosts))"), "x"))
+		// Redefining the macro changes the definition of the cached discoverer
+		fromsexp.DefineMacro(fromsexp.Macro{Name: "hosts", Body: []interface{}{[]byte("const"), []byte("b")}})
+		target.AssertTargetListEquals(t, target.MustFromStrings("b"), mustDiscover(t, mustMake(t, "(cached 10m (hosts))"), "x"))
+	})
+}
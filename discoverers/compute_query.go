@@ -0,0 +1,87 @@
+package discoverers
+
+import (
+	"strings"
+)
+
+/*
+computeQuery is a target definition for discoverers listing cloud VMs: a comma-separated list of terms that all have to
+match. Terms are names, KEY=VALUE label or tag filters after a prefix like label:, and placement globs after a prefix
+like zone:. Values of all of them can contain * and ? wildcards. Names without wildcards are exact, so that a plain
+hostname can be looked up with a server-side filter, instead of listing all VMs; name: makes a name a glob explicitly.
+*/
+type computeQuery struct {
+	exact      []string
+	names      []string
+	labels     map[string]string
+	placements []string
+}
+
+/*
+parseComputeQuery parses input with the given label and placement prefixes, like "label:" and "zone:".
+Returns nil if input contains terms with other prefixes, because those are meant for another discoverer.
+*/
+func parseComputeQuery(input, labelPrefix, placementPrefix string) *computeQuery {
+	q := &computeQuery{labels: map[string]string{}}
+	for _, term := range strings.Split(input, ",") {
+		switch {
+		case strings.HasPrefix(term, labelPrefix):
+			parts := strings.SplitN(strings.TrimPrefix(term, labelPrefix), "=", 2)
+			if parts[0] == "" {
+				return nil
+			}
+			if len(parts) == 1 {
+				parts = append(parts, "*")
+			}
+			q.labels[parts[0]] = parts[1]
+		case strings.HasPrefix(term, placementPrefix):
+			q.placements = append(q.placements, strings.TrimPrefix(term, placementPrefix))
+		case strings.HasPrefix(term, "name:"):
+			q.names = append(q.names, strings.TrimPrefix(term, "name:"))
+		case term == "" || strings.ContainsAny(term, ":="):
+			return nil
+		case strings.ContainsAny(term, "*?"):
+			q.names = append(q.names, term)
+		default:
+			q.exact = append(q.exact, term)
+		}
+	}
+	return q
+}
+
+// exactName returns the name all VMs matching q have, or "" if q has no exact names
+func (q *computeQuery) exactName() string {
+	if len(q.exact) == 0 {
+		return ""
+	}
+	return q.exact[0]
+}
+
+// matches tells whether a VM with the given name, labels and placement matches all terms of q
+func (q *computeQuery) matches(name string, labels map[string]string, placement string, caseInsensitivePlacement bool) bool {
+	for _, exact := range q.exact {
+		if name != exact {
+			return false
+		}
+	}
+	for _, glob := range q.names {
+		if !sshConfigPatternMatches(glob, name) {
+			return false
+		}
+	}
+	for key, glob := range q.labels {
+		value, ok := labels[key]
+		if !ok || !sshConfigPatternMatches(glob, value) {
+			return false
+		}
+	}
+	for _, glob := range q.placements {
+		if caseInsensitivePlacement {
+			glob, placement = strings.ToLower(glob), strings.ToLower(placement)
+		}
+		if !sshConfigPatternMatches(glob, placement) {
+			return false
+		}
+	}
+	return true
+}
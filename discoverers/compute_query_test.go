@@ -0,0 +1,55 @@
+package discoverers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseComputeQuery(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected *computeQuery
+	}{
+		{"web-*", &computeQuery{names: []string{"web-*"}, labels: map[string]string{}}},
+		{"web-1", &computeQuery{exact: []string{"web-1"}, labels: map[string]string{}}},
+		{"name:web-1", &computeQuery{names: []string{"web-1"}, labels: map[string]string{}}},
+		{"web-*,label:env=prod,label:role,zone:us-*", &computeQuery{
+			names: []string{"web-*"}, labels: map[string]string{"env": "prod", "role": "*"}, placements: []string{"us-*"}}},
+		{"label:=prod", nil},
+		{"tag:env=prod", nil},
+		{"env=prod", nil},
+		{"web,", nil},
+	}
+	for _, c := range cases {
+		if actual := parseComputeQuery(c.input, "label:", "zone:"); !reflect.DeepEqual(c.expected, actual) {
+			t.Errorf("parseComputeQuery(%s) = %+v, expected %+v", c.input, actual, c.expected)
+		}
+	}
+}
+
+func TestComputeQueryMatches(t *testing.T) {
+	q := parseComputeQuery("web-?,label:env=prod*,zone:EU-*", "label:", "zone:")
+	cases := []struct {
+		name            string
+		labels          map[string]string
+		placement       string
+		caseInsensitive bool
+		expected        bool
+	}{
+		{"web-1", map[string]string{"env": "production"}, "eu-west", true, true},
+		{"web-1", map[string]string{"env": "production"}, "eu-west", false, false},
+		{"web-10", map[string]string{"env": "prod"}, "EU-west", false, false},
+		{"web-1", map[string]string{"env": "staging"}, "EU-west", false, false},
+		{"web-1", map[string]string{}, "EU-west", false, false},
+	}
+	for _, c := range cases {
+		if actual := q.matches(c.name, c.labels, c.placement, c.caseInsensitive); actual != c.expected {
+			t.Errorf("matches(%s, %s, %s, %t) = %t", c.name, c.labels, c.placement, c.caseInsensitive, actual)
+		}
+	}
+
+	q = parseComputeQuery("web-1", "label:", "zone:")
+	if q.exactName() != "web-1" || !q.matches("web-1", nil, "", false) || q.matches("web-10", nil, "", false) {
+		t.Errorf("Unexpected matches of exact name %+v", q)
+	}
+}
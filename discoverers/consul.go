@@ -0,0 +1,307 @@
+package discoverers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+const (
+	consulDefaultAddress = "127.0.0.1:8500"
+	consulDefaultTimeout = 10 * time.Second
+)
+
+type consulNode struct {
+	Node       string
+	Address    string
+	Datacenter string
+	Meta       map[string]string
+}
+
+type consulHealthCheck struct {
+	Node      string
+	ServiceID string
+	Status    string
+}
+
+type consulServiceEntry struct {
+	Node    consulNode
+	Service struct {
+		ID      string
+		Service string
+		Address string
+		Tags    []string
+	}
+}
+
+/*
+consulQuery is a parsed target definition: [dc:DATACENTER/]service:NAME[?tag=TAG&passing=false] or
+[dc:DATACENTER/]node:GLOB[?passing=false]
+*/
+type consulQuery struct {
+	datacenter string
+	service    string
+	node       string
+	tags       []string
+	passing    bool
+}
+
+func parseConsulQuery(input string) (*consulQuery, error) {
+	q := &consulQuery{passing: true}
+	if strings.HasPrefix(input, "dc:") {
+		slash := strings.Index(input, "/")
+		if slash == -1 {
+			return nil, &util.InvalidTargetError{Input: input, Msg: "expected /service:NAME or /node:GLOB after the datacenter"}
+		}
+		q.datacenter = input[len("dc:"):slash]
+		input = input[slash+1:]
+	}
+	// ? is also a glob character in node names, so it only starts parameters if they follow
+	rest, rawQuery := input, ""
+	if i := strings.LastIndex(input, "?"); i != -1 && strings.Contains(input[i+1:], "=") {
+		rest, rawQuery = input[:i], input[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(rest, "service:"):
+		q.service = rest[len("service:"):]
+	case strings.HasPrefix(rest, "node:"):
+		q.node = rest[len("node:"):]
+	default:
+		return nil, nil
+	}
+	if q.service == "" && q.node == "" {
+		return nil, &util.InvalidTargetError{Input: input, Msg: "missing service or node name"}
+	}
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, &util.InvalidTargetError{Input: input, Msg: err.Error()}
+	}
+	for key, values := range params {
+		switch {
+		case key == "tag" && q.service != "":
+			q.tags = values
+		case key == "passing" && len(values) == 1 && (values[0] == "true" || values[0] == "false"):
+			q.passing = values[0] == "true"
+		default:
+			return nil, &util.InvalidTargetError{Input: input, Msg: fmt.Sprintf("unsupported parameter %s=%s", key, strings.Join(values, ","))}
+		}
+	}
+	return q, nil
+}
+
+type consul struct {
+	args    []interface{}
+	address string
+	client  *http.Client
+}
+
+func (d *consul) baseURL() string {
+	address := d.address
+	if address == "" {
+		address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if address == "" {
+		address = consulDefaultAddress
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	return strings.TrimRight(address, "/")
+}
+
+// get sends a GET request to the Consul HTTP API, and parses the JSON response into result
+func (d *consul) get(path string, params url.Values, result interface{}) error {
+	u := d.baseURL() + path
+	if encoded := params.Encode(); encoded != "" {
+		u += "?" + encoded
+	}
+	util.Logger.Debugf("GET %s", u)
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return util.ConfigErrorf("Invalid Consul address %s: %s", d.baseURL(), err)
+	}
+	if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+		request.Header.Set("X-Consul-Token", token)
+	}
+	response, err := d.client.Do(request)
+	if err != nil {
+		return &util.CommandError{Argv: []string{"GET", u}, Err: err}
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return &util.CommandError{Argv: []string{"GET", u}, Err: err}
+	}
+	if response.StatusCode != http.StatusOK {
+		return &util.CommandError{Argv: []string{"GET", u}, Err: fmt.Errorf("%s", response.Status), Output: body}
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return &util.InvalidOutputError{Source: "GET " + u, Err: err, Output: body}
+	}
+	return nil
+}
+
+func consulTarget(node consulNode, address string) target.Target {
+	t := target.Target{Hostname: node.Node}
+	if net.ParseIP(address) != nil {
+		t.IP = address
+	} else {
+		t.Host = address
+	}
+	t.SetLabel("consul.node", node.Node)
+	t.SetLabel("consul.datacenter", node.Datacenter)
+	for key, value := range node.Meta {
+		t.SetLabel("consul.meta."+key, value)
+	}
+	return t
+}
+
+func (d *consul) discoverService(q *consulQuery) ([]target.Target, error) {
+	params := url.Values{}
+	if q.datacenter != "" {
+		params.Set("dc", q.datacenter)
+	}
+	for _, tag := range q.tags {
+		params.Add("tag", tag)
+	}
+	if q.passing {
+		params.Set("passing", "")
+	}
+	var entries []consulServiceEntry
+	if err := d.get("/v1/health/service/"+url.PathEscape(q.service), params, &entries); err != nil {
+		return nil, err
+	}
+	targets := []target.Target{}
+	for _, entry := range entries {
+		address := entry.Service.Address
+		if address == "" {
+			address = entry.Node.Address
+		}
+		t := consulTarget(entry.Node, address)
+		if t.IsEmpty() {
+			util.Logger.Infof("Consul service %s on node %s doesn't have an address, ignoring", entry.Service.ID, entry.Node.Node)
+			continue
+		}
+		t.SetLabel("consul.service", entry.Service.Service)
+		t.SetLabel("consul.service_id", entry.Service.ID)
+		tags := append([]string{}, entry.Service.Tags...)
+		sort.Strings(tags)
+		t.SetLabel("consul.tags", strings.Join(tags, ","))
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func (d *consul) discoverNodes(q *consulQuery) ([]target.Target, error) {
+	params := url.Values{}
+	if q.datacenter != "" {
+		params.Set("dc", q.datacenter)
+	}
+	var nodes []consulNode
+	if err := d.get("/v1/catalog/nodes", params, &nodes); err != nil {
+		return nil, err
+	}
+	// Nodes are healthy if all their node-level checks (the ones not belonging to a service) are passing
+	unhealthy := map[string]bool{}
+	if q.passing {
+		var checks []consulHealthCheck
+		if err := d.get("/v1/health/state/any", params, &checks); err != nil {
+			return nil, err
+		}
+		for _, check := range checks {
+			if check.ServiceID == "" && check.Status != "passing" {
+				unhealthy[check.Node] = true
+			}
+		}
+	}
+	targets := []target.Target{}
+	for _, node := range nodes {
+		if !sshConfigPatternMatches(q.node, node.Node) {
+			continue
+		}
+		if unhealthy[node.Node] {
+			util.Logger.Infof("Consul node %s is not healthy, ignoring", node.Node)
+			continue
+		}
+		t := consulTarget(node, node.Address)
+		if t.IsEmpty() {
+			util.Logger.Infof("Consul node %s doesn't have an address, ignoring", node.Node)
+			continue
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func (d *consul) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtMost(d, 2, d.args); err != nil {
+		return nil, err
+	}
+	q, err := parseConsulQuery(input)
+	if err != nil {
+		return nil, err
+	}
+	if q == nil {
+		util.Logger.Debugf("Host lookup string doesn't start with service: or node:, it won't match anything in Consul")
+		return []target.Target{}, nil
+	}
+	util.Logger.Infof("Looking up %s in Consul at %s", input, d.baseURL())
+	if q.service != "" {
+		return d.discoverService(q)
+	}
+	return d.discoverNodes(q)
+}
+
+/*
+SetArgs takes an optional address and an optional timeout of requests, in any order, like (consul consul:8500 30s)
+*/
+func (d *consul) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtMost(d, 2, args); err != nil {
+		return err
+	}
+	address, timeout := "", time.Duration(0)
+	for _, arg := range args {
+		if duration, err := util.DurationArg(arg); err == nil {
+			if timeout != 0 {
+				return util.ParseErrorf("%s takes at most one timeout, got %s", nameConsul, args)
+			}
+			timeout = duration
+			continue
+		}
+		value, err := util.StringArg(arg)
+		if err != nil {
+			return err
+		}
+		if address != "" {
+			return util.ParseErrorf("%s takes at most one address, got %s", nameConsul, args)
+		}
+		address = value
+	}
+	d.args = args
+	d.address = address
+	if timeout != 0 {
+		d.client.Timeout = timeout
+	}
+	return nil
+}
+
+func (d *consul) String() string {
+	parts := []string{nameConsul}
+	if d.address != "" {
+		parts = append(parts, d.address)
+	}
+	if d.client != nil && d.client.Timeout != consulDefaultTimeout {
+		parts = append(parts, d.client.Timeout.String())
+	}
+	return fmt.Sprintf("<%s>", strings.Join(parts, " "))
+}
@@ -0,0 +1,197 @@
+package discoverers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+const consulNodesJSON = `[
+  {"Node": "db-1", "Address": "10.0.1.1", "Datacenter": "eu1", "Meta": {"rack": "r1"}},
+  {"Node": "db-2", "Address": "10.0.1.2", "Datacenter": "eu1", "Meta": {}},
+  {"Node": "web-1", "Address": "web-1.node.consul", "Datacenter": "eu1"},
+  {"Node": "left-1", "Address": "", "Datacenter": "eu1"}
+]`
+
+const consulChecksJSON = `[
+  {"Node": "db-1", "CheckID": "serfHealth", "ServiceID": "", "Status": "passing"},
+  {"Node": "db-2", "CheckID": "serfHealth", "ServiceID": "", "Status": "critical"},
+  {"Node": "web-1", "CheckID": "service:api", "ServiceID": "api-1", "Status": "critical"}
+]`
+
+const consulServiceJSON = `[
+  {
+    "Node": {"Node": "web-1", "Address": "10.0.2.1", "Datacenter": "eu1", "Meta": {"rack": "r2"}},
+    "Service": {"ID": "api-1", "Service": "api", "Address": "", "Port": 8080, "Tags": ["v2", "canary"]}
+  },
+  {
+    "Node": {"Node": "web-2", "Address": "10.0.2.2", "Datacenter": "eu1"},
+    "Service": {"ID": "api-2", "Service": "api", "Address": "api-2.example.com", "Port": 8080, "Tags": []}
+  },
+  {
+    "Node": {"Node": "left-1", "Address": "", "Datacenter": "eu1"},
+    "Service": {"ID": "api-3", "Service": "api", "Address": "", "Port": 8080, "Tags": []}
+  }
+]`
+
+// withConsul runs f with a consul discoverer pointed at a stand-in for the Consul HTTP API, which records the requests
+func withConsul(t *testing.T, f func(d *consul, requests *[]string)) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.String())
+		switch r.URL.Path {
+		case "/v1/catalog/nodes":
+			fmt.Fprint(w, consulNodesJSON)
+		case "/v1/health/state/any":
+			fmt.Fprint(w, consulChecksJSON)
+		case "/v1/health/service/api":
+			fmt.Fprint(w, consulServiceJSON)
+		case "/v1/health/service/broken":
+			fmt.Fprint(w, "not json")
+		default:
+			http.Error(w, "Unknown path", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	f(mustMake(t, "(consul "+server.URL+")").(*consul), &requests)
+}
+
+func TestConsulStringViaMake(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("MakeFromString %s -> %s", "(consul)", "[consul]")
+		l.ExpectDebugf("Make %s -> %s", "[consul]", "<consul>")
+		mustMake(t, "(consul)")
+		l.ExpectDebugf("MakeFromString %s -> %s", "(consul consul.example.com:8500)", "[consul consul.example.com:8500]")
+		l.ExpectDebugf("Make %s -> %s", "[consul consul.example.com:8500]", "<consul consul.example.com:8500>")
+		mustMake(t, "(consul consul.example.com:8500)")
+	})
+}
+
+func TestConsulArguments(t *testing.T) {
+	if s := mustMake(t, "(consul 30s consul.example.com:8500)").String(); s != "<consul consul.example.com:8500 30s>" {
+		t.Error(s)
+	}
+	if d := mustMake(t, "(consul 2s)").(*consul); d.address != "" || d.client.Timeout != 2*time.Second {
+		t.Error(d.address, d.client.Timeout)
+	}
+	_, err := Make("(consul a b)")
+	util.ExpectError(t, "consul takes at most one address, got [a b] in (consul a b) at position 0", err)
+	_, err = Make("(consul 1s 2s)")
+	util.ExpectError(t, "consul takes at most one timeout, got [1s 2s] in (consul 1s 2s) at position 0", err)
+}
+
+func TestConsulTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	d := mustMake(t, "(consul "+server.URL+" 10ms)")
+	if _, err := d.Discover("service:api"); err == nil || !strings.Contains(err.Error(), "Client.Timeout exceeded") {
+		t.Error(err)
+	}
+}
+
+func TestConsulBaseURL(t *testing.T) {
+	oldAddr := os.Getenv("CONSUL_HTTP_ADDR")
+	defer os.Setenv("CONSUL_HTTP_ADDR", oldAddr)
+	os.Setenv("CONSUL_HTTP_ADDR", "")
+	util.AssertStringListEquals(t, []string{"http://127.0.0.1:8500"}, []string{mustMake(t, "(consul)").(*consul).baseURL()})
+	os.Setenv("CONSUL_HTTP_ADDR", "https://consul.example.com/")
+	util.AssertStringListEquals(t, []string{"https://consul.example.com"}, []string{mustMake(t, "(consul)").(*consul).baseURL()})
+	util.AssertStringListEquals(t, []string{"http://other:8500"}, []string{mustMake(t, "(consul other:8500)").(*consul).baseURL()})
+}
+
+func TestConsulService(t *testing.T) {
+	withConsul(t, func(d *consul, requests *[]string) {
+		target.AssertTargetListEquals(t, []target.Target{
+			{IP: "10.0.2.1", Hostname: "web-1", Labels: map[string]string{
+				"consul.node": "web-1", "consul.datacenter": "eu1", "consul.meta.rack": "r2",
+				"consul.service": "api", "consul.service_id": "api-1", "consul.tags": "canary,v2"}},
+			{Host: "api-2.example.com", Hostname: "web-2", Labels: map[string]string{
+				"consul.node": "web-2", "consul.datacenter": "eu1", "consul.service": "api", "consul.service_id": "api-2"}},
+		}, mustDiscover(t, d, "service:api"))
+		mustDiscover(t, d, "dc:eu1/service:api?tag=canary&tag=v2")
+		mustDiscover(t, d, "service:api?passing=false")
+		util.AssertStringListEquals(t, []string{
+			"/v1/health/service/api?passing=",
+			"/v1/health/service/api?dc=eu1&passing=&tag=canary&tag=v2",
+			"/v1/health/service/api",
+		}, *requests)
+	})
+}
+
+func TestConsulNodes(t *testing.T) {
+	withConsul(t, func(d *consul, requests *[]string) {
+		target.AssertTargetListEquals(t, []target.Target{
+			{IP: "10.0.1.1", Hostname: "db-1", Labels: map[string]string{
+				"consul.node": "db-1", "consul.datacenter": "eu1", "consul.meta.rack": "r1"}},
+		}, mustDiscover(t, d, "node:db-*"))
+		target.AssertTargetListEquals(t, []target.Target{
+			{IP: "10.0.1.1", Hostname: "db-1", Labels: map[string]string{
+				"consul.node": "db-1", "consul.datacenter": "eu1", "consul.meta.rack": "r1"}},
+			{IP: "10.0.1.2", Hostname: "db-2", Labels: map[string]string{"consul.node": "db-2", "consul.datacenter": "eu1"}},
+			{Host: "web-1.node.consul", Hostname: "web-1", Labels: map[string]string{"consul.node": "web-1", "consul.datacenter": "eu1"}},
+		}, mustDiscover(t, d, "dc:eu1/node:*?passing=false"))
+		target.AssertTargetListEquals(t, []target.Target{
+			{Host: "web-1.node.consul", Hostname: "web-1", Labels: map[string]string{"consul.node": "web-1", "consul.datacenter": "eu1"}},
+		}, mustDiscover(t, d, "node:web-?"))
+		util.AssertStringListEquals(t, []string{
+			"/v1/catalog/nodes", "/v1/health/state/any",
+			"/v1/catalog/nodes?dc=eu1",
+			"/v1/catalog/nodes", "/v1/health/state/any",
+		}, *requests)
+	})
+}
+
+func TestConsulIgnoresNodesWithoutAddress(t *testing.T) {
+	withConsul(t, func(d *consul, requests *[]string) {
+		util.WithLogAssertions(t, func(l *util.MockLogger) {
+			l.ExpectInfof("Looking up %s in Consul at %s", "dc:eu1/node:left-*?passing=false", d.baseURL())
+			l.ExpectDebugf("GET %s", d.baseURL()+"/v1/catalog/nodes?dc=eu1")
+			l.ExpectInfof("Consul node %s doesn't have an address, ignoring", "left-1")
+			target.AssertTargetListEquals(t, []target.Target{}, mustDiscover(t, d, "dc:eu1/node:left-*?passing=false"))
+			l.ExpectInfof("Looking up %s in Consul at %s", "service:api?passing=false", d.baseURL())
+			l.ExpectDebugf("GET %s", d.baseURL()+"/v1/health/service/api")
+			l.ExpectInfof("Consul service %s on node %s doesn't have an address, ignoring", "api-3", "left-1")
+			if targets := mustDiscover(t, d, "service:api?passing=false"); len(targets) != 2 {
+				t.Error(targets)
+			}
+		})
+	})
+}
+
+func TestConsulIgnoresOtherInputs(t *testing.T) {
+	withConsul(t, func(d *consul, requests *[]string) {
+		target.AssertTargetListEquals(t, []target.Target{}, mustDiscover(t, d, "web-1.example.com"))
+		util.AssertStringListEquals(t, []string{}, *requests)
+	})
+}
+
+func TestConsulErrors(t *testing.T) {
+	withConsul(t, func(d *consul, requests *[]string) {
+		cases := []struct {
+			input string
+			err   string
+		}{
+			{"dc:eu1", `Invalid target "dc:eu1": expected /service:NAME or /node:GLOB after the datacenter`},
+			{"service:", `Invalid target "service:": missing service or node name`},
+			{"node:db?tag=x", `Invalid target "node:db?tag=x": unsupported parameter tag=x`},
+			{"service:api?passing=maybe", `Invalid target "service:api?passing=maybe": unsupported parameter passing=maybe`},
+			{"service:missing", "[GET " + d.baseURL() + "/v1/health/service/missing?passing=] failed: 404 Not Found\nOutput:\nUnknown path\n"},
+			{"service:broken", "Failed to parse output of GET " + d.baseURL() + "/v1/health/service/broken?passing=: invalid character 'o' in literal null (expecting 'u')"},
+		}
+		for _, c := range cases {
+			_, err := d.Discover(c.input)
+			util.ExpectError(t, c.err, err)
+		}
+	})
+}
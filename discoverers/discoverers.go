@@ -1,15 +1,39 @@
 package discoverers
 
 import (
+	"fmt"
+	"net/http"
+	"os"
 	"sort"
 
 	"github.com/abesto/easyssh/fromsexp"
 	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/plugin"
 	"github.com/abesto/easyssh/util"
 )
 
-func Make(input string) interfaces.Discoverer {
-	return fromsexp.MakeFromString(input, sexpTransforms, makeByName).(interfaces.Discoverer)
+// Make creates a discoverer from its definition. It's not safe for concurrent use, see fromsexp.Exclusive.
+func Make(input string) (interfaces.Discoverer, error) {
+	d, err := fromsexp.MakeFromString(input, sexpTransforms, makeByName)
+	if err != nil {
+		return nil, err
+	}
+	return d.(interfaces.Discoverer), nil
+}
+
+/*
+Register makes a discoverer available to Make under name, next to the built-in ones. maker must return a new,
+unconfigured instance on each call; Make calls SetArgs on it. Register is not safe to call concurrently with Make,
+so it's best called from an init function.
+*/
+func Register(name string, maker func() interfaces.Discoverer) error {
+	for _, existing := range SupportedDiscovererNames() {
+		if existing == name {
+			return fmt.Errorf("Discoverer \"%s\" is already defined", name)
+		}
+	}
+	discovererMakerMap[name] = maker
+	return nil
 }
 
 func SupportedDiscovererNames() []string {
@@ -31,15 +55,41 @@ func SupportedDiscovererNames() []string {
 	return names
 }
 
-func makeFromSExp(data []interface{}) interfaces.Discoverer {
-	return fromsexp.Make(data, sexpTransforms, makeByName).(interfaces.Discoverer)
+func makeFromSExp(data interface{}) (interfaces.Discoverer, error) {
+	list, err := util.ListArg(data)
+	if err != nil {
+		return nil, err
+	}
+	d, err := fromsexp.Make(list, sexpTransforms, makeByName)
+	if err != nil {
+		return nil, err
+	}
+	return d.(interfaces.Discoverer), nil
 }
 
 const (
-	nameKnife         = "knife"
-	nameFirstMatching = "first-matching"
-	nameFixed         = "fixed"
-	nameSeparatedBy   = "separated-by"
+	nameKnife                   = "knife"
+	nameFirstMatching           = "first-matching"
+	nameFirstMatchingConcurrent = "first-matching-concurrent"
+	nameFixed                   = "fixed"
+	nameSeparatedBy             = "separated-by"
+	nameSSHConfig               = "ssh-config"
+	nameAnsibleInventory        = "ansible-inventory"
+	nameTerraformState          = "terraform-state"
+	nameConsul                  = "consul"
+	nameKubectl                 = "kubectl"
+	nameDocker                  = "docker"
+	nameEc2                     = "ec2"
+	nameGcloud                  = "gcloud"
+	nameAzure                   = "azure"
+	nameExpand                  = "expand"
+	nameFile                    = "file"
+	nameUnion                   = "union"
+	nameUnionStrict             = "union-strict"
+	nameSetOps                  = "set-ops"
+	nameRoute                   = "route"
+	nameIfInputMatches          = "if-input-matches"
+	nameCached                  = "cached"
 )
 
 var discovererMakerMap = map[string]func() interfaces.Discoverer{
@@ -48,16 +98,34 @@ var discovererMakerMap = map[string]func() interfaces.Discoverer{
 		return &knifeSearch{
 			realKnifeSearchResultRowExtractor{}, util.RealCommandRunner{}}
 	},
-	nameFirstMatching: func() interfaces.Discoverer { return &firstMatching{} },
-	nameFixed:         func() interfaces.Discoverer { return &fixed{} },
+	nameFirstMatching:           func() interfaces.Discoverer { return &firstMatching{} },
+	nameFirstMatchingConcurrent: func() interfaces.Discoverer { return &firstMatching{concurrent: true} },
+	nameFixed:                   func() interfaces.Discoverer { return &fixed{} },
+	nameSSHConfig:               func() interfaces.Discoverer { return &sshConfig{} },
+	nameAnsibleInventory:        func() interfaces.Discoverer { return &ansibleInventoryDiscoverer{} },
+	nameTerraformState:          func() interfaces.Discoverer { return &terraformState{} },
+	nameConsul:                  func() interfaces.Discoverer { return &consul{client: &http.Client{Timeout: consulDefaultTimeout}} },
+	nameKubectl:                 func() interfaces.Discoverer { return &kubectl{commandRunner: util.RealCommandRunner{}} },
+	nameDocker:                  func() interfaces.Discoverer { return &docker{commandRunner: util.RealCommandRunner{}} },
+	nameEc2:                     func() interfaces.Discoverer { return &ec2Search{commandRunner: util.RealCommandRunner{}} },
+	nameGcloud:                  func() interfaces.Discoverer { return &gcloud{commandRunner: util.RealCommandRunner{}} },
+	nameAzure:                   func() interfaces.Discoverer { return &azure{commandRunner: util.RealCommandRunner{}} },
+	nameExpand:                  func() interfaces.Discoverer { return &expand{limit: expandDefaultLimit} },
+	nameFile:                    func() interfaces.Discoverer { return &hostFile{stdin: os.Stdin} },
+	nameUnion:                   func() interfaces.Discoverer { return &union{} },
+	nameUnionStrict:             func() interfaces.Discoverer { return &union{strict: true} },
+	nameSetOps:                  func() interfaces.Discoverer { return &setOps{} },
+	nameRoute:                   func() interfaces.Discoverer { return &route{} },
+	nameCached:                  func() interfaces.Discoverer { return &cached{} },
 }
 
 var sexpTransforms = []fromsexp.SexpTransform{
 	fromsexp.Alias("const", "fixed"),
 	fromsexp.Replace("(comma-separated)", "(separated-by ,)"),
+	ifInputMatches,
 }
 
-func makeByName(name string) interface{} {
+func makeByName(name string) (interface{}, error) {
 	var d interfaces.Discoverer
 	for key, maker := range discovererMakerMap {
 		if key == name {
@@ -65,7 +133,12 @@ func makeByName(name string) interface{} {
 		}
 	}
 	if d == nil {
-		util.Panicf("Discoverer \"%s\" is not known", name)
+		if path, ok := plugin.Lookup(plugin.KindDiscoverer, name); ok {
+			return plugin.NewDiscoverer(name, path), nil
+		}
+		err := util.ParseErrorf("Discoverer \"%s\" is not known", name)
+		err.Suggestion = util.Suggest(name, append(SupportedDiscovererNames(), plugin.Names(plugin.KindDiscoverer)...))
+		return nil, err
 	}
-	return d
+	return d, nil
 }
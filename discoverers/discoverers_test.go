@@ -1,13 +1,67 @@
 package discoverers
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
 
+func mustMake(t *testing.T, input string) interfaces.Discoverer {
+	d, err := Make(input)
+	util.ExpectNoError(t, err)
+	return d
+}
+
+func mustDiscover(t *testing.T, d interfaces.Discoverer, input string) []target.Target {
+	targets, err := d.Discover(input)
+	util.ExpectNoError(t, err)
+	return targets
+}
+
 func TestSupportedDiscovererNames(t *testing.T) {
 	util.AssertStringListEquals(t,
-		[]string{"comma-separated", "const", "first-matching", "fixed", "knife", "separated-by"},
+		[]string{"ansible-inventory", "azure", "cached", "comma-separated", "const", "consul", "docker", "ec2", "expand", "file", "first-matching", "first-matching-concurrent", "fixed", "gcloud", "if-input-matches", "knife", "kubectl", "route", "separated-by", "set-ops", "ssh-config", "terraform-state", "union", "union-strict"},
 		SupportedDiscovererNames())
 }
+
+func TestMakeUnknownDiscoverer(t *testing.T) {
+	_, err := Make("(first-matching (knif))")
+	util.ExpectError(t, "Discoverer \"knif\" is not known in (knif) at position 16; did you mean knife?", err)
+	_, err = Make("(foobar)")
+	util.ExpectError(t, "Discoverer \"foobar\" is not known in (foobar) at position 0", err)
+}
+
+func TestRegister(t *testing.T) {
+	util.ExpectNoError(t, Register("test-registered", func() interfaces.Discoverer { return &fixed{} }))
+	defer delete(discovererMakerMap, "test-registered")
+
+	d := mustMake(t, "(test-registered foo)")
+	target.AssertTargetListEquals(t, target.MustFromStrings("foo"), mustDiscover(t, d, ""))
+
+	util.ExpectError(t, "Discoverer \"test-registered\" is already defined",
+		Register("test-registered", func() interfaces.Discoverer { return &fixed{} }))
+	util.ExpectError(t, "Discoverer \"comma-separated\" is already defined",
+		Register("comma-separated", func() interfaces.Discoverer { return &fixed{} }))
+}
+
+func TestMakePlugin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "easyssh-plugin-test")
+	util.ExpectNoError(t, err)
+	defer os.RemoveAll(dir)
+	util.ExpectNoError(t, ioutil.WriteFile(filepath.Join(dir, "easyssh-discoverer-inventory"), []byte("#!/bin/sh\n"), 0755))
+	oldPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", oldPath)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+
+	d := mustMake(t, "(first-matching (inventory prod) (comma-separated))")
+	if d.String() != "<first-matching [<inventory [prod]> <separated-by ,>]>" {
+		t.Errorf("Unexpected discoverer %s", d)
+	}
+	_, err = Make("(inventroy)")
+	util.ExpectError(t, "Discoverer \"inventroy\" is not known in (inventroy) at position 0; did you mean inventory?", err)
+}
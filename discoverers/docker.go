@@ -0,0 +1,183 @@
+package discoverers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// dockerPsRow is a line of docker ps --format json
+type dockerPsRow struct {
+	ID    string
+	Names string
+}
+
+type dockerInspectResult struct {
+	ID     string `json:"Id"`
+	Name   string
+	Config struct {
+		Image  string
+		Labels map[string]string
+	}
+	NetworkSettings struct {
+		IPAddress string
+		Networks  map[string]struct {
+			IPAddress string
+		}
+	}
+}
+
+/*
+dockerPsFilters turns a target definition into docker ps filters, and the glob container names must match.
+label:KEY[=VALUE][,KEY[=VALUE]...] matches labels, service:[PROJECT/]SERVICE matches Docker Compose services, anything
+else is a glob matched against container names.
+*/
+func dockerPsFilters(input string) ([]string, string) {
+	var filters []string
+	switch {
+	case strings.HasPrefix(input, "label:"):
+		for _, label := range strings.Split(strings.TrimPrefix(input, "label:"), ",") {
+			filters = append(filters, "--filter", "label="+label)
+		}
+		return filters, "*"
+	case strings.HasPrefix(input, "service:"):
+		service := strings.TrimPrefix(input, "service:")
+		if slash := strings.Index(service, "/"); slash != -1 {
+			filters = append(filters, "--filter", "label=com.docker.compose.project="+service[:slash])
+			service = service[slash+1:]
+		}
+		return append(filters, "--filter", "label=com.docker.compose.service="+service), "*"
+	}
+	return nil, input
+}
+
+// dockerIP returns the IP address of a container on the default bridge, or on the first of its networks by name
+func dockerIP(container dockerInspectResult) string {
+	if container.NetworkSettings.IPAddress != "" {
+		return container.NetworkSettings.IPAddress
+	}
+	var networks []string
+	for network := range container.NetworkSettings.Networks {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+	for _, network := range networks {
+		if ip := container.NetworkSettings.Networks[network].IPAddress; ip != "" {
+			return ip
+		}
+	}
+	return ""
+}
+
+type docker struct {
+	args          []interface{}
+	context       string
+	commandRunner util.CommandRunner
+}
+
+func (d *docker) run(args ...string) ([]byte, error) {
+	if d.context != "" {
+		args = append([]string{"--context", d.context}, args...)
+	}
+	outputs := d.commandRunner.Outputs("docker", args)
+	if outputs.Error != nil {
+		return nil, &util.CommandError{Argv: append([]string{"docker"}, args...), Err: outputs.Error, Output: outputs.Combined}
+	}
+	return outputs.Stdout, nil
+}
+
+func (d *docker) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtMost(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	filters, glob := dockerPsFilters(input)
+	util.Logger.Infof("Looking up containers with docker matching %s", input)
+	stdout, err := d.run(append([]string{"ps", "--format", "json"}, filters...)...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, line := range bytes.Split(stdout, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var row dockerPsRow
+		if err := json.Unmarshal(line, &row); err != nil {
+			return nil, &util.InvalidOutputError{Source: "docker ps", Err: err, Output: stdout}
+		}
+		for _, name := range strings.Split(row.Names, ",") {
+			if sshConfigPatternMatches(glob, name) {
+				ids = append(ids, row.ID)
+				break
+			}
+		}
+	}
+
+	targets := []target.Target{}
+	if len(ids) == 0 {
+		return targets, nil
+	}
+	stdout, err = d.run(append([]string{"inspect"}, ids...)...)
+	if err != nil {
+		return nil, err
+	}
+	var containers []dockerInspectResult
+	if err := json.Unmarshal(stdout, &containers); err != nil {
+		return nil, &util.InvalidOutputError{Source: "docker inspect", Err: err, Output: stdout}
+	}
+	for _, container := range containers {
+		name := strings.TrimPrefix(container.Name, "/")
+		t := target.Target{Host: name, IP: dockerIP(container)}
+		t.SetLabel("docker.context", d.context)
+		t.SetLabel("docker.container", name)
+		if len(container.ID) > 12 {
+			container.ID = container.ID[:12]
+		}
+		t.SetLabel("docker.id", container.ID)
+		t.SetLabel("docker.image", container.Config.Image)
+		for key, value := range container.Config.Labels {
+			t.SetLabel("docker.label."+key, value)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func (d *docker) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtMost(d, 1, args); err != nil {
+		return err
+	}
+	d.args = args
+	if len(args) == 1 {
+		context, err := util.StringArg(args[0])
+		if err != nil {
+			return err
+		}
+		d.context = context
+	}
+	return util.RequireOnPath(d, "docker")
+}
+
+func (d *docker) SetCommandRunner(r util.CommandRunner) {
+	d.commandRunner = r
+}
+
+func (d *docker) CommandRunner() util.CommandRunner {
+	return d.commandRunner
+}
+
+func (d *docker) RequiredBinaries() []string {
+	return []string{"docker"}
+}
+
+func (d *docker) String() string {
+	if d.context == "" {
+		return fmt.Sprintf("<%s>", nameDocker)
+	}
+	return fmt.Sprintf("<%s %s>", nameDocker, d.context)
+}
@@ -0,0 +1,108 @@
+package discoverers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+const dockerPsJSON = `{"Command":"\"nginx\"","ID":"0123456789ab","Image":"nginx","Names":"shop-web-1","State":"running"}
+{"Command":"\"nginx\"","ID":"123456789abc","Image":"nginx","Names":"shop-web-2,legacy-link","State":"running"}
+{"Command":"\"postgres\"","ID":"23456789abcd","Image":"postgres","Names":"shop-db-1","State":"running"}
+`
+
+const dockerInspectJSON = `[
+  {
+    "Id": "0123456789abcdef0123456789abcdef", "Name": "/shop-web-1",
+    "Config": {"Image": "nginx", "Labels": {"com.docker.compose.project": "shop", "com.docker.compose.service": "web"}},
+    "NetworkSettings": {"IPAddress": "", "Networks": {"shop_z": {"IPAddress": "172.19.0.2"}, "shop_a": {"IPAddress": "172.18.0.2"}}}
+  },
+  {
+    "Id": "123456789abcdef0123456789abcdef0", "Name": "/shop-web-2",
+    "Config": {"Image": "nginx", "Labels": {}},
+    "NetworkSettings": {"IPAddress": "172.17.0.3", "Networks": {}}
+  }
+]`
+
+func givenAMockedDocker(context string) (*docker, *util.MockCommandRunner) {
+	r := &util.MockCommandRunner{}
+	return &docker{context: context, commandRunner: r}, r
+}
+
+func TestDockerStringViaMake(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("MakeFromString %s -> %s", "(docker)", "[docker]")
+		l.ExpectDebugf("Make %s -> %s", "[docker]", "<docker>")
+		mustMake(t, "(docker)")
+		l.ExpectDebugf("MakeFromString %s -> %s", "(docker staging)", "[docker staging]")
+		l.ExpectDebugf("Make %s -> %s", "[docker staging]", "<docker staging>")
+		mustMake(t, "(docker staging)")
+	})
+}
+
+func TestDockerPsFilters(t *testing.T) {
+	cases := []struct {
+		input   string
+		filters []string
+		glob    string
+	}{
+		{"shop-web-*", []string{}, "shop-web-*"},
+		{"label:role=web,env", []string{"--filter", "label=role=web", "--filter", "label=env"}, "*"},
+		{"service:web", []string{"--filter", "label=com.docker.compose.service=web"}, "*"},
+		{"service:shop/web", []string{"--filter", "label=com.docker.compose.project=shop", "--filter", "label=com.docker.compose.service=web"}, "*"},
+	}
+	for _, c := range cases {
+		filters, glob := dockerPsFilters(c.input)
+		util.AssertStringListEquals(t, c.filters, append([]string{}, filters...))
+		util.AssertStringListEquals(t, []string{c.glob}, []string{glob})
+	}
+}
+
+func TestDockerDiscover(t *testing.T) {
+	d, r := givenAMockedDocker("staging")
+	r.On("Outputs", "docker", []string{"--context", "staging", "ps", "--format", "json"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(dockerPsJSON)}).Times(1)
+	r.On("Outputs", "docker", []string{"--context", "staging", "inspect", "0123456789ab", "123456789abc"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(dockerInspectJSON)}).Times(1)
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectInfof("Looking up containers with docker matching %s", "*-web-*")
+		target.AssertTargetListEquals(t, []target.Target{
+			{Host: "shop-web-1", IP: "172.18.0.2", Labels: map[string]string{
+				"docker.context": "staging", "docker.container": "shop-web-1", "docker.id": "0123456789ab", "docker.image": "nginx",
+				"docker.label.com.docker.compose.project": "shop", "docker.label.com.docker.compose.service": "web"}},
+			{Host: "shop-web-2", IP: "172.17.0.3", Labels: map[string]string{
+				"docker.context": "staging", "docker.container": "shop-web-2", "docker.id": "123456789abc", "docker.image": "nginx"}},
+		}, mustDiscover(t, d, "*-web-*"))
+	})
+	r.AssertExpectations(t)
+}
+
+func TestDockerDiscoverNothing(t *testing.T) {
+	d, r := givenAMockedDocker("")
+	r.On("Outputs", "docker", []string{"ps", "--format", "json", "--filter", "label=com.docker.compose.service=web"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte("")}).Times(1)
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectInfof("Looking up containers with docker matching %s", "service:web")
+		target.AssertTargetListEquals(t, []target.Target{}, mustDiscover(t, d, "service:web"))
+	})
+	r.AssertExpectations(t)
+}
+
+func TestDockerErrors(t *testing.T) {
+	d, r := givenAMockedDocker("")
+	r.On("Outputs", "docker", []string{"ps", "--format", "json", "--filter", "label=a"}).
+		Return(util.CommandRunnerOutputs{Error: errors.New("exit status 1"), Combined: []byte("Cannot connect to the Docker daemon")}).Times(1)
+	r.On("Outputs", "docker", []string{"ps", "--format", "json"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte("{{.Names}}\n")}).Times(1)
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectInfof("Looking up containers with docker matching %s", "label:a")
+		_, err := d.Discover("label:a")
+		util.ExpectError(t, "[docker ps --format json --filter label=a] failed: exit status 1\nOutput:\nCannot connect to the Docker daemon", err)
+		l.ExpectInfof("Looking up containers with docker matching %s", "web")
+		_, err = d.Discover("web")
+		util.ExpectError(t, "Failed to parse output of docker ps: invalid character '{' looking for beginning of object key string", err)
+	})
+	r.AssertExpectations(t)
+}
@@ -0,0 +1,130 @@
+package discoverers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/abesto/easyssh/ec2"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+/*
+ec2Filters turns a target definition into describe-instances filters. The definition is a comma-separated list of
+tag:KEY=VALUE and asg:GROUP terms, all of which must match; values may contain * and ? wildcards.
+Returns nil if input is not such a list.
+*/
+func ec2Filters(input string) []string {
+	var filters []string
+	for _, term := range strings.Split(input, ",") {
+		switch {
+		case strings.HasPrefix(term, "tag:"):
+			parts := strings.SplitN(strings.TrimPrefix(term, "tag:"), "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil
+			}
+			filters = append(filters, fmt.Sprintf("Name=tag:%s,Values=%s", parts[0], parts[1]))
+		case strings.HasPrefix(term, "asg:") && len(term) > len("asg:"):
+			filters = append(filters, "Name=tag:aws:autoscaling:groupName,Values="+strings.TrimPrefix(term, "asg:"))
+		default:
+			return nil
+		}
+	}
+	return append(filters, "Name=instance-state-name,Values=running")
+}
+
+type ec2Search struct {
+	args          []interface{}
+	regions       []string
+	commandRunner util.CommandRunner
+}
+
+func (d *ec2Search) discoverInRegion(region string, filters []string) ([]target.Target, error) {
+	argv := append([]string{"ec2", "describe-instances", "--region", region, "--output", "json", "--filters"}, filters...)
+	outputs := d.commandRunner.Outputs("aws", argv)
+	if outputs.Error != nil {
+		return nil, &util.CommandError{Argv: append([]string{"aws"}, argv...), Err: outputs.Error, Output: outputs.Combined}
+	}
+	var data ec2.DescribeInstancesResponse
+	if err := json.Unmarshal(outputs.Stdout, &data); err != nil {
+		return nil, &util.InvalidOutputError{Source: "aws ec2 describe-instances", Err: err, Output: outputs.Stdout}
+	}
+	var targets []target.Target
+	for _, reservation := range data.Reservations {
+		for _, instance := range reservation.Instances {
+			var t target.Target
+			ec2.SetAddress(&t, instance)
+			if t.IsEmpty() {
+				util.Logger.Infof("EC2 instance %s in %s doesn't have an IP address or DNS name, ignoring", instance.InstanceId, region)
+				continue
+			}
+			ec2.SetLabels(&t, region, instance)
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+func (d *ec2Search) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	filters := ec2Filters(input)
+	if filters == nil {
+		util.Logger.Debugf("Host lookup string is not a list of tag:KEY=VALUE and asg:GROUP terms, it won't match anything in EC2")
+		return []target.Target{}, nil
+	}
+
+	util.Logger.Infof("Looking up EC2 instances matching %s in %s", input, d.regions)
+	results := make([][]target.Target, len(d.regions))
+	errs := make([]error, len(d.regions))
+	var wg sync.WaitGroup
+	for i, region := range d.regions {
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+			results[i], errs[i] = d.discoverInRegion(region, filters)
+		}(i, region)
+	}
+	wg.Wait()
+
+	targets := []target.Target{}
+	for i := range d.regions {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		targets = append(targets, results[i]...)
+	}
+	return targets, nil
+}
+
+func (d *ec2Search) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(d, 1, args); err != nil {
+		return err
+	}
+	regions, err := util.ByteToStringArray(args)
+	if err != nil {
+		return err
+	}
+	d.args = args
+	d.regions = regions
+	return util.RequireOnPath(d, "aws")
+}
+
+func (d *ec2Search) SetCommandRunner(r util.CommandRunner) {
+	d.commandRunner = r
+}
+
+func (d *ec2Search) CommandRunner() util.CommandRunner {
+	return d.commandRunner
+}
+
+func (d *ec2Search) RequiredBinaries() []string {
+	return []string{"aws"}
+}
+
+func (d *ec2Search) String() string {
+	return fmt.Sprintf("<%s %s>", nameEc2, d.regions)
+}
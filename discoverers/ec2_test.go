@@ -0,0 +1,107 @@
+package discoverers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+func givenAMockedEc2Search(regions ...string) (*ec2Search, *util.MockCommandRunner) {
+	r := &util.MockCommandRunner{}
+	args := make([]interface{}, len(regions))
+	for i, region := range regions {
+		args[i] = []byte(region)
+	}
+	return &ec2Search{args: args, regions: regions, commandRunner: r}, r
+}
+
+func ec2DescribeInstancesArgs(region string, filters ...string) []string {
+	return append([]string{"ec2", "describe-instances", "--region", region, "--output", "json", "--filters"}, filters...)
+}
+
+func TestEc2StringViaMake(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("MakeFromString %s -> %s", "(ec2 us-east-1 eu-west-1)", "[ec2 us-east-1 eu-west-1]")
+		l.ExpectDebugf("Make %s -> %s", "[ec2 us-east-1 eu-west-1]", "<ec2 [us-east-1 eu-west-1]>")
+		mustMake(t, "(ec2 us-east-1 eu-west-1)")
+	})
+}
+
+func TestEc2MakeWithoutArgument(t *testing.T) {
+	_, err := Make("(ec2)")
+	util.ExpectError(t, "<ec2 []> requires at least 1 argument(s), got 0: [] in (ec2) at position 0", err)
+}
+
+func TestEc2Filters(t *testing.T) {
+	util.AssertStringListEquals(t, []string{
+		"Name=tag:Role,Values=app", "Name=tag:Env,Values=prod*", "Name=instance-state-name,Values=running",
+	}, ec2Filters("tag:Role=app,tag:Env=prod*"))
+	util.AssertStringListEquals(t, []string{
+		"Name=tag:aws:autoscaling:groupName,Values=my-group", "Name=instance-state-name,Values=running",
+	}, ec2Filters("asg:my-group"))
+	for _, input := range []string{"web01", "tag:Role", "tag:=app", "asg:", "tag:Role=app,web01"} {
+		if filters := ec2Filters(input); filters != nil {
+			t.Errorf("%s should not be looked up in EC2, got %s", input, filters)
+		}
+	}
+}
+
+func TestEc2Discover(t *testing.T) {
+	d, r := givenAMockedEc2Search("us-east-1", "eu-west-1")
+	filters := []string{"Name=tag:Role,Values=app", "Name=instance-state-name,Values=running"}
+	r.On("Outputs", "aws", ec2DescribeInstancesArgs("us-east-1", filters...)).Return(util.CommandRunnerOutputs{Stdout: []byte(`
+		{"Reservations": [{"Instances": [
+			{"InstanceId": "i-1", "PublicIpAddress": "54.0.0.1", "PublicDnsName": "ec2-54-0-0-1.compute.amazonaws.com",
+			 "PrivateIpAddress": "10.0.0.1", "PrivateDnsName": "ip-10-0-0-1.ec2.internal",
+			 "Placement": {"AvailabilityZone": "us-east-1a"}, "Tags": [{"Key": "Role", "Value": "app"}]},
+			{"InstanceId": "i-2"}
+		]}]}`)}).Times(1)
+	r.On("Outputs", "aws", ec2DescribeInstancesArgs("eu-west-1", filters...)).Return(util.CommandRunnerOutputs{Stdout: []byte(`
+		{"Reservations": [{"Instances": [
+			{"InstanceId": "i-3", "PrivateIpAddress": "10.1.0.1", "PrivateDnsName": "ip-10-1-0-1.eu-west-1.compute.internal",
+			 "Tags": [{"Key": "Role", "Value": "app"}, {"Key": "Env", "Value": "prod"}]}
+		]}]}`)}).Times(1)
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectInfof("Looking up EC2 instances matching %s in %s", "tag:Role=app", "[us-east-1 eu-west-1]")
+		l.ExpectInfof("EC2 instance %s in %s doesn't have an IP address or DNS name, ignoring", "i-2", "us-east-1")
+		target.AssertTargetListEquals(t, []target.Target{
+			{Host: "ec2-54-0-0-1.compute.amazonaws.com", IP: "54.0.0.1", Labels: map[string]string{
+				"region": "us-east-1", "zone": "us-east-1a", "ec2.instance_id": "i-1",
+				"ec2.public_ip": "54.0.0.1", "ec2.public_dns": "ec2-54-0-0-1.compute.amazonaws.com",
+				"ec2.private_ip": "10.0.0.1", "ec2.private_dns": "ip-10-0-0-1.ec2.internal", "ec2.tag.Role": "app"}},
+			{Host: "ip-10-1-0-1.eu-west-1.compute.internal", IP: "10.1.0.1", Labels: map[string]string{
+				"region": "eu-west-1", "ec2.instance_id": "i-3",
+				"ec2.private_ip": "10.1.0.1", "ec2.private_dns": "ip-10-1-0-1.eu-west-1.compute.internal",
+				"ec2.tag.Role": "app", "ec2.tag.Env": "prod"}},
+		}, mustDiscover(t, d, "tag:Role=app"))
+	})
+	r.AssertExpectations(t)
+}
+
+func TestEc2IgnoresOtherInputs(t *testing.T) {
+	d, r := givenAMockedEc2Search("us-east-1")
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("Host lookup string is not a list of tag:KEY=VALUE and asg:GROUP terms, it won't match anything in EC2")
+		target.AssertTargetListEquals(t, []target.Target{}, mustDiscover(t, d, "web01.example.com"))
+	})
+	r.AssertExpectations(t)
+}
+
+func TestEc2Errors(t *testing.T) {
+	d, r := givenAMockedEc2Search("us-east-1", "eu-west-1")
+	filters := []string{"Name=tag:aws:autoscaling:groupName,Values=web", "Name=instance-state-name,Values=running"}
+	r.On("Outputs", "aws", ec2DescribeInstancesArgs("us-east-1", filters...)).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(`{"Reservations": []}`)}).Times(1)
+	r.On("Outputs", "aws", ec2DescribeInstancesArgs("eu-west-1", filters...)).
+		Return(util.CommandRunnerOutputs{Error: errors.New("exit status 255"), Combined: []byte("Unable to locate credentials")}).Times(1)
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectInfof("Looking up EC2 instances matching %s in %s", "asg:web", "[us-east-1 eu-west-1]")
+		_, err := d.Discover("asg:web")
+		util.ExpectError(t, "[aws ec2 describe-instances --region eu-west-1 --output json --filters "+
+			"Name=tag:aws:autoscaling:groupName,Values=web Name=instance-state-name,Values=running] failed: exit status 255\n"+
+			"Output:\nUnable to locate credentials", err)
+	})
+	r.AssertExpectations(t)
+}
@@ -0,0 +1,408 @@
+package discoverers
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// expandDefaultLimit is the number of targets one expansion may produce, unless configured otherwise
+const expandDefaultLimit = 1024
+
+var (
+	expandBraceNumbers  = regexp.MustCompile(`^(-?\d+)\.\.(-?\d+)(?:\.\.(\d+))?$`)
+	expandBraceLetters  = regexp.MustCompile(`^([a-zA-Z])\.\.([a-zA-Z])$`)
+	expandBracketItem   = regexp.MustCompile(`^(\d+)(?:-(\d+))?$|^([a-zA-Z])-([a-zA-Z])$`)
+	expandLeadingZeroes = regexp.MustCompile(`^-?0\d`)
+)
+
+/*
+expandChoices is a list of strings that is counted before it's built, so that a huge range like web[1-30000000] can be
+rejected without building it
+*/
+type expandChoices struct {
+	count int
+	build func() []string
+}
+
+func literalChoice(s string) expandChoices {
+	return expandChoices{count: 1, build: func() []string { return []string{s} }}
+}
+
+// errTooManyTargets reports that something expands to more than limit targets
+func errTooManyTargets(limit int) error {
+	return fmt.Errorf("expands to more than %d targets", limit)
+}
+
+// numberChoices returns the numbers from start to end, zero-padded if either of them is
+func numberChoices(start, end string, step int, limit int) (expandChoices, error) {
+	from, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return expandChoices{}, err
+	}
+	to, err := strconv.ParseInt(end, 10, 64)
+	if err != nil {
+		return expandChoices{}, err
+	}
+	width := 0
+	if expandLeadingZeroes.MatchString(start) || expandLeadingZeroes.MatchString(end) {
+		width = len(start)
+		if len(end) > width {
+			width = len(end)
+		}
+	}
+	// Unsigned, so that the distance between the most negative and the most positive numbers doesn't overflow
+	distance := uint64(to) - uint64(from)
+	if from > to {
+		distance = uint64(from) - uint64(to)
+		step = -step
+	}
+	stepSize := uint64(step)
+	if step < 0 {
+		stepSize = uint64(-step)
+	}
+	if distance/stepSize >= uint64(limit) {
+		return expandChoices{}, errTooManyTargets(limit)
+	}
+	count := int(distance/stepSize) + 1
+	return expandChoices{count: count, build: func() []string {
+		numbers := make([]string, count)
+		for i := range numbers {
+			numbers[i] = fmt.Sprintf("%0*d", width, from+int64(i)*int64(step))
+		}
+		return numbers
+	}}, nil
+}
+
+func letterChoices(start, end byte) expandChoices {
+	step, count := 1, int(end)-int(start)+1
+	if start > end {
+		step, count = -1, int(start)-int(end)+1
+	}
+	return expandChoices{count: count, build: func() []string {
+		letters := make([]string, count)
+		for i := range letters {
+			letters[i] = string(rune(int(start) + i*step))
+		}
+		return letters
+	}}
+}
+
+// concatChoices lists the choices of all of lists, one after the other
+func concatChoices(lists []expandChoices, limit int) (expandChoices, error) {
+	count := 0
+	for _, list := range lists {
+		if count += list.count; count > limit {
+			return expandChoices{}, errTooManyTargets(limit)
+		}
+	}
+	return expandChoices{count: count, build: func() []string {
+		var choices []string
+		for _, list := range lists {
+			choices = append(choices, list.build()...)
+		}
+		return choices
+	}}, nil
+}
+
+// splitTopLevel splits s at the separator characters that are not inside {} or []
+func splitTopLevel(s string, separators string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch {
+		case c == '{' || c == '[':
+			depth++
+		case (c == '}' || c == ']') && depth > 0:
+			depth--
+		case depth == 0 && strings.ContainsRune(separators, c):
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// closingIndex returns the index of the bracket closing the one at s[0], or -1
+func closingIndex(s string) int {
+	open, close := s[0], byte('}')
+	if open == '[' {
+		close = ']'
+	}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case open:
+			depth++
+		case close:
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+/*
+braceChoices parses the content of {}: a sequence like 1..3, 01..10..2 or a..c, or alternatives like app,db that are
+patterns themselves. Returns false if content is neither, so the braces are kept as they are.
+*/
+func braceChoices(content string, limit int) (expandChoices, bool, error) {
+	if m := expandBraceNumbers.FindStringSubmatch(content); m != nil {
+		step := 1
+		if m[3] != "" {
+			step, _ = strconv.Atoi(m[3])
+		}
+		if step == 0 {
+			return expandChoices{}, false, fmt.Errorf("invalid step 0 in {%s}", content)
+		}
+		choices, err := numberChoices(m[1], m[2], step, limit)
+		return choices, true, err
+	}
+	if m := expandBraceLetters.FindStringSubmatch(content); m != nil {
+		return letterChoices(m[1][0], m[2][0]), true, nil
+	}
+	alternatives := splitTopLevel(content, ",")
+	if len(alternatives) == 1 {
+		return expandChoices{}, false, nil
+	}
+	lists := make([]expandChoices, len(alternatives))
+	for i, alternative := range alternatives {
+		var err error
+		if lists[i], err = parsePattern(alternative, limit); err != nil {
+			return expandChoices{}, false, err
+		}
+	}
+	choices, err := concatChoices(lists, limit)
+	return choices, true, err
+}
+
+/*
+bracketChoices parses the content of []: a comma-separated list of numbers, number ranges like 01-12, and letter ranges
+like a-f. Returns false if content is something else, like an IPv6 address, so the brackets are kept as they are.
+*/
+func bracketChoices(content string, limit int) (expandChoices, bool, error) {
+	var lists []expandChoices
+	for _, item := range strings.Split(content, ",") {
+		m := expandBracketItem.FindStringSubmatch(item)
+		switch {
+		case m == nil:
+			return expandChoices{}, false, nil
+		case m[3] != "":
+			lists = append(lists, letterChoices(m[3][0], m[4][0]))
+		case m[2] == "":
+			lists = append(lists, literalChoice(m[1]))
+		default:
+			numbers, err := numberChoices(m[1], m[2], 1, limit)
+			if err != nil {
+				return expandChoices{}, false, err
+			}
+			lists = append(lists, numbers)
+		}
+	}
+	choices, err := concatChoices(lists, limit)
+	return choices, true, err
+}
+
+/*
+parsePattern parses the ranges, sequences and alternatives in pattern. It expands to the cartesian product of their
+choices, which is counted first, and rejected if it's larger than limit.
+*/
+func parsePattern(pattern string, limit int) (expandChoices, error) {
+	var literals []string
+	var groups []expandChoices
+	count := 1
+	rest := pattern
+	for rest != "" {
+		i := strings.IndexAny(rest, "{[")
+		if i == -1 {
+			break
+		}
+		end := closingIndex(rest[i:])
+		if end == -1 {
+			return expandChoices{}, fmt.Errorf("missing closing bracket for %c at %s", rest[i], rest[i:])
+		}
+		group, content := rest[i:i+end+1], rest[i+1:i+end]
+		var choices expandChoices
+		var ok bool
+		var err error
+		if group[0] == '{' {
+			choices, ok, err = braceChoices(content, limit)
+		} else {
+			choices, ok, err = bracketChoices(content, limit)
+		}
+		if err != nil {
+			return expandChoices{}, err
+		}
+		if !ok {
+			choices = literalChoice(group)
+		}
+		if count > limit/choices.count {
+			return expandChoices{}, errTooManyTargets(limit)
+		}
+		count *= choices.count
+		literals = append(literals, rest[:i])
+		groups = append(groups, choices)
+		rest = rest[i+end+1:]
+	}
+	return expandChoices{count: count, build: func() []string {
+		results := []string{""}
+		for i, group := range groups {
+			choices := group.build()
+			product := make([]string, 0, len(results)*len(choices))
+			for _, result := range results {
+				for _, choice := range choices {
+					product = append(product, result+literals[i]+choice)
+				}
+			}
+			results = product
+		}
+		for i := range results {
+			results[i] += rest
+		}
+		return results
+	}}, nil
+}
+
+// expandPattern expands the ranges, sequences and alternatives in pattern, failing if that's more than limit strings
+func expandPattern(pattern string, limit int) ([]string, error) {
+	choices, err := parsePattern(pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	return choices.build(), nil
+}
+
+/*
+expandCIDR returns the addresses in s if it's a CIDR block, optionally prefixed with user@, or s itself otherwise.
+The network and broadcast addresses of IPv4 blocks larger than /31 are left out.
+*/
+func expandCIDR(s string, limit int) ([]string, error) {
+	user := ""
+	block := s
+	if at := strings.LastIndex(s, "@"); at != -1 {
+		user, block = s[:at+1], s[at+1:]
+	}
+	_, network, err := net.ParseCIDR(block)
+	if err != nil {
+		return []string{s}, nil
+	}
+	ones, bits := network.Mask.Size()
+	hostBits := uint(bits - ones)
+	skipEnds := bits == 32 && hostBits >= 2
+	count := new(big.Int).Lsh(big.NewInt(1), hostBits)
+	if skipEnds {
+		count.Sub(count, big.NewInt(2))
+	}
+	if count.Cmp(big.NewInt(int64(limit))) > 0 {
+		return nil, fmt.Errorf("%s expands to more than %d targets", block, limit)
+	}
+	ip := new(big.Int).SetBytes(network.IP)
+	if skipEnds {
+		ip.Add(ip, big.NewInt(1))
+	}
+	addresses := make([]string, 0, count.Int64())
+	for i := int64(0); i < count.Int64(); i++ {
+		bytes := ip.Bytes()
+		padded := make(net.IP, len(network.IP))
+		copy(padded[len(padded)-len(bytes):], bytes)
+		addresses = append(addresses, user+padded.String())
+		ip.Add(ip, big.NewInt(1))
+	}
+	return addresses, nil
+}
+
+type expand struct {
+	args  []interface{}
+	limit int
+}
+
+/*
+expandHosts expands input into host names. Patterns are separated by commas or whitespace; after a !, a pattern
+excludes the hosts it expands to, like web[01-12]!web07 or web[01-12],!web07.
+*/
+func (d *expand) expandHosts(input string) ([]string, error) {
+	var included, excluded []string
+	for _, term := range splitTopLevel(input, ", \t\n") {
+		for i, pattern := range splitTopLevel(term, "!") {
+			if pattern == "" {
+				continue
+			}
+			hosts, err := expandPattern(pattern, d.limit)
+			if err != nil {
+				return nil, &util.InvalidTargetError{Input: pattern, Msg: err.Error()}
+			}
+			for _, host := range hosts {
+				addresses, err := expandCIDR(host, d.limit)
+				if err != nil {
+					return nil, &util.InvalidTargetError{Input: pattern, Msg: err.Error()}
+				}
+				if i == 0 {
+					included = append(included, addresses...)
+				} else {
+					excluded = append(excluded, addresses...)
+				}
+			}
+			if len(included) > d.limit {
+				return nil, &util.InvalidTargetError{Input: input, Msg: fmt.Sprintf("expands to more than %d targets", d.limit)}
+			}
+		}
+	}
+
+	skip := map[string]bool{}
+	for _, host := range excluded {
+		skip[host] = true
+	}
+	hosts := []string{}
+	for _, host := range included {
+		if !skip[host] {
+			hosts = append(hosts, host)
+			skip[host] = true
+		}
+	}
+	return hosts, nil
+}
+
+func (d *expand) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtMost(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	hosts, err := d.expandHosts(input)
+	if err != nil {
+		return nil, err
+	}
+	return target.FromStrings(hosts...)
+}
+
+func (d *expand) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtMost(d, 1, args); err != nil {
+		return err
+	}
+	d.limit = expandDefaultLimit
+	if len(args) == 1 {
+		str, err := util.StringArg(args[0])
+		if err != nil {
+			return err
+		}
+		limit, err := strconv.Atoi(str)
+		if err != nil || limit < 1 {
+			return util.ParseErrorf("%s expects a positive number as the maximum number of targets, got %s", nameExpand, str)
+		}
+		d.limit = limit
+	}
+	d.args = args
+	return nil
+}
+
+func (d *expand) String() string {
+	if len(d.args) == 0 {
+		return fmt.Sprintf("<%s>", nameExpand)
+	}
+	return fmt.Sprintf("<%s %d>", nameExpand, d.limit)
+}
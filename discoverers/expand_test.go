@@ -0,0 +1,91 @@
+package discoverers
+
+import (
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+func TestExpandMakeWithTooManyArguments(t *testing.T) {
+	_, err := Make("(expand 10 20)")
+	util.ExpectError(t, "<expand> takes at most 1 argument(s), got 2: [10 20] in (expand 10 20) at position 0", err)
+}
+
+func TestExpandMakeWithInvalidLimit(t *testing.T) {
+	_, err := Make("(expand lots)")
+	util.ExpectError(t, "expand expects a positive number as the maximum number of targets, got lots in (expand lots) at position 0", err)
+}
+
+func TestExpandString(t *testing.T) {
+	if s := mustMake(t, "(expand)").String(); s != "<expand>" {
+		t.Error(s)
+	}
+	if s := mustMake(t, "(expand 16)").String(); s != "<expand 16>" {
+		t.Error(s)
+	}
+}
+
+func TestExpandOperation(t *testing.T) {
+	d := mustMake(t, "(expand)")
+	cases := []struct {
+		input          string
+		expectedOutput []string
+	}{
+		{"", []string{}},
+		{"foo", []string{"foo"}},
+		{"alpha,beta gamma", []string{"alpha", "beta", "gamma"}},
+		{"web[01-03]", []string{"web01", "web02", "web03"}},
+		{"web[8-10]", []string{"web8", "web9", "web10"}},
+		{"web[1,3,5-6]", []string{"web1", "web3", "web5", "web6"}},
+		{"rack[a-c]", []string{"racka", "rackb", "rackc"}},
+		{"{app,db}{1..2}.dc1", []string{"app1.dc1", "app2.dc1", "db1.dc1", "db2.dc1"}},
+		{"{01..10..3}", []string{"01", "04", "07", "10"}},
+		{"{3..1}", []string{"3", "2", "1"}},
+		{"{web{1..2},db}", []string{"web1", "web2", "db"}},
+		{"{app,db[1-2]}.example.com", []string{"app.example.com", "db1.example.com", "db2.example.com"}},
+		{"root@web[1-2]:2222", []string{"root@web1:2222", "root@web2:2222"}},
+		{"web[01-05]!web03", []string{"web01", "web02", "web04", "web05"}},
+		{"web[01-05]!web0[2-3],!web05", []string{"web01", "web04"}},
+		{"web1,web2,web1", []string{"web1", "web2"}},
+		{"{single}", []string{"{single}"}},
+		{"[fe80::1]:22", []string{"[fe80::1]:22"}},
+		{"10.0.3.0/29", []string{"10.0.3.1", "10.0.3.2", "10.0.3.3", "10.0.3.4", "10.0.3.5", "10.0.3.6"}},
+		{"root@10.0.3.8/31", []string{"root@10.0.3.8", "root@10.0.3.9"}},
+		{"10.0.{1,2}.0/30!10.0.2.2", []string{"10.0.1.1", "10.0.1.2", "10.0.2.1"}},
+		{"fd00::/127", []string{"fd00::", "fd00::1"}},
+	}
+
+	for _, c := range cases {
+		target.AssertTargetListEquals(t, target.MustFromStrings(c.expectedOutput...), mustDiscover(t, d, c.input))
+	}
+}
+
+func TestExpandLimit(t *testing.T) {
+	d := mustMake(t, "(expand 4)")
+	target.AssertTargetListEquals(t, target.MustFromStrings("a1", "a2", "a4"), mustDiscover(t, d, "a[1-4]!a3"))
+
+	cases := []struct {
+		input         string
+		expectedError string
+	}{
+		{"{a,b}[1-3]", `Invalid target "{a,b}[1-3]": expands to more than 4 targets`},
+		{"10.0.0.0/24", `Invalid target "10.0.0.0/24": 10.0.0.0/24 expands to more than 4 targets`},
+		{"fd00::/64", `Invalid target "fd00::/64": fd00::/64 expands to more than 4 targets`},
+		{"a[1-3],b[1-3]", `Invalid target "a[1-3],b[1-3]": expands to more than 4 targets`},
+		{"web[1-30000000]", `Invalid target "web[1-30000000]": expands to more than 4 targets`},
+		{"{-9223372036854775808..9223372036854775807}", `Invalid target "{-9223372036854775808..9223372036854775807}": expands to more than 4 targets`},
+		{"[1-2][1-2][1-2][1-99999999]", `Invalid target "[1-2][1-2][1-2][1-99999999]": expands to more than 4 targets`},
+		{"{a[1-3],b[1-3]}", `Invalid target "{a[1-3],b[1-3]}": expands to more than 4 targets`},
+	}
+	for _, c := range cases {
+		_, err := d.Discover(c.input)
+		util.ExpectError(t, c.expectedError, err)
+	}
+}
+
+func TestExpandUnbalancedBrackets(t *testing.T) {
+	d := mustMake(t, "(expand)")
+	_, err := d.Discover("web{1..3")
+	util.ExpectError(t, `Invalid target "web{1..3": missing closing bracket for { at {1..3`, err)
+}
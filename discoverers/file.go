@@ -0,0 +1,299 @@
+package discoverers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// hostListFields are the column names of CSV host lists, and the keys of JSON ones, that set Target fields
+var hostListFields = map[string]bool{"host": true, "hostname": true, "ip": true, "user": true, "port": true, "proxy_jump": true}
+
+/*
+hostListTarget makes a target of the fields of a CSV row or JSON object. Fields named like the JSON fields of Target
+set those; other fields become labels with the same name.
+*/
+func hostListTarget(fields map[string]string) (target.Target, error) {
+	var t target.Target
+	for key, value := range fields {
+		switch strings.ToLower(key) {
+		case "host":
+			t.Host = value
+		case "hostname":
+			t.Hostname = value
+		case "ip":
+			t.IP = value
+		case "user":
+			t.User = value
+		case "port":
+			if value == "" {
+				continue
+			}
+			port, err := strconv.Atoi(value)
+			if err != nil || port < 1 || port > 65535 {
+				return t, fmt.Errorf("invalid port \"%s\"", value)
+			}
+			t.Port = port
+		case "proxy_jump":
+			if value != "" {
+				t.ProxyJump = strings.Split(value, ",")
+			}
+		default:
+			t.SetLabel(key, value)
+		}
+	}
+	if t.Host == "" && t.IP == "" && t.Hostname != "" {
+		t.Host = t.Hostname
+	}
+	if t.IsEmpty() {
+		return t, fmt.Errorf("at least one of host, hostname and ip must be set")
+	}
+	return t, nil
+}
+
+// isCSVHostListHeader tells whether line is the header of a CSV host list: at least one column is a Target field
+func isCSVHostListHeader(line string) bool {
+	columns := strings.Split(line, ",")
+	if len(columns) < 2 {
+		return false
+	}
+	for _, column := range columns {
+		if hostListFields[strings.ToLower(strings.TrimSpace(column))] {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCSVHostList(data []byte) ([]target.Target, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	targets := []target.Target{}
+	for i, row := range rows[1:] {
+		fields := map[string]string{}
+		for column, value := range row {
+			fields[strings.TrimSpace(rows[0][column])] = strings.TrimSpace(value)
+		}
+		t, err := hostListTarget(fields)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %s", i+1, err)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// jsonHostListValue turns a value of a JSON host list entry into a string, like it would be written in a CSV column
+func jsonHostListValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("expected a list of strings, got %v", v)
+			}
+			values[i] = s
+		}
+		return strings.Join(values, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
+
+/*
+parseJSONHostList parses a JSON array of target strings, or of objects with the fields of Target. Other fields of the
+objects, and the fields of a "labels" object, become labels.
+*/
+func parseJSONHostList(data []byte) ([]target.Target, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var entries []interface{}
+	if err := decoder.Decode(&entries); err != nil {
+		return nil, err
+	}
+	targets := []target.Target{}
+	for i, entry := range entries {
+		var t target.Target
+		var err error
+		switch e := entry.(type) {
+		case string:
+			t, err = target.FromString(e)
+		case map[string]interface{}:
+			fields := map[string]string{}
+			for key, value := range e {
+				if labels, ok := value.(map[string]interface{}); ok && key == "labels" {
+					for label, labelValue := range labels {
+						if fields[label], err = jsonHostListValue(labelValue); err != nil {
+							break
+						}
+					}
+				} else {
+					fields[key], err = jsonHostListValue(value)
+				}
+				if err != nil {
+					err = fmt.Errorf("%s: %s", key, err)
+					break
+				}
+			}
+			if err == nil {
+				t, err = hostListTarget(fields)
+			}
+		default:
+			err = fmt.Errorf("expected a string or an object, got %v", entry)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %s", i+1, err)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+/*
+parseLineHostList parses one target per line, like [user@]host[:port]. A second word on a line is the user, like in
+pssh hosts files.
+*/
+func parseLineHostList(lines []string) ([]target.Target, error) {
+	targets := []target.Target{}
+	for i, line := range lines {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		if len(words) > 2 {
+			return nil, fmt.Errorf("line %d: expected [user@]host[:port] [user], got %s", i+1, line)
+		}
+		t, err := target.FromString(words[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err)
+		}
+		if len(words) == 2 && t.User == "" {
+			t.User = words[1]
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// stripHostListComments removes everything after a # at the start of a line or after whitespace
+func stripHostListComments(data []byte) []string {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		for j := 0; j < len(line); j++ {
+			if line[j] == '#' && (j == 0 || line[j-1] == ' ' || line[j-1] == '\t') {
+				line = line[:j]
+				break
+			}
+		}
+		lines[i] = strings.TrimSpace(line)
+	}
+	return lines
+}
+
+// parseHostList detects the format of a host list: a JSON array, CSV with a header, or one target per line
+func parseHostList(data []byte) ([]target.Target, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) && json.Valid(trimmed) {
+		return parseJSONHostList(trimmed)
+	}
+	lines := stripHostListComments(data)
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		if isCSVHostListHeader(line) {
+			return parseCSVHostList(data)
+		}
+		break
+	}
+	return parseLineHostList(lines)
+}
+
+type hostFile struct {
+	args      []interface{}
+	path      string
+	stdin     io.Reader
+	stdinData []byte
+}
+
+// read reads the host list at path, or stdin if path is -. Stdin is read only once, later calls reuse its contents.
+func (d *hostFile) read(path string) ([]byte, error) {
+	if path != "-" {
+		return ioutil.ReadFile(expandHome(path))
+	}
+	if d.stdinData == nil {
+		data, err := ioutil.ReadAll(d.stdin)
+		if err != nil {
+			return nil, err
+		}
+		d.stdinData = data
+	}
+	return d.stdinData, nil
+}
+
+func (d *hostFile) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtMost(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	path := d.path
+	if path == "" {
+		path = input
+	}
+	name := path
+	if path == "-" {
+		name = "stdin"
+	}
+	data, err := d.read(path)
+	if err != nil {
+		return nil, util.ConfigErrorf("Failed to read host list: %s", err)
+	}
+	targets, err := parseHostList(data)
+	if err != nil {
+		return nil, util.ConfigErrorf("Failed to parse host list %s: %s", name, err)
+	}
+	util.Logger.Debugf("Read %d targets from %s", len(targets), name)
+	return targets, nil
+}
+
+func (d *hostFile) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtMost(d, 1, args); err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		path, err := util.StringArg(args[0])
+		if err != nil {
+			return err
+		}
+		d.path = path
+	}
+	d.args = args
+	return nil
+}
+
+func (d *hostFile) String() string {
+	if d.path == "" {
+		return fmt.Sprintf("<%s>", nameFile)
+	}
+	return fmt.Sprintf("<%s %s>", nameFile, d.path)
+}
@@ -0,0 +1,104 @@
+package discoverers
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+func TestFileStringViaMake(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("MakeFromString %s -> %s", "(file)", "[file]")
+		l.ExpectDebugf("Make %s -> %s", "[file]", "<file>")
+		mustMake(t, "(file)")
+		l.ExpectDebugf("MakeFromString %s -> %s", "(file -)", "[file -]")
+		l.ExpectDebugf("Make %s -> %s", "[file -]", "<file ->")
+		mustMake(t, "(file -)")
+	})
+}
+
+func TestFileMakeWithTooManyArguments(t *testing.T) {
+	_, err := Make("(file a b)")
+	util.ExpectError(t, "<file> takes at most 1 argument(s), got 2: [a b] in (file a b) at position 0", err)
+}
+
+func TestFileFormats(t *testing.T) {
+	withLabels := func(t target.Target, labels map[string]string) target.Target {
+		t.Labels = labels
+		return t
+	}
+	cases := []struct {
+		name     string
+		contents string
+		expected []target.Target
+	}{
+		{"empty", "\n# nothing here\n", []target.Target{}},
+		{"lines", "# web servers\nweb1\n\nroot@web2:2222  # the odd one\n[fe80::1]:22\n",
+			target.MustFromStrings("web1", "root@web2:2222", "[fe80::1]:22")},
+		{"pssh", "web1:2222 deploy\nadmin@web2 deploy\n", target.MustFromStrings("deploy@web1:2222", "admin@web2")},
+		{"csv", "# exported\nhost, user, port, region\nweb1,deploy,2222,eu-west-1\nweb2,,,\n", []target.Target{
+			withLabels(target.MustFromString("deploy@web1:2222"), map[string]string{"region": "eu-west-1"}),
+			target.MustFromString("web2"),
+		}},
+		{"json strings", `["web1", "root@web2:2222"]`, target.MustFromStrings("web1", "root@web2:2222")},
+		{"json objects", `[
+			{"ip": "10.0.0.1", "hostname": "web1", "port": 2222, "proxy_jump": ["bastion"], "labels": {"env": "prod"}},
+			{"host": "web2", "role": "web", "primary": true}
+		]`, []target.Target{
+			{IP: "10.0.0.1", Hostname: "web1", Port: 2222, ProxyJump: []string{"bastion"}, Labels: map[string]string{"env": "prod"}},
+			{Host: "web2", Labels: map[string]string{"role": "web", "primary": "true"}},
+		}},
+	}
+	for _, c := range cases {
+		withTempFiles(t, map[string]string{"hosts": c.contents}, func(dir string) {
+			d := mustMake(t, "(file)")
+			target.AssertTargetListEquals(t, c.expected, mustDiscover(t, d, filepath.Join(dir, "hosts")))
+		})
+	}
+}
+
+func TestFileCSVWithProxyJump(t *testing.T) {
+	withTempFiles(t, map[string]string{"hosts.csv": "ip,hostname,proxy_jump\n10.0.0.1,web1,\"bastion1,bastion2\"\n,web2,\n"}, func(dir string) {
+		d := mustMake(t, "(file "+filepath.Join(dir, "hosts.csv")+")")
+		target.AssertTargetListEquals(t, []target.Target{
+			{IP: "10.0.0.1", Hostname: "web1", ProxyJump: []string{"bastion1", "bastion2"}},
+			{Host: "web2", Hostname: "web2"},
+		}, mustDiscover(t, d, "ignored"))
+	})
+}
+
+func TestFileStdin(t *testing.T) {
+	d := &hostFile{stdin: strings.NewReader("web1\nweb2\n")}
+	util.ExpectNoError(t, d.SetArgs([]interface{}{[]byte("-")}))
+	target.AssertTargetListEquals(t, target.MustFromStrings("web1", "web2"), mustDiscover(t, d, "_"))
+	// Stdin can only be read once, the second lookup reuses what was read
+	target.AssertTargetListEquals(t, target.MustFromStrings("web1", "web2"), mustDiscover(t, d, "_"))
+}
+
+func TestFileErrors(t *testing.T) {
+	cases := []struct {
+		contents      string
+		expectedError string
+	}{
+		{"web1\nweb2 deploy extra\n", "Failed to parse host list %s: line 2: expected [user@]host[:port] [user], got web2 deploy extra"},
+		{"web1:http\n", `Failed to parse host list %s: line 1: Invalid target "web1:http": invalid port "http"`},
+		{"host,port\nweb1,ssh\n", `Failed to parse host list %s: row 1: invalid port "ssh"`},
+		{"host,user\nweb1\n", "Failed to parse host list %s: record on line 2: wrong number of fields"},
+		{"user,region\ndeploy,eu\n", "Failed to parse host list %s: row 1: at least one of host, hostname and ip must be set"},
+		{`[{"host": "web1", "labels": {"a": {"b": "c"}}}]`, "Failed to parse host list %s: entry 1: labels: unsupported value map[b:c]"},
+		{`[42]`, "Failed to parse host list %s: entry 1: expected a string or an object, got 42"},
+	}
+	for _, c := range cases {
+		withTempFiles(t, map[string]string{"hosts": c.contents}, func(dir string) {
+			path := filepath.Join(dir, "hosts")
+			_, err := mustMake(t, "(file)").Discover(path)
+			util.ExpectError(t, strings.Replace(c.expectedError, "%s", path, 1), err)
+		})
+	}
+
+	_, err := mustMake(t, "(file)").Discover("/nonexistent/hosts")
+	util.ExpectError(t, "Failed to read host list: open /nonexistent/hosts: no such file or directory", err)
+}
@@ -1,40 +1,259 @@
 package discoverers
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/abesto/easyssh/interfaces"
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
 
+type firstMatchingResult struct {
+	targets []target.Target
+	err     error
+}
+
+// startDiscover runs child in the background. A panicking child is reported as an error, so that it can be skipped.
+func startDiscover(child interfaces.Discoverer, input string) <-chan firstMatchingResult {
+	results := make(chan firstMatchingResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				results <- firstMatchingResult{err: fmt.Errorf("%s panicked: %v", child, r)}
+			}
+		}()
+		targets, err := child.Discover(input)
+		results <- firstMatchingResult{targets, err}
+	}()
+	return results
+}
+
 type firstMatching struct {
 	args     []interface{}
 	children []interfaces.Discoverer
+	// The time each child may take, zero for no limit
+	timeouts   []time.Duration
+	concurrent bool
+	// The command runners of each child, which cancel their lookups
+	runners []*util.CancelableCommandRunner
+	// The runner of the first-matching this one is a child of, if any, whose lookup cancels the lookups of this one
+	parent *util.CancelableCommandRunner
+}
+
+func (d *firstMatching) name() string {
+	if d.concurrent {
+		return nameFirstMatchingConcurrent
+	}
+	return nameFirstMatching
 }
 
-func (d *firstMatching) Discover(input string) []target.Target {
-	util.RequireArgumentsAtLeast(d, 1, d.args)
-	var targets []target.Target
-	for _, discoverer := range d.children {
-		util.Logger.Debugf("Trying discoverer %s", discoverer)
-		targets = discoverer.Discover(input)
-		if len(targets) > 0 {
-			break
+// replaceableCommandRunner is implemented by the components whose command runner cancelWith can replace
+type replaceableCommandRunner interface {
+	interfaces.UsesCommandRunner
+	CommandRunner() util.CommandRunner
+}
+
+/*
+cancelWith makes component and its children run their commands, like knife, with r, so that they can be killed. It's
+called once, when the components are made, so that running lookups never see their runners replaced. Cached components
+are left alone, so that their results can still be refreshed in the background; their lookups can't be cancelled.
+*/
+func cancelWith(component interface{}, r *util.CancelableCommandRunner) {
+	switch c := component.(type) {
+	case *firstMatching:
+		c.parent = r
+		return
+	case *cached:
+		return
+	case replaceableCommandRunner:
+		// Other runners, like the ones of tests, can't be cancelled
+		if _, ok := c.CommandRunner().(util.RealCommandRunner); ok {
+			c.SetCommandRunner(r)
+		}
+	}
+	if parent, ok := component.(interfaces.HasChildren); ok {
+		for _, child := range parent.Children() {
+			cancelWith(child, r)
 		}
 	}
-	return targets
 }
 
-func (d *firstMatching) SetArgs(args []interface{}) {
-	util.RequireArgumentsAtLeast(d, 1, args)
-	d.args = args
+// prepare makes the lookup of the child at index i cancelled by cancels[i], and by the lookup of the parent, if any
+func (d *firstMatching) prepare(i int, cancels []context.CancelFunc) {
+	parent := context.Background()
+	if d.parent != nil {
+		parent = d.parent.Context()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	cancels[i] = cancel
+	d.runners[i].SetContext(ctx)
+}
+
+// start runs the child at index i in the background, with its lookup cancelled by cancels[i]
+func (d *firstMatching) start(i int, input string, cancels []context.CancelFunc) <-chan firstMatchingResult {
+	d.prepare(i, cancels)
+	return startDiscover(d.children[i], input)
+}
+
+/*
+await waits for the result of the child at index i, until its timeout counted from start. Reports whether the child
+finished in time.
+*/
+func (d *firstMatching) await(i int, results <-chan firstMatchingResult, start time.Time) (firstMatchingResult, bool) {
+	if d.timeouts[i] == 0 {
+		return <-results, true
+	}
+	select {
+	case result := <-results:
+		return result, true
+	case <-time.After(time.Until(start.Add(d.timeouts[i]))):
+		return firstMatchingResult{err: fmt.Errorf("%s timed out after %s", d.children[i], d.timeouts[i])}, false
+	}
+}
+
+func (d *firstMatching) cancelChild(i int, cancels []context.CancelFunc) {
+	util.Logger.Debugf("Cancelling discoverer %s", d.children[i])
+	cancels[i]()
+	cancels[i] = nil
+}
+
+/*
+cancelRest cancels the lookups of the children that are left, logging the ones that were not waited for and are still
+running. The others only have work left over from their own children, like nested first-matching-concurrent lookups.
+*/
+func (d *firstMatching) cancelRest(running []<-chan firstMatchingResult, finished []bool, cancels []context.CancelFunc) {
+	for i, cancel := range cancels {
+		if cancel == nil {
+			continue
+		}
+		if !finished[i] {
+			select {
+			case <-running[i]:
+			default:
+				util.Logger.Debugf("Cancelling discoverer %s", d.children[i])
+			}
+		}
+		cancel()
+	}
+}
+
+/*
+Discover returns the targets of the first child, in the order they were provided, that finds any. Children that fail
+or time out are logged and skipped; if all of them do, the first error is returned. In concurrent mode all children
+start at once, and the ones after the winner are not waited for. Children that are not waited for are cancelled: the
+commands they run are killed.
+
+Discover can be called again, but not concurrently: children abandoned by a previous call, which are still running
+after their commands were killed, run any further commands with the context of the latest call.
+*/
+func (d *firstMatching) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	running := make([]<-chan firstMatchingResult, len(d.children))
+	finished := make([]bool, len(d.children))
+	cancels := make([]context.CancelFunc, len(d.children))
+	defer d.cancelRest(running, finished, cancels)
+	start := time.Now()
+	if d.concurrent {
+		for i, discoverer := range d.children {
+			util.Logger.Debugf("Trying discoverer %s", discoverer)
+			running[i] = d.start(i, input, cancels)
+		}
+	}
+
+	var firstErr error
+	failed := 0
+	for i, discoverer := range d.children {
+		var result firstMatchingResult
+		switch {
+		case d.concurrent:
+			result, finished[i] = d.await(i, running[i], start)
+		case d.timeouts[i] != 0:
+			util.Logger.Debugf("Trying discoverer %s", discoverer)
+			running[i] = d.start(i, input, cancels)
+			result, finished[i] = d.await(i, running[i], time.Now())
+		default:
+			util.Logger.Debugf("Trying discoverer %s", discoverer)
+			d.prepare(i, cancels)
+			finished[i] = true
+			result.targets, result.err = discoverer.Discover(input)
+		}
+		if !finished[i] && cancels[i] != nil {
+			d.cancelChild(i, cancels)
+		}
+		if result.err != nil {
+			util.Logger.Warningf("%s failed, ignoring it: %s", discoverer, result.err)
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			failed++
+			continue
+		}
+		if len(result.targets) > 0 {
+			return result.targets, nil
+		}
+	}
+	if failed == len(d.children) {
+		return nil, firstErr
+	}
+	return []target.Target{}, nil
+}
+
+/*
+SetArgs takes discoverers, each of which may be preceded by a timeout, like (first-matching 5s (knife) (comma-separated))
+*/
+func (d *firstMatching) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(d, 1, args); err != nil {
+		return err
+	}
 	d.children = []interfaces.Discoverer{}
+	d.timeouts = []time.Duration{}
+	d.runners = []*util.CancelableCommandRunner{}
+	var timeout time.Duration
 	for _, exp := range args {
-		d.children = append(d.children, makeFromSExp(exp.([]interface{})))
+		if duration, err := util.DurationArg(exp); err == nil {
+			if timeout != 0 {
+				return util.ParseErrorf("Expected a discoverer after the timeout %s in %s, got %s", timeout, d.name(), exp)
+			}
+			timeout = duration
+			continue
+		}
+		child, err := makeFromSExp(exp)
+		if err != nil {
+			return err
+		}
+		runner := &util.CancelableCommandRunner{}
+		cancelWith(child, runner)
+		d.children = append(d.children, child)
+		d.timeouts = append(d.timeouts, timeout)
+		d.runners = append(d.runners, runner)
+		timeout = 0
+	}
+	if timeout != 0 {
+		return util.ParseErrorf("Expected a discoverer after the timeout %s in %s", timeout, d.name())
 	}
+	d.args = args
+	return nil
+}
+
+func (d *firstMatching) Children() []interface{} {
+	children := make([]interface{}, len(d.children))
+	for i, child := range d.children {
+		children[i] = child
+	}
+	return children
 }
 
 func (d *firstMatching) String() string {
-	return fmt.Sprintf("<%s %s>", nameFirstMatching, d.children)
+	children := make([]string, len(d.children))
+	for i, child := range d.children {
+		children[i] = child.String()
+		if i < len(d.timeouts) && d.timeouts[i] != 0 {
+			children[i] = fmt.Sprintf("%s %s", d.timeouts[i], child)
+		}
+	}
+	return fmt.Sprintf("<%s %s>", d.name(), children)
 }
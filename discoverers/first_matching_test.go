@@ -3,6 +3,7 @@ package discoverers
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
@@ -19,28 +20,28 @@ func TestFirstMatchingStringViaMake(t *testing.T) {
 		l.ExpectDebugf("Make %s -> %s", "[separated-by ,]", "<separated-by ,>")
 		l.ExpectDebugf("Make %s -> %s", "[separated-by ,]", "<separated-by ,>")
 		l.ExpectDebugf("Make %s -> %s", structs, final)
-		Make(input)
+		mustMake(t, input)
 	})
 }
 
 func TestFirstMatchingMakeWithoutArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(first-matching)", "[first-matching]")
-		util.ExpectPanic(t, "<first-matching []> requires at least 1 argument(s), got 0: []",
-			func() { Make("(first-matching)") })
+		_, err := Make("(first-matching)")
+		util.ExpectError(t, "<first-matching []> requires at least 1 argument(s), got 0: [] in (first-matching) at position 0", err)
 	})
 }
 
 func TestFirstMatchingFilterWithoutSetArgs(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
-		util.ExpectPanic(t, "<first-matching []> requires at least 1 argument(s), got 0: []",
-			func() { (&firstMatching{}).Discover("") })
+		_, err := (&firstMatching{}).Discover("")
+		util.ExpectError(t, "<first-matching []> requires at least 1 argument(s), got 0: []", err)
 	})
 }
 
 func TestFirstMatchingSetArgs(t *testing.T) {
 	input := "(first-matching (comma-separated) (comma-separated))"
-	f := Make(input).(*firstMatching)
+	f := mustMake(t, input).(*firstMatching)
 	if len(f.children) != 2 || f.children[0].String() != "<separated-by ,>" || f.children[1].String() != "<separated-by ,>" {
 		t.Error("children", f.children)
 	}
@@ -50,13 +51,152 @@ func TestFirstMatchingSetArgs(t *testing.T) {
 }
 
 func TestFirstMatchingOperation(t *testing.T) {
-	f := Make("(first-matching (const foo) (const a b) (const c d))").(*firstMatching)
+	f := mustMake(t, "(first-matching (const foo) (const a b) (const c d))").(*firstMatching)
 	// Hack to test skipping a non-matching discoverer
 	f.children[0].(*fixed).retval = []target.Target{}
 
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("Trying discoverer %s", "<fixed []>")
 		l.ExpectDebugf("Trying discoverer %s", "<fixed [a b]>")
-		target.AssertTargetListEquals(t, target.FromStrings("a", "b"), f.Discover("irrelevant string"))
+		target.AssertTargetListEquals(t, target.MustFromStrings("a", "b"), mustDiscover(t, f, "irrelevant string"))
 	})
 }
+
+func TestFirstMatchingChildErrors(t *testing.T) {
+	_, err := Make("(first-matching (fixed a) foo)")
+	util.ExpectError(t, "Expected a definition, got a string: foo in (first-matching (fixed a) foo) at position 0", err)
+}
+
+// slow is a discoverer returning its targets after a delay
+type slow struct {
+	delay   time.Duration
+	targets []target.Target
+}
+
+func (d *slow) Discover(input string) ([]target.Target, error) {
+	time.Sleep(d.delay)
+	return d.targets, nil
+}
+func (d *slow) SetArgs(args []interface{}) error { return nil }
+func (d *slow) String() string                   { return fmt.Sprintf("<slow %s %s>", d.delay, d.targets) }
+
+func TestFirstMatchingTimeouts(t *testing.T) {
+	f := mustMake(t, "(first-matching 5s (const a) (const b))").(*firstMatching)
+	if s := f.String(); s != "<first-matching [5s <fixed [a]> <fixed [b]>]>" {
+		t.Error(s)
+	}
+	f.children[0] = &slow{delay: time.Second, targets: target.MustFromStrings("a")}
+	f.timeouts[0] = 10 * time.Millisecond
+
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("Trying discoverer %s", "<slow 1s [a]>")
+		l.ExpectDebugf("Cancelling discoverer %s", "<slow 1s [a]>")
+		l.ExpectWarningf("%s failed, ignoring it: %s", "<slow 1s [a]>", "<slow 1s [a]> timed out after 10ms")
+		l.ExpectDebugf("Trying discoverer %s", "<fixed [b]>")
+		target.AssertTargetListEquals(t, target.MustFromStrings("b"), mustDiscover(t, f, "irrelevant string"))
+	})
+}
+
+func TestFirstMatchingTimeoutErrors(t *testing.T) {
+	_, err := Make("(first-matching 5s 10s (const a))")
+	util.ExpectError(t, "Expected a discoverer after the timeout 5s in first-matching, got 10s in (first-matching 5s 10s (const a)) at position 0", err)
+	_, err = Make("(first-matching (const a) 5s)")
+	util.ExpectError(t, "Expected a discoverer after the timeout 5s in first-matching in (first-matching (const a) 5s) at position 0", err)
+}
+
+func TestFirstMatchingSkipsErrors(t *testing.T) {
+	f := mustMake(t, "(first-matching (file /nonexistent/hosts) (const a))")
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("Trying discoverer %s", "<file /nonexistent/hosts>")
+		l.ExpectWarningf("%s failed, ignoring it: %s", "<file /nonexistent/hosts>", "Failed to read host list: open /nonexistent/hosts: no such file or directory")
+		l.ExpectDebugf("Trying discoverer %s", "<fixed [a]>")
+		target.AssertTargetListEquals(t, target.MustFromStrings("a"), mustDiscover(t, f, "irrelevant string"))
+	})
+
+	f = mustMake(t, "(first-matching (file /nonexistent/a) (file /nonexistent/b))")
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("Trying discoverer %s", "<file /nonexistent/a>")
+		l.ExpectWarningf("%s failed, ignoring it: %s", "<file /nonexistent/a>", "Failed to read host list: open /nonexistent/a: no such file or directory")
+		l.ExpectDebugf("Trying discoverer %s", "<file /nonexistent/b>")
+		l.ExpectWarningf("%s failed, ignoring it: %s", "<file /nonexistent/b>", "Failed to read host list: open /nonexistent/b: no such file or directory")
+		_, err := f.Discover("irrelevant string")
+		util.ExpectError(t, "Failed to read host list: open /nonexistent/a: no such file or directory", err)
+	})
+}
+
+func TestFirstMatchingConcurrent(t *testing.T) {
+	f := mustMake(t, "(first-matching-concurrent (const a) (const b) (const c))").(*firstMatching)
+	if s := f.String(); s != "<first-matching-concurrent [<fixed [a]> <fixed [b]> <fixed [c]>]>" {
+		t.Error(s)
+	}
+	// The first child finds nothing, the second one is slower than the third, but still wins by priority
+	f.children[0].(*fixed).retval = []target.Target{}
+	f.children[1] = &slow{delay: 50 * time.Millisecond, targets: target.MustFromStrings("b")}
+	f.children[2] = &slow{delay: time.Hour, targets: target.MustFromStrings("c")}
+
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("Trying discoverer %s", "<fixed []>")
+		l.ExpectDebugf("Trying discoverer %s", "<slow 50ms [b]>")
+		l.ExpectDebugf("Trying discoverer %s", "<slow 1h0m0s [c]>")
+		l.ExpectDebugf("Cancelling discoverer %s", "<slow 1h0m0s [c]>")
+		start := time.Now()
+		target.AssertTargetListEquals(t, target.MustFromStrings("b"), mustDiscover(t, f, "irrelevant string"))
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Error("waited for a lower priority child", elapsed)
+		}
+	})
+}
+
+// sleeper is a discoverer running sleep with its command runner, and reporting how that ended
+type sleeper struct {
+	commandRunner util.CommandRunner
+	done          chan error
+}
+
+func (d *sleeper) Discover(input string) ([]target.Target, error) {
+	_, err := d.commandRunner.CombinedOutput("sleep", []string{"60"})
+	d.done <- err
+	return nil, err
+}
+func (d *sleeper) SetArgs(args []interface{}) error      { return nil }
+func (d *sleeper) String() string                        { return "<sleeper>" }
+func (d *sleeper) SetCommandRunner(r util.CommandRunner) { d.commandRunner = r }
+func (d *sleeper) CommandRunner() util.CommandRunner     { return d.commandRunner }
+
+func TestFirstMatchingConcurrentKillsCommands(t *testing.T) {
+	f := mustMake(t, "(first-matching-concurrent (const a) (const b))").(*firstMatching)
+	s := &sleeper{commandRunner: f.runners[1], done: make(chan error, 1)}
+	f.children[1] = s
+	target.AssertTargetListEquals(t, target.MustFromStrings("a"), mustDiscover(t, f, "irrelevant string"))
+	select {
+	case err := <-s.done:
+		// sleep is killed if it started before the lookup was cancelled, and not started at all otherwise
+		if err == nil || (err.Error() != "[sleep 60] failed: signal: killed" && err.Error() != "[sleep 60] failed: context canceled") {
+			t.Error("Unexpected error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("sleep was not killed")
+	}
+}
+
+func TestFirstMatchingReplacesRealCommandRunners(t *testing.T) {
+	f := mustMake(t, "(first-matching 5s (knife) (cached 10m (knife)) (first-matching-concurrent (knife)))").(*firstMatching)
+	if r := f.children[0].(*knifeSearch).commandRunner; r != f.runners[0] {
+		t.Error("runner not replaced", r)
+	}
+	if r := f.children[1].(*cached).child.(*knifeSearch).commandRunner; r != (util.RealCommandRunner{}) {
+		t.Error("runner of cached child replaced", r)
+	}
+	nested := f.children[2].(*firstMatching)
+	if nested.parent != f.runners[2] || nested.children[0].(*knifeSearch).commandRunner != nested.runners[0] {
+		t.Error("nested first-matching not cancelled by its parent")
+	}
+}
+
+func TestFirstMatchingRunsAgainAfterTimeouts(t *testing.T) {
+	f := mustMake(t, "(first-matching 10ms (const a) (const b))").(*firstMatching)
+	f.children[0] = &sleeper{commandRunner: f.runners[0], done: make(chan error, 2)}
+	for i := 0; i < 2; i++ {
+		target.AssertTargetListEquals(t, target.MustFromStrings("b"), mustDiscover(t, f, "irrelevant string"))
+	}
+}
@@ -12,15 +12,28 @@ type fixed struct {
 	retval []target.Target
 }
 
-func (d *fixed) Discover(input string) []target.Target {
-	util.RequireArgumentsAtLeast(d, 1, d.args)
-	return d.retval
+func (d *fixed) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	return d.retval, nil
 }
 
-func (d *fixed) SetArgs(args []interface{}) {
-	util.RequireArgumentsAtLeast(d, 1, args)
+func (d *fixed) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(d, 1, args); err != nil {
+		return err
+	}
+	strs, err := util.ByteToStringArray(args)
+	if err != nil {
+		return err
+	}
+	retval, err := target.FromStrings(strs...)
+	if err != nil {
+		return err
+	}
 	d.args = args
-	d.retval = target.FromStrings(util.ByteToStringArray(args)...)
+	d.retval = retval
+	return nil
 }
 
 func (d *fixed) String() string {
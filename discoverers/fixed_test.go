@@ -13,29 +13,37 @@ func TestFixedStringViaMake(t *testing.T) {
 		l.ExpectDebugf("MakeFromString %s -> %s", input, "[const foo bar]")
 		l.ExpectDebugf("Transform: %s -> %s", "[const foo bar]", "[fixed foo bar]")
 		l.ExpectDebugf("Make %s -> %s", "[fixed foo bar]", "<fixed [foo bar]>")
-		Make(input)
+		mustMake(t, input)
 	})
 }
 
 func TestFixedMakeWithoutArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(fixed)", "[fixed]")
-		util.ExpectPanic(t, "<fixed []> requires at least 1 argument(s), got 0: []",
-			func() { Make("(fixed)") })
+		_, err := Make("(fixed)")
+		util.ExpectError(t, "<fixed []> requires at least 1 argument(s), got 0: [] in (fixed) at position 0", err)
 	})
 }
 
 func TestFixedFilterWithoutSetArgs(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
-		util.ExpectPanic(t, "<fixed []> requires at least 1 argument(s), got 0: []",
-			func() { (&fixed{}).Discover("") })
+		_, err := (&fixed{}).Discover("")
+		util.ExpectError(t, "<fixed []> requires at least 1 argument(s), got 0: []", err)
 	})
 }
 
 func TestFixedOperation(t *testing.T) {
 	input := "(fixed a b c)"
-	d := Make(input).(*fixed)
-	expected := target.FromStrings("a", "b", "c")
+	d := mustMake(t, input).(*fixed)
+	expected := target.MustFromStrings("a", "b", "c")
 	target.AssertTargetListEquals(t, expected, d.retval)
-	target.AssertTargetListEquals(t, expected, d.Discover("foobar"))
+	target.AssertTargetListEquals(t, expected, mustDiscover(t, d, "foobar"))
+}
+
+func TestFixedInvalidTarget(t *testing.T) {
+	_, err := Make("(fixed a a@b@c)")
+	util.ExpectError(t, "Invalid target \"a@b@c\": more than one @ character", err)
+	if util.ExitCode(err) != util.ExitCodeInvalidTarget {
+		t.Errorf("Unexpected exit code %d", util.ExitCode(err))
+	}
 }
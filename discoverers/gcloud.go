@@ -0,0 +1,158 @@
+package discoverers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+type gcloudInstance struct {
+	Name              string
+	Zone              string
+	Status            string
+	Hostname          string
+	Labels            map[string]string
+	NetworkInterfaces []struct {
+		NetworkIP     string `json:"networkIP"`
+		AccessConfigs []struct {
+			NatIP string `json:"natIP"`
+		}
+	}
+}
+
+// lastPathSegment returns the part of a GCP resource URL after the last slash, like the zone name of a zone URL
+func lastPathSegment(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
+
+/*
+gcloudTarget makes a target of instance, preferring its external IP address over its internal one, the same way knife
+prefers public addresses
+*/
+func gcloudTarget(instance gcloudInstance, project string) target.Target {
+	var publicIP, privateIP string
+	for _, networkInterface := range instance.NetworkInterfaces {
+		for _, accessConfig := range networkInterface.AccessConfigs {
+			if publicIP == "" {
+				publicIP = accessConfig.NatIP
+			}
+		}
+		if privateIP == "" {
+			privateIP = networkInterface.NetworkIP
+		}
+	}
+	t := target.Target{IP: publicIP, Hostname: instance.Name}
+	if t.IP == "" {
+		t.IP = privateIP
+	}
+	zone := lastPathSegment(instance.Zone)
+	if dash := strings.LastIndex(zone, "-"); dash != -1 {
+		t.SetLabel("region", zone[:dash])
+	}
+	t.SetLabel("zone", zone)
+	t.SetLabel("gcloud.project", project)
+	t.SetLabel("gcloud.name", instance.Name)
+	t.SetLabel("gcloud.hostname", instance.Hostname)
+	t.SetLabel("gcloud.public_ip", publicIP)
+	t.SetLabel("gcloud.private_ip", privateIP)
+	for key, value := range instance.Labels {
+		t.SetLabel("gcloud.label."+key, value)
+	}
+	return t
+}
+
+type gcloud struct {
+	args          []interface{}
+	projects      []string
+	commandRunner util.CommandRunner
+}
+
+// listInstances lists the instances of project, or only the ones called name if it's not empty
+func (d *gcloud) listInstances(project, name string) ([]gcloudInstance, error) {
+	argv := []string{"compute", "instances", "list", "--format=json"}
+	if name != "" {
+		argv = append(argv, "--filter", fmt.Sprintf("name=%q", name))
+	}
+	if project != "" {
+		argv = append(argv, "--project", project)
+	}
+	outputs := d.commandRunner.Outputs("gcloud", argv)
+	if outputs.Error != nil {
+		return nil, &util.CommandError{Argv: append([]string{"gcloud"}, argv...), Err: outputs.Error, Output: outputs.Combined}
+	}
+	var instances []gcloudInstance
+	if err := json.Unmarshal(outputs.Stdout, &instances); err != nil {
+		return nil, &util.InvalidOutputError{Source: "gcloud compute instances list", Err: err, Output: outputs.Stdout}
+	}
+	return instances, nil
+}
+
+func (d *gcloud) Discover(input string) ([]target.Target, error) {
+	q := parseComputeQuery(input, "label:", "zone:")
+	if q == nil {
+		util.Logger.Debugf("Host lookup string is not a list of names, label:KEY=VALUE and zone:ZONE terms, it won't match anything in GCP")
+		return []target.Target{}, nil
+	}
+	projects := d.projects
+	if len(projects) == 0 {
+		// The default project of gcloud
+		projects = []string{""}
+	}
+
+	util.Logger.Infof("Looking up GCP instances matching %s", input)
+	targets := []target.Target{}
+	for _, project := range projects {
+		instances, err := d.listInstances(project, q.exactName())
+		if err != nil {
+			return nil, err
+		}
+		for _, instance := range instances {
+			if !q.matches(instance.Name, instance.Labels, lastPathSegment(instance.Zone), false) {
+				continue
+			}
+			if instance.Status != "RUNNING" {
+				util.Logger.Infof("GCP instance %s is %s, ignoring", instance.Name, instance.Status)
+				continue
+			}
+			t := gcloudTarget(instance, project)
+			if t.IsEmpty() {
+				util.Logger.Infof("GCP instance %s doesn't have an IP address, ignoring", instance.Name)
+				continue
+			}
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+func (d *gcloud) SetArgs(args []interface{}) error {
+	projects, err := util.ByteToStringArray(args)
+	if err != nil {
+		return err
+	}
+	d.args = args
+	d.projects = projects
+	return util.RequireOnPath(d, "gcloud")
+}
+
+func (d *gcloud) SetCommandRunner(r util.CommandRunner) {
+	d.commandRunner = r
+}
+
+func (d *gcloud) CommandRunner() util.CommandRunner {
+	return d.commandRunner
+}
+
+func (d *gcloud) RequiredBinaries() []string {
+	return []string{"gcloud"}
+}
+
+func (d *gcloud) String() string {
+	if len(d.projects) == 0 {
+		return fmt.Sprintf("<%s>", nameGcloud)
+	}
+	return fmt.Sprintf("<%s %s>", nameGcloud, d.projects)
+}
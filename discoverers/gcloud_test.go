@@ -0,0 +1,111 @@
+package discoverers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+const gcloudInstancesJSON = `[
+  {
+    "name": "web-1", "status": "RUNNING", "hostname": "web-1.example.internal", "labels": {"env": "prod"},
+    "zone": "https://www.googleapis.com/compute/v1/projects/shop/zones/us-central1-a",
+    "networkInterfaces": [{"networkIP": "10.0.0.2", "accessConfigs": [{"natIP": "35.1.1.1"}]}]
+  },
+  {
+    "name": "web-2", "status": "RUNNING", "labels": {"env": "staging"},
+    "zone": "https://www.googleapis.com/compute/v1/projects/shop/zones/europe-west1-b",
+    "networkInterfaces": [{"networkIP": "10.0.0.3", "accessConfigs": []}]
+  },
+  {
+    "name": "web-3", "status": "TERMINATED", "labels": {"env": "prod"},
+    "zone": "https://www.googleapis.com/compute/v1/projects/shop/zones/us-central1-a",
+    "networkInterfaces": [{"networkIP": "10.0.0.4"}]
+  },
+  {"name": "db-1", "status": "RUNNING", "zone": "us-central1-a", "networkInterfaces": [{"networkIP": "10.0.1.2"}]}
+]`
+
+func givenAMockedGcloud(projects ...string) (*gcloud, *util.MockCommandRunner) {
+	r := &util.MockCommandRunner{}
+	return &gcloud{projects: projects, commandRunner: r}, r
+}
+
+func TestGcloudStringViaMake(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("MakeFromString %s -> %s", "(gcloud)", "[gcloud]")
+		l.ExpectDebugf("Make %s -> %s", "[gcloud]", "<gcloud>")
+		mustMake(t, "(gcloud)")
+		l.ExpectDebugf("MakeFromString %s -> %s", "(gcloud shop infra)", "[gcloud shop infra]")
+		l.ExpectDebugf("Make %s -> %s", "[gcloud shop infra]", "<gcloud [shop infra]>")
+		mustMake(t, "(gcloud shop infra)")
+	})
+}
+
+func TestGcloudDiscover(t *testing.T) {
+	d, r := givenAMockedGcloud("shop", "infra")
+	r.On("Outputs", "gcloud", []string{"compute", "instances", "list", "--format=json", "--project", "shop"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(gcloudInstancesJSON)}).Times(1)
+	r.On("Outputs", "gcloud", []string{"compute", "instances", "list", "--format=json", "--project", "infra"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte("[]")}).Times(1)
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectInfof("Looking up GCP instances matching %s", "web-*")
+		l.ExpectInfof("GCP instance %s is %s, ignoring", "web-3", "TERMINATED")
+		target.AssertTargetListEquals(t, []target.Target{
+			{IP: "35.1.1.1", Hostname: "web-1", Labels: map[string]string{
+				"region": "us-central1", "zone": "us-central1-a", "gcloud.project": "shop", "gcloud.name": "web-1",
+				"gcloud.hostname": "web-1.example.internal", "gcloud.public_ip": "35.1.1.1", "gcloud.private_ip": "10.0.0.2",
+				"gcloud.label.env": "prod"}},
+			{IP: "10.0.0.3", Hostname: "web-2", Labels: map[string]string{
+				"region": "europe-west1", "zone": "europe-west1-b", "gcloud.project": "shop", "gcloud.name": "web-2",
+				"gcloud.private_ip": "10.0.0.3", "gcloud.label.env": "staging"}},
+		}, mustDiscover(t, d, "web-*"))
+	})
+	r.AssertExpectations(t)
+}
+
+func TestGcloudDiscoverWithFilters(t *testing.T) {
+	d, r := givenAMockedGcloud()
+	r.On("Outputs", "gcloud", []string{"compute", "instances", "list", "--format=json"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(gcloudInstancesJSON)})
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{"label:env=prod", []string{"web-1"}},
+		{"zone:us-*", []string{"web-1", "db-1"}},
+		{"*,zone:europe-west1-?", []string{"web-2"}},
+		{"db-*,label:env", []string{}},
+	}
+	for _, c := range cases {
+		actual := []string{}
+		for _, discovered := range mustDiscover(t, d, c.input) {
+			actual = append(actual, discovered.Hostname)
+		}
+		util.AssertStringListEquals(t, c.expected, actual)
+	}
+}
+
+func TestGcloudErrors(t *testing.T) {
+	d, r := givenAMockedGcloud("shop")
+	r.On("Outputs", "gcloud", []string{"compute", "instances", "list", "--format=json", "--filter", `name="web-1"`, "--project", "shop"}).
+		Return(util.CommandRunnerOutputs{Error: errors.New("exit status 1"), Combined: []byte("You do not currently have an active account selected.")}).Times(1)
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("Host lookup string is not a list of names, label:KEY=VALUE and zone:ZONE terms, it won't match anything in GCP")
+		target.AssertTargetListEquals(t, []target.Target{}, mustDiscover(t, d, "asg:web"))
+		l.ExpectInfof("Looking up GCP instances matching %s", "web-1")
+		_, err := d.Discover("web-1")
+		util.ExpectError(t, "[gcloud compute instances list --format=json --filter name=\"web-1\" --project shop] failed: exit status 1\n"+
+			"Output:\nYou do not currently have an active account selected.", err)
+	})
+	r.AssertExpectations(t)
+}
+
+func TestGcloudDiscoverExactName(t *testing.T) {
+	d, r := givenAMockedGcloud()
+	r.On("Outputs", "gcloud", []string{"compute", "instances", "list", "--format=json", "--filter", `name="web-1"`}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(gcloudInstancesJSON)}).Times(1)
+	util.AssertStringListEquals(t, []string{"web-1"}, target.FriendlyNames(mustDiscover(t, d, "web-1")))
+	r.AssertExpectations(t)
+}
@@ -3,6 +3,7 @@ package discoverers
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/abesto/easyssh/target"
@@ -19,18 +20,23 @@ type knifeSearchResult struct {
 }
 
 type knifeSearchResultRow struct {
-	Name      string
-	Automatic knifeSearchResultRowAutomatic
+	Name            string
+	ChefEnvironment string `json:"chef_environment"`
+	Automatic       knifeSearchResultRowAutomatic
 }
 
 type knifeSearchResultRowAutomatic struct {
-	CloudV2   *knifeSearchResultCloudV2 `json:"cloud_v2"`
-	Ipaddress string
-	Hostname  string
-	Fqdn      string
+	CloudV2         *knifeSearchResultCloudV2 `json:"cloud_v2"`
+	Ipaddress       string
+	Hostname        string
+	Fqdn            string
+	Roles           []string
+	Platform        string
+	PlatformVersion string `json:"platform_version"`
 }
 
 type knifeSearchResultCloudV2 struct {
+	Provider       string
 	PublicHostname string `json:"public_hostname"`
 	PublicIpv4     string `json:"public_ipv4"`
 	LocalHostname  string `json:"local_hostname"`
@@ -57,26 +63,37 @@ func (e realKnifeSearchResultRowExtractor) Extract(row knifeSearchResultRow) tar
 		target.IP = row.Automatic.Ipaddress
 	}
 	target.Hostname = row.Automatic.Hostname
+	target.SetLabel("chef.node", row.Name)
+	target.SetLabel("chef.environment", row.ChefEnvironment)
+	roles := append([]string{}, row.Automatic.Roles...)
+	sort.Strings(roles)
+	target.SetLabel("chef.roles", strings.Join(roles, ","))
+	target.SetLabel("chef.platform", row.Automatic.Platform)
+	target.SetLabel("chef.platform_version", row.Automatic.PlatformVersion)
+	if row.Automatic.CloudV2 != nil {
+		target.SetLabel("chef.cloud_provider", row.Automatic.CloudV2.Provider)
+	}
 	return target
 }
 
-func (d *knifeSearch) Discover(input string) []target.Target {
+func (d *knifeSearch) Discover(input string) ([]target.Target, error) {
 	var targets []target.Target
 
 	if !strings.Contains(input, ":") {
 		util.Logger.Debugf("Host lookup string doesn't contain ':', it won't match anything in a knife search node query")
-		return targets
+		return targets, nil
 	}
 
 	util.Logger.Infof("Looking up nodes with knife matching %s", input)
-	outputs := d.commandRunner.Outputs("knife", []string{"search", "node", "-F", "json", input})
+	argv := []string{"search", "node", "-F", "json", input}
+	outputs := d.commandRunner.Outputs("knife", argv)
 	if outputs.Error != nil {
-		util.Panicf("Knife lookup failed: %s\nOutput:\n%s", outputs.Error, outputs.Combined)
+		return nil, &util.CommandError{Argv: append([]string{"knife"}, argv...), Err: outputs.Error, Output: outputs.Combined}
 	}
 
 	data := knifeSearchResult{}
 	if err := json.Unmarshal(outputs.Stdout, &data); err != nil {
-		util.Panicf("Failed to parse knife search result: %s", err)
+		return nil, &util.InvalidOutputError{Source: "knife search", Err: err, Output: outputs.Stdout}
 	}
 	//	util.Logger.Debugf("Parsed result into data: %s", data)
 
@@ -89,12 +106,26 @@ func (d *knifeSearch) Discover(input string) []target.Target {
 		}
 	}
 
-	return targets
+	return targets, nil
+}
+
+func (d *knifeSearch) SetArgs(args []interface{}) error {
+	if err := util.RequireNoArguments(d, args); err != nil {
+		return err
+	}
+	return util.RequireOnPath(d, "knife")
+}
+
+func (d *knifeSearch) SetCommandRunner(r util.CommandRunner) {
+	d.commandRunner = r
+}
+
+func (d *knifeSearch) CommandRunner() util.CommandRunner {
+	return d.commandRunner
 }
 
-func (d *knifeSearch) SetArgs(args []interface{}) {
-	util.RequireNoArguments(d, args)
-	util.RequireOnPath(d, "knife")
+func (d *knifeSearch) RequiredBinaries() []string {
+	return []string{"knife"}
 }
 
 func (d *knifeSearch) String() string {
@@ -25,7 +25,7 @@ func TestKnifeStringViaMake(t *testing.T) {
 		util.WithLogAssertions(t, func(l *util.MockLogger) {
 			l.ExpectDebugf("MakeFromString %s -> %s", c.input, c.structs)
 			l.ExpectDebugf("Make %s -> %s", c.structs, c.final)
-			d := Make(c.input)
+			d := mustMake(t, c.input)
 			if d.String() != c.final {
 				t.Error(d)
 			}
@@ -36,7 +36,8 @@ func TestKnifeStringViaMake(t *testing.T) {
 func TestKnifeMakeWithArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(knife foo)", "[knife foo]")
-		util.ExpectPanic(t, "<knife> doesn't take any arguments, got 1: [foo]", func() { Make("(knife foo)") })
+		_, err := Make("(knife foo)")
+		util.ExpectError(t, "<knife> doesn't take any arguments, got 1: [foo] in (knife foo) at position 0", err)
 	})
 }
 
@@ -89,7 +90,7 @@ func TestKnifeNoColonInSearchString(t *testing.T) {
 	input := "no colon at all"
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("Host lookup string doesn't contain ':', it won't match anything in a knife search node query")
-		if len(s.Discover(input)) != 0 {
+		if len(mustDiscover(t, &s, input)) != 0 {
 			t.Fail()
 		}
 	})
@@ -104,7 +105,11 @@ func TestKnifeError(t *testing.T) {
 	whenKnifeSearch(r, input).Return(util.CommandRunnerOutputs{Error: errors.New(err), Combined: []byte("Foo\nBar")}).Times(1)
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectInfof("Looking up nodes with knife matching %s", input)
-		util.ExpectPanic(t, "Knife lookup failed: knife run failed\nOutput:\nFoo\nBar", func() { s.Discover(input) })
+		_, err := s.Discover(input)
+		util.ExpectError(t, "[knife search node -F json name:whatever] failed: knife run failed\nOutput:\nFoo\nBar", err)
+		if util.ExitCode(err) != util.ExitCodeCommand {
+			t.Errorf("Unexpected exit code %d", util.ExitCode(err))
+		}
 	})
 	e.AssertExpectations(t)
 	r.AssertExpectations(t)
@@ -116,9 +121,12 @@ func TestKnifeInvalidJsonFromKnife(t *testing.T) {
 	whenKnifeSearch(r, input).Return(util.CommandRunnerOutputs{Stdout: []byte("Invalid JSON")}).Times(1)
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectInfof("Looking up nodes with knife matching %s", input)
-		util.ExpectPanic(t,
-			"Failed to parse knife search result: invalid character 'I' looking for beginning of value",
-			func() { s.Discover(input) })
+		_, err := s.Discover(input)
+		util.ExpectError(t,
+			"Failed to parse output of knife search: invalid character 'I' looking for beginning of value", err)
+		if util.ExitCode(err) != util.ExitCodeInvalidOutput {
+			t.Errorf("Unexpected exit code %d", util.ExitCode(err))
+		}
 	})
 	e.AssertExpectations(t)
 	r.AssertExpectations(t)
@@ -135,9 +143,9 @@ func TestKnifeHappyPath(t *testing.T) {
 	var actualTargets []target.Target
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectInfof("Looking up nodes with knife matching %s", input)
-		actualTargets = s.Discover(input)
+		actualTargets = mustDiscover(t, &s, input)
 	})
-	expectedTargets := target.FromStrings("alpha.hostname", "beta.hostname", "gamma.hostname")
+	expectedTargets := target.MustFromStrings("alpha.hostname", "beta.hostname", "gamma.hostname")
 	target.AssertTargetListEquals(t, expectedTargets, actualTargets)
 	e.AssertExpectations(t)
 	r.AssertExpectations(t)
@@ -181,6 +189,26 @@ func TestKnifeExtractor(t *testing.T) {
 					Fqdn:      "b.fqdn",
 				}},
 		},
+		{
+			expectedOutput: target.Target{IP: "d.ip", Host: "d.host", Hostname: "d.hostname", Labels: map[string]string{
+				"chef.node": "d", "chef.environment": "prod", "chef.roles": "app,base", "chef.platform": "ubuntu",
+				"chef.platform_version": "22.04", "chef.cloud_provider": "ec2",
+			}},
+			input: knifeSearchResultRow{
+				Name:            "d",
+				ChefEnvironment: "prod",
+				Automatic: knifeSearchResultRowAutomatic{
+					Hostname:        "d.hostname",
+					Roles:           []string{"base", "app"},
+					Platform:        "ubuntu",
+					PlatformVersion: "22.04",
+					CloudV2: &knifeSearchResultCloudV2{
+						Provider:       "ec2",
+						PublicIpv4:     "d.ip",
+						PublicHostname: "d.host",
+					}},
+			},
+		},
 	}
 	for _, c := range cases {
 		e := realKnifeSearchResultRowExtractor{}
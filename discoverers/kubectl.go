@@ -0,0 +1,165 @@
+package discoverers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+type kubectlPodList struct {
+	Items []kubectlPod
+}
+
+type kubectlPod struct {
+	Metadata struct {
+		Name        string
+		Namespace   string
+		Labels      map[string]string
+		Annotations map[string]string
+	}
+	Spec struct {
+		NodeName   string
+		Containers []struct {
+			Name string
+		}
+	}
+	Status struct {
+		Phase string
+		PodIP string
+	}
+}
+
+// kubectlDefaultContainerAnnotation names the container kubectl exec uses when none is given
+const kubectlDefaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// kubectlNamespacePattern matches namespace names, and * for all namespaces
+var kubectlNamespacePattern = regexp.MustCompile(`^(\*|[a-z0-9]([-a-z0-9]*[a-z0-9])?)$`)
+
+/*
+kubectlGetArgs turns a target definition into the arguments of kubectl get pods. Returns nil if input doesn't look like
+something kubectl could find: either pod:[NAMESPACE/]NAME, or [NAMESPACE/]SELECTOR with a label selector containing =.
+NAMESPACE may be * for all namespaces.
+*/
+func kubectlGetArgs(input string) []string {
+	var namespace, selector, pod string
+	if strings.HasPrefix(input, "pod:") {
+		pod = strings.TrimPrefix(input, "pod:")
+		if slash := strings.Index(pod, "/"); slash != -1 {
+			namespace, pod = pod[:slash], pod[slash+1:]
+		}
+		if pod == "" {
+			return nil
+		}
+	} else if strings.Contains(input, "=") {
+		selector = input
+		// Label keys may have a prefix with a slash too, like app.kubernetes.io/name, but those contain dots
+		if slash := strings.Index(input, "/"); slash != -1 && kubectlNamespacePattern.MatchString(input[:slash]) {
+			namespace, selector = input[:slash], input[slash+1:]
+		}
+	} else {
+		return nil
+	}
+
+	args := []string{"get", "pods", "-o", "json"}
+	if namespace == "*" {
+		args = append(args, "--all-namespaces")
+	} else if namespace != "" {
+		args = append(args, "-n", namespace)
+	}
+	if pod != "" {
+		return append(args, "--field-selector", "metadata.name="+pod)
+	}
+	return append(args, "-l", selector)
+}
+
+type kubectl struct {
+	args          []interface{}
+	context       string
+	commandRunner util.CommandRunner
+}
+
+func (d *kubectl) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtMost(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	targets := []target.Target{}
+	argv := kubectlGetArgs(input)
+	if argv == nil {
+		util.Logger.Debugf("Host lookup string is neither pod:NAME nor a label selector, it won't match anything with kubectl")
+		return targets, nil
+	}
+	if d.context != "" {
+		argv = append([]string{"--context", d.context}, argv...)
+	}
+
+	util.Logger.Infof("Looking up pods with kubectl matching %s", input)
+	outputs := d.commandRunner.Outputs("kubectl", argv)
+	if outputs.Error != nil {
+		return nil, &util.CommandError{Argv: append([]string{"kubectl"}, argv...), Err: outputs.Error, Output: outputs.Combined}
+	}
+	var pods kubectlPodList
+	if err := json.Unmarshal(outputs.Stdout, &pods); err != nil {
+		return nil, &util.InvalidOutputError{Source: "kubectl get pods", Err: err, Output: outputs.Stdout}
+	}
+
+	for _, pod := range pods.Items {
+		name := pod.Metadata.Namespace + "/" + pod.Metadata.Name
+		if pod.Status.Phase != "Running" {
+			util.Logger.Infof("Pod %s is %s, ignoring", name, pod.Status.Phase)
+			continue
+		}
+		t := target.Target{Host: pod.Metadata.Name, IP: pod.Status.PodIP}
+		container := pod.Metadata.Annotations[kubectlDefaultContainerAnnotation]
+		if container == "" && len(pod.Spec.Containers) > 0 {
+			container = pod.Spec.Containers[0].Name
+		}
+		t.SetLabel("kubernetes.context", d.context)
+		t.SetLabel("kubernetes.namespace", pod.Metadata.Namespace)
+		t.SetLabel("kubernetes.pod", pod.Metadata.Name)
+		t.SetLabel("kubernetes.container", container)
+		t.SetLabel("kubernetes.node", pod.Spec.NodeName)
+		for key, value := range pod.Metadata.Labels {
+			t.SetLabel("kubernetes.label."+key, value)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func (d *kubectl) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtMost(d, 1, args); err != nil {
+		return err
+	}
+	d.args = args
+	if len(args) == 1 {
+		context, err := util.StringArg(args[0])
+		if err != nil {
+			return err
+		}
+		d.context = context
+	}
+	return util.RequireOnPath(d, "kubectl")
+}
+
+func (d *kubectl) SetCommandRunner(r util.CommandRunner) {
+	d.commandRunner = r
+}
+
+func (d *kubectl) CommandRunner() util.CommandRunner {
+	return d.commandRunner
+}
+
+func (d *kubectl) RequiredBinaries() []string {
+	return []string{"kubectl"}
+}
+
+func (d *kubectl) String() string {
+	if d.context == "" {
+		return fmt.Sprintf("<%s>", nameKubectl)
+	}
+	return fmt.Sprintf("<%s %s>", nameKubectl, d.context)
+}
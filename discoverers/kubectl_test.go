@@ -0,0 +1,119 @@
+package discoverers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+const kubectlPodsJSON = `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {
+      "metadata": {"name": "web-1", "namespace": "shop", "labels": {"app": "web", "tier": "frontend"}},
+      "spec": {"nodeName": "node-a", "containers": [{"name": "app"}, {"name": "proxy"}]},
+      "status": {"phase": "Running", "podIP": "10.1.0.5"}
+    },
+    {
+      "metadata": {
+        "name": "web-2", "namespace": "shop", "labels": {"app": "web"},
+        "annotations": {"kubectl.kubernetes.io/default-container": "proxy"}
+      },
+      "spec": {"nodeName": "node-b", "containers": [{"name": "app"}, {"name": "proxy"}]},
+      "status": {"phase": "Running", "podIP": "10.1.0.6"}
+    },
+    {
+      "metadata": {"name": "web-3", "namespace": "shop", "labels": {"app": "web"}},
+      "spec": {"containers": [{"name": "app"}]},
+      "status": {"phase": "Pending"}
+    }
+  ]
+}`
+
+func givenAMockedKubectl(context string) (*kubectl, *util.MockCommandRunner) {
+	r := &util.MockCommandRunner{}
+	return &kubectl{context: context, commandRunner: r}, r
+}
+
+func TestKubectlStringViaMake(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("MakeFromString %s -> %s", "(kubectl)", "[kubectl]")
+		l.ExpectDebugf("Make %s -> %s", "[kubectl]", "<kubectl>")
+		mustMake(t, "(kubectl)")
+		l.ExpectDebugf("MakeFromString %s -> %s", "(kubectl prod)", "[kubectl prod]")
+		l.ExpectDebugf("Make %s -> %s", "[kubectl prod]", "<kubectl prod>")
+		mustMake(t, "(kubectl prod)")
+	})
+}
+
+func TestKubectlGetArgs(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{"app=web", []string{"get", "pods", "-o", "json", "-l", "app=web"}},
+		{"shop/app=web,tier!=db", []string{"get", "pods", "-o", "json", "-n", "shop", "-l", "app=web,tier!=db"}},
+		{"*/app=web", []string{"get", "pods", "-o", "json", "--all-namespaces", "-l", "app=web"}},
+		{"app.kubernetes.io/name=web", []string{"get", "pods", "-o", "json", "-l", "app.kubernetes.io/name=web"}},
+		{"shop/app.kubernetes.io/name=web", []string{"get", "pods", "-o", "json", "-n", "shop", "-l", "app.kubernetes.io/name=web"}},
+		{"pod:web-1", []string{"get", "pods", "-o", "json", "--field-selector", "metadata.name=web-1"}},
+		{"pod:shop/web-1", []string{"get", "pods", "-o", "json", "-n", "shop", "--field-selector", "metadata.name=web-1"}},
+	}
+	for _, c := range cases {
+		util.AssertStringListEquals(t, c.expected, kubectlGetArgs(c.input))
+	}
+	for _, input := range []string{"web-1.example.com", "pod:", "pod:shop/"} {
+		if args := kubectlGetArgs(input); args != nil {
+			t.Errorf("%s should not be looked up with kubectl, got %s", input, args)
+		}
+	}
+}
+
+func TestKubectlDiscover(t *testing.T) {
+	d, r := givenAMockedKubectl("prod")
+	r.On("Outputs", "kubectl", []string{"--context", "prod", "get", "pods", "-o", "json", "-n", "shop", "-l", "app=web"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte(kubectlPodsJSON)}).Times(1)
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectInfof("Looking up pods with kubectl matching %s", "shop/app=web")
+		l.ExpectInfof("Pod %s is %s, ignoring", "shop/web-3", "Pending")
+		target.AssertTargetListEquals(t, []target.Target{
+			{Host: "web-1", IP: "10.1.0.5", Labels: map[string]string{
+				"kubernetes.context": "prod", "kubernetes.namespace": "shop", "kubernetes.pod": "web-1",
+				"kubernetes.container": "app", "kubernetes.node": "node-a",
+				"kubernetes.label.app": "web", "kubernetes.label.tier": "frontend"}},
+			{Host: "web-2", IP: "10.1.0.6", Labels: map[string]string{
+				"kubernetes.context": "prod", "kubernetes.namespace": "shop", "kubernetes.pod": "web-2",
+				"kubernetes.container": "proxy", "kubernetes.node": "node-b", "kubernetes.label.app": "web"}},
+		}, mustDiscover(t, d, "shop/app=web"))
+	})
+	r.AssertExpectations(t)
+}
+
+func TestKubectlIgnoresOtherInputs(t *testing.T) {
+	d, r := givenAMockedKubectl("")
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("Host lookup string is neither pod:NAME nor a label selector, it won't match anything with kubectl")
+		target.AssertTargetListEquals(t, []target.Target{}, mustDiscover(t, d, "web-1.example.com"))
+	})
+	r.AssertExpectations(t)
+}
+
+func TestKubectlErrors(t *testing.T) {
+	d, r := givenAMockedKubectl("")
+	r.On("Outputs", "kubectl", []string{"get", "pods", "-o", "json", "--field-selector", "metadata.name=web-1"}).
+		Return(util.CommandRunnerOutputs{Error: errors.New("exit status 1"), Combined: []byte("Unable to connect")}).Times(1)
+	r.On("Outputs", "kubectl", []string{"get", "pods", "-o", "json", "-l", "app=web"}).
+		Return(util.CommandRunnerOutputs{Stdout: []byte("No resources found")}).Times(1)
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectInfof("Looking up pods with kubectl matching %s", "pod:web-1")
+		_, err := d.Discover("pod:web-1")
+		util.ExpectError(t, "[kubectl get pods -o json --field-selector metadata.name=web-1] failed: exit status 1\nOutput:\nUnable to connect", err)
+		l.ExpectInfof("Looking up pods with kubectl matching %s", "app=web")
+		_, err = d.Discover("app=web")
+		util.ExpectError(t, "Failed to parse output of kubectl get pods: invalid character 'N' looking for beginning of value", err)
+	})
+	r.AssertExpectations(t)
+}
@@ -0,0 +1,122 @@
+package discoverers
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"github.com/abesto/easyssh/fromsexp"
+	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+/*
+ifInputMatches rewrites (if-input-matches REGEX DISCOVERER [ELSE-DISCOVERER]) into a route table with one or two
+entries; the else branch matches any input
+*/
+var ifInputMatches = fromsexp.SexpTransform{
+	Name: nameIfInputMatches,
+	Matches: func(input []interface{}) bool {
+		return len(input) > 0 && reflect.DeepEqual(input[0], []byte(nameIfInputMatches))
+	},
+	Transform: func(input []interface{}) ([]interface{}, error) {
+		if len(input) < 3 || len(input) > 4 {
+			return nil, util.ParseErrorf("%s requires a regular expression, a discoverer and optionally an else discoverer, got %s", nameIfInputMatches, input[1:])
+		}
+		output := []interface{}{[]byte(nameRoute), []interface{}{input[1], input[2]}}
+		if len(input) == 4 {
+			output = append(output, []interface{}{[]byte(""), input[3]})
+		}
+		return output, nil
+	},
+}
+
+// routeEntry is a (REGEX DISCOVERER [INPUT]) entry of a route table
+type routeEntry struct {
+	regex      *regexp.Regexp
+	discoverer interfaces.Discoverer
+	template   string
+}
+
+func (r routeEntry) String() string {
+	if r.template == "" {
+		return fmt.Sprintf("(%s %s)", r.regex, r.discoverer)
+	}
+	return fmt.Sprintf("(%s %s %s)", r.regex, r.discoverer, r.template)
+}
+
+type route struct {
+	args    []interface{}
+	entries []routeEntry
+}
+
+func (d *route) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	for _, entry := range d.entries {
+		match := entry.regex.FindStringSubmatchIndex(input)
+		if match == nil {
+			continue
+		}
+		childInput := input
+		if entry.template != "" {
+			childInput = string(entry.regex.ExpandString(nil, entry.template, input, match))
+		}
+		util.Logger.Debugf("%s matches %s, looking up %s with %s", input, entry.regex, childInput, entry.discoverer)
+		return entry.discoverer.Discover(childInput)
+	}
+	util.Logger.Debugf("%s matches no route of %s", input, d)
+	return []target.Target{}, nil
+}
+
+func (d *route) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(d, 1, args); err != nil {
+		return err
+	}
+	var entries []routeEntry
+	for _, arg := range args {
+		list, err := util.ListArg(arg)
+		if err != nil {
+			return err
+		}
+		if len(list) < 2 || len(list) > 3 {
+			return util.ParseErrorf("Expected (REGEX DISCOVERER [INPUT]) in %s, got %s", nameRoute, list)
+		}
+		pattern, err := util.StringArg(list[0])
+		if err != nil {
+			return err
+		}
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return util.ParseErrorf("Invalid regular expression in %s: %s", nameRoute, err)
+		}
+		child, err := makeFromSExp(list[1])
+		if err != nil {
+			return err
+		}
+		entry := routeEntry{regex: regex, discoverer: child}
+		if len(list) == 3 {
+			if entry.template, err = util.StringArg(list[2]); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, entry)
+	}
+	d.args = args
+	d.entries = entries
+	return nil
+}
+
+func (d *route) Children() []interface{} {
+	children := make([]interface{}, len(d.entries))
+	for i, entry := range d.entries {
+		children[i] = entry.discoverer
+	}
+	return children
+}
+
+func (d *route) String() string {
+	return fmt.Sprintf("<%s %s>", nameRoute, d.entries)
+}
@@ -0,0 +1,72 @@
+package discoverers
+
+import (
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+func TestRouteStringViaMake(t *testing.T) {
+	d := mustMake(t, `(route ("^i-" (const a)) ("^(.*)\.k8s$" (comma-separated) $1))`)
+	if s := d.String(); s != `<route [(^i- <fixed [a]>) (^(.*)\.k8s$ <separated-by ,> $1)]>` {
+		t.Error(s)
+	}
+}
+
+func TestIfInputMatchesViaMake(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		input := "(if-input-matches : (const a) (const b))"
+		l.ExpectDebugf("MakeFromString %s -> %s", input, "[if-input-matches : [const a] [const b]]")
+		l.ExpectDebugf("Transform: %s -> %s", "[if-input-matches : [const a] [const b]]", "[route [: [const a]] [ [const b]]]")
+		l.ExpectDebugf("Transform: %s -> %s", "[const a]", "[fixed a]")
+		l.ExpectDebugf("Make %s -> %s", "[fixed a]", "<fixed [a]>")
+		l.ExpectDebugf("Transform: %s -> %s", "[const b]", "[fixed b]")
+		l.ExpectDebugf("Make %s -> %s", "[fixed b]", "<fixed [b]>")
+		l.ExpectDebugf("Make %s -> %s", "[route [: [const a]] [ [const b]]]", "<route [(: <fixed [a]>) ( <fixed [b]>)]>")
+		mustMake(t, input)
+	})
+}
+
+func TestRouteMakeErrors(t *testing.T) {
+	cases := []struct {
+		input         string
+		expectedError string
+	}{
+		{"(route)", "<route []> requires at least 1 argument(s), got 0: [] in (route) at position 0"},
+		{"(route foo)", "Expected a definition, got a string: foo in (route foo) at position 0"},
+		{"(route (foo))", "Expected (REGEX DISCOVERER [INPUT]) in route, got [foo] in (route (foo)) at position 0"},
+		{"(route ((const a) (const b)))", "Expected a string, got a list: [const a] in (route ((const a) (const b))) at position 0"},
+		{`(route ("(" (const a)))`, "Invalid regular expression in route: error parsing regexp: missing closing ): `(` in (route (\"(\" (const a))) at position 0"},
+		{"(if-input-matches foo)", "if-input-matches requires a regular expression, a discoverer and optionally an else discoverer, got [foo] in (if-input-matches foo) at position 0"},
+	}
+	for _, c := range cases {
+		_, err := Make(c.input)
+		util.ExpectError(t, c.expectedError, err)
+	}
+}
+
+func TestRouteOperation(t *testing.T) {
+	d := mustMake(t, `(route ("^i-" (const instance)) (":" (const knife)) ("^(?P<name>.*)\.k8s$" (comma-separated) "pod-${name}"))`)
+	cases := []struct {
+		input    string
+		expected []target.Target
+	}{
+		{"i-0123", target.MustFromStrings("instance")},
+		{"roles:app", target.MustFromStrings("knife")},
+		{"web.k8s", target.MustFromStrings("pod-web")},
+		{"web01", []target.Target{}},
+	}
+	for _, c := range cases {
+		target.AssertTargetListEquals(t, c.expected, mustDiscover(t, d, c.input))
+	}
+}
+
+func TestIfInputMatchesOperation(t *testing.T) {
+	d := mustMake(t, "(if-input-matches ^db (const db) (comma-separated))")
+	target.AssertTargetListEquals(t, target.MustFromStrings("db"), mustDiscover(t, d, "db1,db2"))
+	target.AssertTargetListEquals(t, target.MustFromStrings("web1", "web2"), mustDiscover(t, d, "web1,web2"))
+
+	d = mustMake(t, "(if-input-matches ^db (const db))")
+	target.AssertTargetListEquals(t, []target.Target{}, mustDiscover(t, d, "web1"))
+}
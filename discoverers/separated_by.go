@@ -13,7 +13,7 @@ type separatedBy struct {
 	sep  string
 }
 
-func (d *separatedBy) Discover(input string) []target.Target {
+func (d *separatedBy) Discover(input string) ([]target.Target, error) {
 	strs := strings.Split(input, d.sep)
 	notEmpty := make([]string, 0, len(strs))
 	for _, str := range strs {
@@ -24,10 +24,17 @@ func (d *separatedBy) Discover(input string) []target.Target {
 	return target.FromStrings(notEmpty...)
 }
 
-func (d *separatedBy) SetArgs(args []interface{}) {
-	util.RequireArguments(d, 1, args)
+func (d *separatedBy) SetArgs(args []interface{}) error {
+	if err := util.RequireArguments(d, 1, args); err != nil {
+		return err
+	}
+	sep, err := util.StringArg(args[0])
+	if err != nil {
+		return err
+	}
 	d.args = args
-	d.sep = string(args[0].([]byte))
+	d.sep = sep
+	return nil
 }
 
 func (d *separatedBy) String() string {
@@ -14,7 +14,7 @@ func TestCommaSeparatedStringViaMake(t *testing.T) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(comma-separated)", "[comma-separated]")
 		l.ExpectDebugf("Transform: %s -> %s", "[comma-separated]", "[separated-by ,]")
 		l.ExpectDebugf("Make %s -> %s", "[separated-by ,]", "<separated-by ,>")
-		d := Make("(comma-separated)")
+		d := mustMake(t, "(comma-separated)")
 		if d.String() != "<separated-by ,>" {
 			t.Error(d)
 		}
@@ -22,29 +22,27 @@ func TestCommaSeparatedStringViaMake(t *testing.T) {
 }
 
 func TestSeparatedByMakeWithoutArgument(t *testing.T) {
-	util.ExpectPanic(t, "<separated-by > requires exactly 1 argument(s), got 0: []", func() {
-		Make("(separated-by)")
-	})
+	_, err := Make("(separated-by)")
+	util.ExpectError(t, "<separated-by > requires exactly 1 argument(s), got 0: [] in (separated-by) at position 0", err)
 }
 
 func TestSeparatedByMakeWithTooManyArguments(t *testing.T) {
-	util.ExpectPanic(t, "<separated-by > requires exactly 1 argument(s), got 2: [foo bar]", func() {
-		Make("(separated-by foo bar)")
-	})
+	_, err := Make("(separated-by foo bar)")
+	util.ExpectError(t, "<separated-by > requires exactly 1 argument(s), got 2: [foo bar] in (separated-by foo bar) at position 0", err)
 }
 
 func TestCommaSeparatedOperation(t *testing.T) {
-	d := Make("(comma-separated)")
+	d := mustMake(t, "(comma-separated)")
 	cases := []struct {
 		input          string
 		expectedOutput []target.Target
 	}{
 		{"", []target.Target{}},
-		{"foo", target.FromStrings("foo")},
-		{"alpha,beta", target.FromStrings("alpha", "beta")},
+		{"foo", target.MustFromStrings("foo")},
+		{"alpha,beta", target.MustFromStrings("alpha", "beta")},
 	}
 
 	for _, c := range cases {
-		target.AssertTargetListEquals(t, c.expectedOutput, d.Discover(c.input))
+		target.AssertTargetListEquals(t, c.expectedOutput, mustDiscover(t, d, c.input))
 	}
 }
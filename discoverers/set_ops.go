@@ -0,0 +1,286 @@
+package discoverers
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+/*
+setOpsNode is a node of a parsed set expression: either an operand, a target definition passed on to the child
+discoverer, or an operator applied to two subexpressions.
+*/
+type setOpsNode struct {
+	operand     string
+	operator    string
+	left, right *setOpsNode
+}
+
+func (n *setOpsNode) String() string {
+	if n.operator == "" {
+		return n.operand
+	}
+	return fmt.Sprintf("(%s %s %s)", n.left, n.operator, n.right)
+}
+
+func isSetOperator(token string) bool {
+	return token == "+" || token == "-" || token == "&"
+}
+
+/*
+tokenizeSetOps splits input into operators, parentheses and operands. Operators and parentheses are only recognized as
+separate words (parentheses may be attached to the start or end of an operand); consecutive other words form a single
+operand, so that operands can contain spaces, like knife queries. ,! is a shorthand of -, as in roles:app,!web03.
+*/
+func tokenizeSetOps(input string) []string {
+	var tokens, operand []string
+	flush := func() {
+		if len(operand) > 0 {
+			tokens = append(tokens, strings.Join(operand, " "))
+			operand = nil
+		}
+	}
+	for _, word := range strings.Fields(strings.Replace(input, ",!", " - ", -1)) {
+		for strings.HasPrefix(word, "(") {
+			flush()
+			tokens = append(tokens, "(")
+			word = word[1:]
+		}
+		closing := len(word) - len(strings.TrimRight(word, ")"))
+		word = word[:len(word)-closing]
+		if isSetOperator(word) {
+			flush()
+			tokens = append(tokens, word)
+		} else if word != "" {
+			operand = append(operand, word)
+		}
+		for i := 0; i < closing; i++ {
+			flush()
+			tokens = append(tokens, ")")
+		}
+	}
+	flush()
+	return tokens
+}
+
+// setOpsParser is a recursive descent parser of set expressions, where & binds tighter than + and -
+type setOpsParser struct {
+	input  string
+	tokens []string
+	pos    int
+}
+
+func (p *setOpsParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *setOpsParser) errorf(msg string, args ...interface{}) error {
+	return &util.InvalidTargetError{Input: p.input, Msg: fmt.Sprintf(msg, args...)}
+}
+
+func (p *setOpsParser) parse() (*setOpsNode, error) {
+	node, err := p.parseUnion()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %s", p.peek())
+	}
+	return node, nil
+}
+
+func (p *setOpsParser) parseUnion() (*setOpsNode, error) {
+	left, err := p.parseIntersection()
+	for err == nil && (p.peek() == "+" || p.peek() == "-") {
+		operator := p.tokens[p.pos]
+		p.pos++
+		var right *setOpsNode
+		if right, err = p.parseIntersection(); err == nil {
+			left = &setOpsNode{operator: operator, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *setOpsParser) parseIntersection() (*setOpsNode, error) {
+	left, err := p.parseOperand()
+	for err == nil && p.peek() == "&" {
+		p.pos++
+		var right *setOpsNode
+		if right, err = p.parseOperand(); err == nil {
+			left = &setOpsNode{operator: "&", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *setOpsParser) parseOperand() (*setOpsNode, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, p.errorf("unexpected end of input")
+	case token == "(":
+		p.pos++
+		node, err := p.parseUnion()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+		return node, nil
+	case token == ")" || isSetOperator(token):
+		return nil, p.errorf("unexpected %s", token)
+	}
+	p.pos++
+	return &setOpsNode{operand: token}, nil
+}
+
+// nodeNameLabels are the labels discoverers record the name of a node under, which may differ from its address
+var nodeNameLabels = []string{
+	"chef.node", "consul.node", "ansible.host", "azure.name", "gcloud.name", "gcloud.hostname", "kubernetes.pod",
+	"docker.container",
+}
+
+// targetNames returns the names t is known by: its Host, Hostname and IP, and its node names recorded as labels
+func targetNames(t target.Target) []string {
+	names := []string{t.Host, t.Hostname, t.IP}
+	for _, label := range nodeNameLabels {
+		names = append(names, t.Labels[label])
+	}
+	return names
+}
+
+// shortName returns the first label of a domain name, or "" for IP addresses
+func shortName(name string) string {
+	if net.ParseIP(name) != nil {
+		return ""
+	}
+	return strings.SplitN(name, ".", 2)[0]
+}
+
+/*
+sameName tells whether x and y name the same node: if they're equal, or if one of them is a bare host name, like web03,
+and the other one is a domain name starting with it, like web03.example.com
+*/
+func sameName(x, y string) bool {
+	if x == "" || y == "" {
+		return false
+	}
+	if x == y {
+		return true
+	}
+	if !strings.Contains(x, ".") && !strings.Contains(x, ":") {
+		return x == shortName(y)
+	}
+	if !strings.Contains(y, ".") && !strings.Contains(y, ":") {
+		return y == shortName(x)
+	}
+	return false
+}
+
+/*
+sameTarget tells whether a and b name the same node, by any of their Host, Hostname and IP, or the node names
+discoverers record as labels, like chef.node. A bare host name matches domain names starting with it.
+*/
+func sameTarget(a, b target.Target) bool {
+	for _, x := range targetNames(a) {
+		for _, y := range targetNames(b) {
+			if sameName(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsTarget tells whether targets contains a target that's the same as t
+func containsTarget(targets []target.Target, t target.Target) bool {
+	for _, other := range targets {
+		if sameTarget(t, other) {
+			return true
+		}
+	}
+	return false
+}
+
+type setOps struct {
+	args  []interface{}
+	child interfaces.Discoverer
+}
+
+func (d *setOps) evaluate(node *setOpsNode) ([]target.Target, error) {
+	if node.operator == "" {
+		return d.child.Discover(node.operand)
+	}
+	left, err := d.evaluate(node.left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := d.evaluate(node.right)
+	if err != nil {
+		return nil, err
+	}
+	if node.operator == "+" {
+		return dedupTargets(append(append([]target.Target{}, left...), right...)), nil
+	}
+	// Difference keeps the targets not in right, intersection the ones in right
+	keep := node.operator == "&"
+	targets := []target.Target{}
+	for _, t := range left {
+		if containsTarget(right, t) == keep {
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+func (d *setOps) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArguments(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	tokens := tokenizeSetOps(input)
+	if len(tokens) == 0 || (len(tokens) == 1 && tokens[0] != "(" && tokens[0] != ")" && !isSetOperator(tokens[0])) {
+		// No set operations, pass on the input as it is
+		return d.child.Discover(input)
+	}
+	p := &setOpsParser{input: input, tokens: tokens}
+	node, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	util.Logger.Debugf("Parsed set expression %s as %s", input, node)
+	return d.evaluate(node)
+}
+
+func (d *setOps) SetArgs(args []interface{}) error {
+	if err := util.RequireArguments(d, 1, args); err != nil {
+		return err
+	}
+	child, err := makeFromSExp(args[0])
+	if err != nil {
+		return err
+	}
+	d.args = args
+	d.child = child
+	return nil
+}
+
+func (d *setOps) Children() []interface{} {
+	return []interface{}{d.child}
+}
+
+func (d *setOps) String() string {
+	if d.child == nil {
+		return fmt.Sprintf("<%s >", nameSetOps)
+	}
+	return fmt.Sprintf("<%s %s>", nameSetOps, d.child)
+}
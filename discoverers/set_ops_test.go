@@ -0,0 +1,146 @@
+package discoverers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+func TestSetOpsStringViaMake(t *testing.T) {
+	if s := mustMake(t, "(set-ops (comma-separated))").String(); s != "<set-ops <separated-by ,>>" {
+		t.Error(s)
+	}
+}
+
+func TestSetOpsMakeWithWrongArguments(t *testing.T) {
+	_, err := Make("(set-ops)")
+	util.ExpectError(t, "<set-ops > requires exactly 1 argument(s), got 0: [] in (set-ops) at position 0", err)
+	_, err = Make("(set-ops (const a) (const b))")
+	util.ExpectError(t, "<set-ops > requires exactly 1 argument(s), got 2: [[const a] [const b]] in (set-ops (const a) (const b)) at position 0", err)
+}
+
+func TestTokenizeSetOps(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{"", nil},
+		{"roles:app", []string{"roles:app"}},
+		{"roles:app AND chef_environment:prod", []string{"roles:app AND chef_environment:prod"}},
+		{"roles:app - db1.example.com + roles:cache", []string{"roles:app", "-", "db1.example.com", "+", "roles:cache"}},
+		{"roles:app,!web03", []string{"roles:app", "-", "web03"}},
+		{"(a + b) & ( c )", []string{"(", "a", "+", "b", ")", "&", "(", "c", ")"}},
+		{"((web-1))", []string{"(", "(", "web-1", ")", ")"}},
+	}
+	for _, c := range cases {
+		util.AssertStringListEquals(t, c.expected, tokenizeSetOps(c.input))
+	}
+}
+
+func TestSetOpsOperation(t *testing.T) {
+	d := mustMake(t, "(set-ops (expand))")
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{"web[1-3]", []string{"web1", "web2", "web3"}},
+		{"web[1-5] - web3 + db1", []string{"web1", "web2", "web4", "web5", "db1"}},
+		{"web[1-5],!web3", []string{"web1", "web2", "web4", "web5"}},
+		{"web[1-5] - (web[1-4] & web[3-9])", []string{"web1", "web2", "web5"}},
+		{"web[1-2] + web[2-3] & web[3-4]", []string{"web1", "web2", "web3"}},
+		{"(web[1-2] + web[2-3]) & web[2-4]", []string{"web2", "web3"}},
+		{"web1 - web1", []string{}},
+	}
+	for _, c := range cases {
+		target.AssertTargetListEquals(t, target.MustFromStrings(c.expected...), mustDiscover(t, d, c.input))
+	}
+}
+
+func TestSetOpsTargetIdentity(t *testing.T) {
+	files := map[string]string{
+		"nodes.json": `[
+			{"host": "web1.example.com", "hostname": "web1", "ip": "10.0.0.1"},
+			{"host": "web2.example.com", "hostname": "web2", "ip": "10.0.0.2"},
+			{"host": "web3.example.com", "hostname": "web3", "ip": "10.0.0.3"}
+		]`,
+		"broken": "web2\n",
+		"subnet": "10.0.0.3\nweb1.example.com\n",
+	}
+	withTempFiles(t, files, func(dir string) {
+		d := mustMake(t, "(set-ops (file))")
+		path := func(name string) string { return filepath.Join(dir, name) }
+		targets := mustDiscover(t, d, path("nodes.json")+" - "+path("broken")+" & "+path("subnet"))
+		target.AssertTargetListEquals(t, []target.Target{
+			{Host: "web1.example.com", Hostname: "web1", IP: "10.0.0.1"},
+			{Host: "web2.example.com", Hostname: "web2", IP: "10.0.0.2"},
+			{Host: "web3.example.com", Hostname: "web3", IP: "10.0.0.3"},
+		}, targets)
+		targets = mustDiscover(t, d, "("+path("nodes.json")+" - "+path("broken")+") & "+path("subnet"))
+		target.AssertTargetListEquals(t, []target.Target{
+			{Host: "web1.example.com", Hostname: "web1", IP: "10.0.0.1"},
+			{Host: "web3.example.com", Hostname: "web3", IP: "10.0.0.3"},
+		}, targets)
+	})
+}
+
+func TestSetOpsExcludesKnifeNodesByName(t *testing.T) {
+	d := mustMake(t, "(set-ops (first-matching (knife) (comma-separated)))").(*setOps)
+	runner := &util.MockCommandRunner{}
+	d.child.(*firstMatching).children[0] = &knifeSearch{realKnifeSearchResultRowExtractor{}, runner}
+	data := knifeSearchResult{}
+	for _, name := range []string{"web01", "web02", "web03"} {
+		row := knifeSearchResultRow{Name: name}
+		row.Automatic.Fqdn = name + ".example.com"
+		data.Rows = append(data.Rows, row)
+	}
+	knifeReturnsWithCloudV2(runner, "roles:app", data)
+
+	hosts := []string{}
+	for _, t := range mustDiscover(t, d, "roles:app,!web03") {
+		hosts = append(hosts, t.Host)
+	}
+	util.AssertStringListEquals(t, []string{"web01.example.com", "web02.example.com"}, hosts)
+	runner.AssertExpectations(t)
+}
+
+func TestSameTarget(t *testing.T) {
+	cases := []struct {
+		a, b     target.Target
+		expected bool
+	}{
+		{target.Target{Host: "web03.example.com"}, target.Target{Host: "web03"}, true},
+		{target.Target{Host: "web03.example.com"}, target.Target{Host: "web03.other.com"}, false},
+		{target.Target{Host: "web03.example.com"}, target.Target{Host: "web0"}, false},
+		{target.Target{IP: "10.0.0.3", Labels: map[string]string{"chef.node": "db-primary"}}, target.Target{Host: "db-primary"}, true},
+		{target.Target{IP: "10.0.0.3"}, target.Target{Host: "10"}, false},
+		{target.Target{IP: "fe80::1"}, target.Target{IP: "fe80::1"}, true},
+	}
+	for _, c := range cases {
+		if actual := sameTarget(c.a, c.b); actual != c.expected || sameTarget(c.b, c.a) != c.expected {
+			t.Errorf("sameTarget(%v, %v) = %t", c.a, c.b, actual)
+		}
+	}
+}
+
+func TestSetOpsErrors(t *testing.T) {
+	d := mustMake(t, "(set-ops (comma-separated))")
+	cases := []struct {
+		input         string
+		expectedError string
+	}{
+		{"a -", `Invalid target "a -": unexpected end of input`},
+		{"- a", `Invalid target "- a": unexpected -`},
+		{"(a + b", `Invalid target "(a + b": missing )`},
+		{"a + b)", `Invalid target "a + b)": unexpected )`},
+		{"a & & b", `Invalid target "a & & b": unexpected &`},
+	}
+	for _, c := range cases {
+		_, err := d.Discover(c.input)
+		util.ExpectError(t, c.expectedError, err)
+	}
+
+	_, err := mustMake(t, "(set-ops (file))").Discover("/nonexistent/a - /nonexistent/b")
+	util.ExpectError(t, "Failed to read host list: open /nonexistent/a: no such file or directory", err)
+}
@@ -0,0 +1,224 @@
+package discoverers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// The same limit OpenSSH uses for nested Include directives
+const sshConfigMaxIncludeDepth = 16
+
+/*
+sshConfigBlock is a Host or Match section of an ssh config file, with the options relevant to easyssh.
+Options before the first Host line are in a block matching all hosts. Match blocks have no patterns, and never match,
+because their criteria can't be evaluated without connecting.
+*/
+type sshConfigBlock struct {
+	patterns []string
+	options  map[string]string
+}
+
+func (b sshConfigBlock) matches(alias string) bool {
+	matched := false
+	for _, pattern := range b.patterns {
+		if strings.HasPrefix(pattern, "!") {
+			if sshConfigPatternMatches(pattern[1:], alias) {
+				return false
+			}
+		} else if sshConfigPatternMatches(pattern, alias) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// sshConfigPatternMatches matches s against an OpenSSH pattern, where * matches any string and ? any character
+func sshConfigPatternMatches(pattern, s string) bool {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.Replace(quoted, `\*`, ".*", -1)
+	quoted = strings.Replace(quoted, `\?`, ".", -1)
+	return regexp.MustCompile("^" + quoted + "$").MatchString(s)
+}
+
+func isSSHConfigPattern(alias string) bool {
+	return strings.ContainsAny(alias, "*?!")
+}
+
+type sshConfig struct {
+	args []interface{}
+	path string
+}
+
+func (d *sshConfig) Discover(input string) ([]target.Target, error) {
+	path := expandHome(d.path)
+	if path == "" {
+		path = filepath.Join(os.Getenv("HOME"), ".ssh", "config")
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			util.Logger.Debugf("%s doesn't exist, %s found nothing", path, d)
+			return nil, nil
+		}
+	}
+	blocks, err := parseSSHConfigFile(path, filepath.Dir(path), []string{"*"}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []target.Target
+	seen := map[string]bool{}
+	for _, block := range blocks {
+		for _, alias := range block.patterns {
+			if isSSHConfigPattern(alias) || seen[alias] || !sshConfigPatternMatches(input, alias) {
+				continue
+			}
+			seen[alias] = true
+			t, err := sshConfigTarget(alias, blocks)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+// sshConfigTarget resolves the options of alias the way ssh does: the first value found in a matching block wins
+func sshConfigTarget(alias string, blocks []sshConfigBlock) (target.Target, error) {
+	t := target.Target{Host: alias}
+	options := map[string]string{}
+	for _, block := range blocks {
+		if !block.matches(alias) {
+			continue
+		}
+		for key, value := range block.options {
+			if _, ok := options[key]; !ok {
+				options[key] = value
+			}
+		}
+	}
+	t.Hostname = strings.Replace(options["hostname"], "%h", alias, -1)
+	t.User = options["user"]
+	if port, ok := options["port"]; ok {
+		number, err := strconv.Atoi(port)
+		if err != nil || number < 1 || number > 65535 {
+			return t, util.ConfigErrorf("Invalid Port %s for Host %s in ssh config", port, alias)
+		}
+		t.Port = number
+	}
+	// ProxyJump none disables jump hosts set by later blocks, like Host *
+	if jump, ok := options["proxyjump"]; ok && !strings.EqualFold(jump, "none") {
+		t.ProxyJump = strings.Split(jump, ",")
+	}
+	return t, nil
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return filepath.Join(os.Getenv("HOME"), path[1:])
+	}
+	return path
+}
+
+/*
+parseSSHConfigFile parses the ssh config file at path, inlining the files it includes.
+Lines before the first Host or Match line belong to a block with patterns, which is * for the top-level file, and the
+patterns of the enclosing block for included files. Relative Include paths are resolved in includeDir, the directory of
+the top-level config file.
+*/
+func parseSSHConfigFile(path, includeDir string, patterns []string, depth int) ([]sshConfigBlock, error) {
+	if depth > sshConfigMaxIncludeDepth {
+		return nil, util.ConfigErrorf("Too many nested Include directives in ssh config at %s", path)
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, util.ConfigErrorf("Failed to read ssh config: %s", err)
+	}
+	blocks := []sshConfigBlock{{patterns: patterns, options: map[string]string{}}}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		keyword, value := splitSSHConfigLine(scanner.Text())
+		switch keyword {
+		case "":
+			continue
+		case "host":
+			blocks = append(blocks, sshConfigBlock{patterns: strings.Fields(value), options: map[string]string{}})
+		case "match":
+			blocks = append(blocks, sshConfigBlock{options: map[string]string{}})
+		case "include":
+			enclosing := blocks[len(blocks)-1].patterns
+			for _, pattern := range strings.Fields(value) {
+				pattern = expandHome(pattern)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(includeDir, pattern)
+				}
+				matches, err := filepath.Glob(pattern)
+				if err != nil {
+					return nil, util.ConfigErrorf("Invalid Include %s in %s line %d: %s", pattern, path, lineNumber, err)
+				}
+				for _, match := range matches {
+					included, err := parseSSHConfigFile(match, includeDir, enclosing, depth+1)
+					if err != nil {
+						return nil, err
+					}
+					blocks = append(blocks, included...)
+				}
+			}
+			// The lines after the Include still belong to the enclosing block
+			blocks = append(blocks, sshConfigBlock{patterns: enclosing, options: map[string]string{}})
+		case "hostname", "user", "port", "proxyjump":
+			options := blocks[len(blocks)-1].options
+			if _, ok := options[keyword]; !ok {
+				options[keyword] = value
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// splitSSHConfigLine returns the lowercase keyword and the value of a line, or an empty keyword for blank lines and comments
+func splitSSHConfigLine(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	end := strings.IndexAny(line, " \t=")
+	if end == -1 {
+		return strings.ToLower(line), ""
+	}
+	value := strings.TrimSpace(line[end:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	value = strings.Trim(value, `"`)
+	return strings.ToLower(line[:end]), value
+}
+
+func (d *sshConfig) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtMost(d, 1, args); err != nil {
+		return err
+	}
+	var path string
+	if len(args) == 1 {
+		var err error
+		if path, err = util.StringArg(args[0]); err != nil {
+			return err
+		}
+	}
+	d.args = args
+	d.path = path
+	return nil
+}
+
+func (d *sshConfig) String() string {
+	if d.path == "" {
+		return fmt.Sprintf("<%s>", nameSSHConfig)
+	}
+	return fmt.Sprintf("<%s %s>", nameSSHConfig, d.path)
+}
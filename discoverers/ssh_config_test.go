@@ -0,0 +1,119 @@
+package discoverers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+func withTempFiles(t *testing.T, files map[string]string, f func(dir string)) {
+	dir, err := ioutil.TempDir("", "easyssh-discoverers-test")
+	util.ExpectNoError(t, err)
+	defer os.RemoveAll(dir)
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		util.ExpectNoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		util.ExpectNoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+	f(dir)
+}
+
+func TestSSHConfigStringViaMake(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("MakeFromString %s -> %s", "(ssh-config)", "[ssh-config]")
+		l.ExpectDebugf("Make %s -> %s", "[ssh-config]", "<ssh-config>")
+		mustMake(t, "(ssh-config)")
+		l.ExpectDebugf("MakeFromString %s -> %s", "(ssh-config ~/my-config)", "[ssh-config ~/my-config]")
+		l.ExpectDebugf("Make %s -> %s", "[ssh-config ~/my-config]", "<ssh-config ~/my-config>")
+		mustMake(t, "(ssh-config ~/my-config)")
+	})
+}
+
+func TestSSHConfigMakeWithTooManyArguments(t *testing.T) {
+	_, err := Make("(ssh-config a b)")
+	util.ExpectError(t, "<ssh-config> takes at most 1 argument(s), got 2: [a b] in (ssh-config a b) at position 0", err)
+}
+
+func TestSSHConfigDiscover(t *testing.T) {
+	files := map[string]string{
+		"config": `
+# Global options come first
+User global-user
+
+Host web-* !web-canary
+    Port 2222
+    ProxyJump bastion,admin@inner:2200
+
+Host web-1 web-2 web-canary
+    HostName %h.example.com
+
+Include conf.d/*.conf
+Host db-1
+    HostName = 10.0.0.5
+    User "dba"
+    Include extra
+    Port 5022
+
+Match host db-*
+    User nobody
+
+Host *
+    ProxyJump gateway
+`,
+		"conf.d/app.conf": `
+Host app-1
+  hostname app-1.internal
+  user deploy
+  proxyjump none
+`,
+		"extra": `
+Port 1022
+Host web-2
+  Port 3333
+`,
+	}
+	withTempFiles(t, files, func(dir string) {
+		d := mustMake(t, "(ssh-config "+filepath.Join(dir, "config")+")")
+		target.AssertTargetListEquals(t, []target.Target{
+			{Host: "web-1", Hostname: "web-1.example.com", User: "global-user", Port: 2222, ProxyJump: []string{"bastion", "admin@inner:2200"}},
+			{Host: "web-2", Hostname: "web-2.example.com", User: "global-user", Port: 2222, ProxyJump: []string{"bastion", "admin@inner:2200"}},
+			{Host: "web-canary", Hostname: "web-canary.example.com", User: "global-user", ProxyJump: []string{"gateway"}},
+		}, mustDiscover(t, d, "web-*"))
+		target.AssertTargetListEquals(t, []target.Target{
+			{Host: "app-1", Hostname: "app-1.internal", User: "global-user"},
+		}, mustDiscover(t, d, "app-1"))
+		target.AssertTargetListEquals(t, []target.Target{
+			{Host: "db-1", Hostname: "10.0.0.5", User: "global-user", Port: 1022, ProxyJump: []string{"gateway"}},
+		}, mustDiscover(t, d, "db-?"))
+		target.AssertTargetListEquals(t, []target.Target{}, mustDiscover(t, d, "nothing-*"))
+	})
+}
+
+func TestSSHConfigErrors(t *testing.T) {
+	withTempFiles(t, map[string]string{"config": "Host a\n  Port ssh\n", "loop": "Include loop\n"}, func(dir string) {
+		_, err := mustMake(t, "(ssh-config "+filepath.Join(dir, "config")+")").Discover("a")
+		util.ExpectError(t, "Invalid Port ssh for Host a in ssh config", err)
+
+		_, err = mustMake(t, "(ssh-config "+filepath.Join(dir, "loop")+")").Discover("a")
+		util.ExpectError(t, "Too many nested Include directives in ssh config at "+filepath.Join(dir, "loop"), err)
+
+		_, err = mustMake(t, "(ssh-config "+filepath.Join(dir, "missing")+")").Discover("a")
+		util.ExpectError(t, "Failed to read ssh config: open "+filepath.Join(dir, "missing")+": no such file or directory", err)
+		if util.ExitCode(err) != util.ExitCodeConfig {
+			t.Errorf("Unexpected exit code %d", util.ExitCode(err))
+		}
+	})
+}
+
+func TestSSHConfigDefaultPathMissing(t *testing.T) {
+	withTempFiles(t, map[string]string{}, func(dir string) {
+		oldHome := os.Getenv("HOME")
+		defer os.Setenv("HOME", oldHome)
+		os.Setenv("HOME", dir)
+		target.AssertTargetListEquals(t, []target.Target{}, mustDiscover(t, mustMake(t, "(ssh-config)"), "a"))
+	})
+}
@@ -0,0 +1,293 @@
+package discoverers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// terraformStateV4 is the format of terraform.tfstate files since Terraform 0.12
+type terraformStateV4 struct {
+	Version   int
+	Resources []struct {
+		Module    string
+		Mode      string
+		Type      string
+		Name      string
+		Instances []struct {
+			IndexKey   interface{}            `json:"index_key"`
+			Attributes map[string]interface{} `json:"attributes"`
+		}
+	}
+}
+
+// terraformShowModule is a module in the output of terraform show -json
+type terraformShowModule struct {
+	Resources []struct {
+		Address string
+		Mode    string
+		Type    string
+		Values  map[string]interface{}
+	}
+	ChildModules []terraformShowModule `json:"child_modules"`
+}
+
+type terraformShowOutput struct {
+	FormatVersion string `json:"format_version"`
+	Values        struct {
+		RootModule terraformShowModule `json:"root_module"`
+	}
+}
+
+// terraformResource is a single instance of a managed resource, from either format
+type terraformResource struct {
+	address    string
+	kind       string
+	attributes map[string]interface{}
+}
+
+/*
+terraformAddresses describes how to reach an instance of a resource type: the attribute paths of the public and private
+addresses, its name, tags and zone. Paths are dot-separated, and numbers index lists.
+*/
+type terraformAddresses struct {
+	publicIP, privateIP, publicDNS, privateDNS, name, tags, zone string
+}
+
+var terraformResourceTypes = map[string]terraformAddresses{
+	"aws_instance": {
+		publicIP: "public_ip", privateIP: "private_ip", publicDNS: "public_dns", privateDNS: "private_dns",
+		tags: "tags", zone: "availability_zone",
+	},
+	"google_compute_instance": {
+		publicIP: "network_interface.0.access_config.0.nat_ip", privateIP: "network_interface.0.network_ip",
+		name: "name", tags: "labels", zone: "zone",
+	},
+	"azurerm_linux_virtual_machine": {
+		publicIP: "public_ip_address", privateIP: "private_ip_address", name: "name", tags: "tags", zone: "zone",
+	},
+	"azurerm_windows_virtual_machine": {
+		publicIP: "public_ip_address", privateIP: "private_ip_address", name: "name", tags: "tags", zone: "zone",
+	},
+	"digitalocean_droplet": {
+		publicIP: "ipv4_address", privateIP: "ipv4_address_private", name: "name", zone: "region",
+	},
+	"hcloud_server": {
+		publicIP: "ipv4_address", name: "name", tags: "labels", zone: "location",
+	},
+	"openstack_compute_instance_v2": {
+		publicIP: "access_ip_v4", name: "name", tags: "metadata", zone: "availability_zone",
+	},
+}
+
+func terraformAttribute(attributes map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	var current interface{} = attributes
+	for _, key := range strings.Split(path, ".") {
+		switch typed := current.(type) {
+		case map[string]interface{}:
+			current = typed[key]
+		case []interface{}:
+			var index int
+			if _, err := fmt.Sscanf(key, "%d", &index); err != nil || index >= len(typed) {
+				return nil
+			}
+			current = typed[index]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+func terraformString(attributes map[string]interface{}, path string) string {
+	s, _ := terraformAttribute(attributes, path).(string)
+	return s
+}
+
+func terraformTags(attributes map[string]interface{}, path string) map[string]string {
+	tags := map[string]string{}
+	raw, _ := terraformAttribute(attributes, path).(map[string]interface{})
+	for key, value := range raw {
+		if s, ok := value.(string); ok {
+			tags[key] = s
+		}
+	}
+	return tags
+}
+
+// terraformIndex formats the index_key of a resource instance the way Terraform addresses do
+func terraformIndex(key interface{}) string {
+	switch typed := key.(type) {
+	case float64:
+		return fmt.Sprintf("[%d]", int(typed))
+	case string:
+		return fmt.Sprintf("[%q]", typed)
+	}
+	return ""
+}
+
+func parseTerraformState(data []byte) ([]terraformResource, error) {
+	var show terraformShowOutput
+	if err := json.Unmarshal(data, &show); err != nil {
+		return nil, err
+	}
+	if show.FormatVersion != "" {
+		var resources []terraformResource
+		collectTerraformShowResources(show.Values.RootModule, &resources)
+		return resources, nil
+	}
+
+	var state terraformStateV4
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	if state.Version != 4 {
+		return nil, fmt.Errorf("unsupported state version %d, expected 4", state.Version)
+	}
+	var resources []terraformResource
+	for _, resource := range state.Resources {
+		if resource.Mode != "managed" {
+			continue
+		}
+		address := resource.Type + "." + resource.Name
+		if resource.Module != "" {
+			address = resource.Module + "." + address
+		}
+		for _, instance := range resource.Instances {
+			resources = append(resources, terraformResource{
+				address:    address + terraformIndex(instance.IndexKey),
+				kind:       resource.Type,
+				attributes: instance.Attributes,
+			})
+		}
+	}
+	return resources, nil
+}
+
+func collectTerraformShowResources(module terraformShowModule, resources *[]terraformResource) {
+	for _, resource := range module.Resources {
+		if resource.Mode == "managed" {
+			*resources = append(*resources, terraformResource{address: resource.Address, kind: resource.Type, attributes: resource.Values})
+		}
+	}
+	for _, child := range module.ChildModules {
+		collectTerraformShowResources(child, resources)
+	}
+}
+
+/*
+terraformMatches tells whether a resource matches input: either tag:Key=value terms separated by commas, all of which
+must match (the value may be a glob), or a resource address. Addresses match themselves, and all instances and
+resources under them, like aws_instance.web for aws_instance.web[0], or module.app for everything in that module.
+They can also be globs.
+*/
+func terraformMatches(input string, resource terraformResource, tags map[string]string) bool {
+	if strings.HasPrefix(input, "tag:") {
+		for _, term := range strings.Split(input, ",") {
+			parts := strings.SplitN(strings.TrimPrefix(term, "tag:"), "=", 2)
+			if len(parts) != 2 {
+				return false
+			}
+			value, ok := tags[parts[0]]
+			if !ok || !sshConfigPatternMatches(parts[1], value) {
+				return false
+			}
+		}
+		return true
+	}
+	return resource.address == input ||
+		strings.HasPrefix(resource.address, input+"[") ||
+		strings.HasPrefix(resource.address, input+".") ||
+		sshConfigPatternMatches(input, resource.address)
+}
+
+func terraformTarget(resource terraformResource, addresses terraformAddresses, tags map[string]string) target.Target {
+	var t target.Target
+	attributes := resource.attributes
+	if t.IP = terraformString(attributes, addresses.publicIP); t.IP != "" {
+		t.Host = terraformString(attributes, addresses.publicDNS)
+	} else {
+		t.IP = terraformString(attributes, addresses.privateIP)
+		t.Host = terraformString(attributes, addresses.privateDNS)
+	}
+	if t.Hostname = terraformString(attributes, addresses.name); t.Hostname == "" {
+		t.Hostname = tags["Name"]
+	}
+	t.SetLabel("terraform.address", resource.address)
+	t.SetLabel("terraform.type", resource.kind)
+	t.SetLabel("terraform.public_ip", terraformString(attributes, addresses.publicIP))
+	t.SetLabel("terraform.private_ip", terraformString(attributes, addresses.privateIP))
+	zone := terraformString(attributes, addresses.zone)
+	t.SetLabel("zone", zone[strings.LastIndex(zone, "/")+1:])
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		t.SetLabel("terraform.tag."+key, tags[key])
+	}
+	return t
+}
+
+type terraformState struct {
+	args []interface{}
+	path string
+}
+
+func (d *terraformState) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArguments(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(expandHome(d.path))
+	if err != nil {
+		return nil, util.ConfigErrorf("Failed to read Terraform state: %s", err)
+	}
+	resources, err := parseTerraformState(data)
+	if err != nil {
+		return nil, util.ConfigErrorf("Failed to parse Terraform state %s: %s", d.path, err)
+	}
+	var targets []target.Target
+	for _, resource := range resources {
+		addresses, known := terraformResourceTypes[resource.kind]
+		if !known {
+			continue
+		}
+		tags := terraformTags(resource.attributes, addresses.tags)
+		if !terraformMatches(input, resource, tags) {
+			continue
+		}
+		t := terraformTarget(resource, addresses, tags)
+		if t.IsEmpty() {
+			util.Logger.Infof("%s doesn't have an IP address or hostname, ignoring", resource.address)
+			continue
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func (d *terraformState) SetArgs(args []interface{}) error {
+	if err := util.RequireArguments(d, 1, args); err != nil {
+		return err
+	}
+	path, err := util.StringArg(args[0])
+	if err != nil {
+		return err
+	}
+	d.args = args
+	d.path = path
+	return nil
+}
+
+func (d *terraformState) String() string {
+	return fmt.Sprintf("<%s %s>", nameTerraformState, d.path)
+}
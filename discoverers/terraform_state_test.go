@@ -0,0 +1,176 @@
+package discoverers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+const terraformStateV4JSON = `{
+  "version": 4,
+  "terraform_version": "1.5.7",
+  "resources": [
+    {
+      "mode": "data",
+      "type": "aws_ami",
+      "name": "ubuntu",
+      "instances": [{"attributes": {"id": "ami-1"}}]
+    },
+    {
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "web",
+      "instances": [
+        {
+          "index_key": 0,
+          "attributes": {
+            "public_ip": "54.1.2.3", "public_dns": "ec2-54-1-2-3.compute.amazonaws.com",
+            "private_ip": "10.0.0.1", "private_dns": "ip-10-0-0-1.ec2.internal",
+            "availability_zone": "us-east-1a", "tags": {"Name": "web-1", "Env": "prod"}
+          }
+        },
+        {
+          "index_key": 1,
+          "attributes": {
+            "public_ip": "", "public_dns": "",
+            "private_ip": "10.0.0.2", "private_dns": "ip-10-0-0-2.ec2.internal",
+            "tags": {"Name": "web-2", "Env": "staging"}
+          }
+        }
+      ]
+    },
+    {
+      "module": "module.db",
+      "mode": "managed",
+      "type": "google_compute_instance",
+      "name": "primary",
+      "instances": [
+        {
+          "index_key": "eu",
+          "attributes": {
+            "name": "db-eu", "zone": "projects/p/zones/europe-west1-b", "labels": {"role": "db"},
+            "network_interface": [{"network_ip": "10.1.0.1", "access_config": [{"nat_ip": "35.1.1.1"}]}]
+          }
+        }
+      ]
+    },
+    {
+      "mode": "managed",
+      "type": "aws_security_group",
+      "name": "web",
+      "instances": [{"attributes": {"id": "sg-1"}}]
+    }
+  ]
+}`
+
+const terraformShowJSON = `{
+  "format_version": "1.0",
+  "values": {
+    "root_module": {
+      "resources": [
+        {
+          "address": "digitalocean_droplet.cache", "mode": "managed", "type": "digitalocean_droplet",
+          "values": {"name": "cache-1", "ipv4_address": "167.1.1.1", "ipv4_address_private": "10.2.0.1", "region": "ams3"}
+        }
+      ],
+      "child_modules": [
+        {
+          "address": "module.app",
+          "resources": [
+            {
+              "address": "module.app.aws_instance.app", "mode": "managed", "type": "aws_instance",
+              "values": {"private_ip": "10.3.0.1", "private_dns": "", "tags": {"Name": "app-1"}}
+            }
+          ]
+        }
+      ]
+    }
+  }
+}`
+
+func withTerraformStates(t *testing.T, f func(state, show interfaces.Discoverer)) {
+	files := map[string]string{"terraform.tfstate": terraformStateV4JSON, "show.json": terraformShowJSON}
+	withTempFiles(t, files, func(dir string) {
+		f(mustMake(t, "(terraform-state "+filepath.Join(dir, "terraform.tfstate")+")"),
+			mustMake(t, "(terraform-state "+filepath.Join(dir, "show.json")+")"))
+	})
+}
+
+func TestTerraformStateMakeWithoutArgument(t *testing.T) {
+	_, err := Make("(terraform-state)")
+	util.ExpectError(t, "<terraform-state > requires exactly 1 argument(s), got 0: [] in (terraform-state) at position 0", err)
+}
+
+func TestTerraformStateTargets(t *testing.T) {
+	withTerraformStates(t, func(state, show interfaces.Discoverer) {
+		target.AssertTargetListEquals(t, []target.Target{
+			{Host: "ec2-54-1-2-3.compute.amazonaws.com", IP: "54.1.2.3", Hostname: "web-1", Labels: map[string]string{
+				"terraform.address": "aws_instance.web[0]", "terraform.type": "aws_instance",
+				"terraform.public_ip": "54.1.2.3", "terraform.private_ip": "10.0.0.1", "zone": "us-east-1a",
+				"terraform.tag.Name": "web-1", "terraform.tag.Env": "prod"}},
+			{Host: "ip-10-0-0-2.ec2.internal", IP: "10.0.0.2", Hostname: "web-2", Labels: map[string]string{
+				"terraform.address": "aws_instance.web[1]", "terraform.type": "aws_instance",
+				"terraform.private_ip": "10.0.0.2", "terraform.tag.Name": "web-2", "terraform.tag.Env": "staging"}},
+			{IP: "35.1.1.1", Hostname: "db-eu", Labels: map[string]string{
+				"terraform.address": `module.db.google_compute_instance.primary["eu"]`, "terraform.type": "google_compute_instance",
+				"terraform.public_ip": "35.1.1.1", "terraform.private_ip": "10.1.0.1", "zone": "europe-west1-b",
+				"terraform.tag.role": "db"}},
+		}, mustDiscover(t, state, "*"))
+
+		target.AssertTargetListEquals(t, []target.Target{
+			{IP: "167.1.1.1", Hostname: "cache-1", Labels: map[string]string{
+				"terraform.address": "digitalocean_droplet.cache", "terraform.type": "digitalocean_droplet",
+				"terraform.public_ip": "167.1.1.1", "terraform.private_ip": "10.2.0.1", "zone": "ams3"}},
+			{IP: "10.3.0.1", Hostname: "app-1", Labels: map[string]string{
+				"terraform.address": "module.app.aws_instance.app", "terraform.type": "aws_instance",
+				"terraform.private_ip": "10.3.0.1", "terraform.tag.Name": "app-1"}},
+		}, mustDiscover(t, show, "*"))
+	})
+}
+
+func TestTerraformStateMatching(t *testing.T) {
+	withTerraformStates(t, func(state, show interfaces.Discoverer) {
+		cases := []struct {
+			input    string
+			expected []string
+		}{
+			{"aws_instance.web", []string{"web-1", "web-2"}},
+			{"aws_instance.web[1]", []string{"web-2"}},
+			{"aws_instance.we", []string{}},
+			{"module.db", []string{"db-eu"}},
+			{"module.*", []string{"db-eu"}},
+			{"tag:Name=web-1", []string{"web-1"}},
+			{"tag:Name=web-*,tag:Env=staging", []string{"web-2"}},
+			{"tag:role=db", []string{"db-eu"}},
+			{"tag:Missing=x", []string{}},
+		}
+		for _, c := range cases {
+			actual := []string{}
+			for _, discovered := range mustDiscover(t, state, c.input) {
+				actual = append(actual, discovered.Hostname)
+			}
+			util.AssertStringListEquals(t, c.expected, actual)
+		}
+	})
+}
+
+func TestTerraformStateErrors(t *testing.T) {
+	files := map[string]string{"v3.tfstate": `{"version": 3}`, "broken.tfstate": `{"version":`}
+	withTempFiles(t, files, func(dir string) {
+		cases := []struct {
+			file string
+			err  string
+		}{
+			{"v3.tfstate", "Failed to parse Terraform state " + filepath.Join(dir, "v3.tfstate") + ": unsupported state version 3, expected 4"},
+			{"broken.tfstate", "Failed to parse Terraform state " + filepath.Join(dir, "broken.tfstate") + ": unexpected end of JSON input"},
+			{"missing", "Failed to read Terraform state: open " + filepath.Join(dir, "missing") + ": no such file or directory"},
+		}
+		for _, c := range cases {
+			_, err := mustMake(t, "(terraform-state "+filepath.Join(dir, c.file)+")").Discover("*")
+			util.ExpectError(t, c.err, err)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package discoverers
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+/*
+mergeTargets fills the empty fields of into from from. Non-empty fields of into are kept, so the discoverer listed
+first wins conflicts.
+*/
+func mergeTargets(into *target.Target, from target.Target) {
+	if into.Host == "" {
+		into.Host = from.Host
+	}
+	if into.Hostname == "" {
+		into.Hostname = from.Hostname
+	}
+	if into.IP == "" {
+		into.IP = from.IP
+	}
+	if into.User == "" {
+		into.User = from.User
+	}
+	if into.Port == 0 {
+		into.Port = from.Port
+	}
+	if len(into.ProxyJump) == 0 {
+		into.ProxyJump = from.ProxyJump
+	}
+	if len(into.CoalesceOrder) == 0 {
+		into.CoalesceOrder = from.CoalesceOrder
+	}
+	for key, value := range from.Labels {
+		if _, ok := into.Labels[key]; !ok {
+			into.SetLabel(key, value)
+		}
+	}
+}
+
+// dedupTargets merges targets with the same IP or Host into the first one of them, keeping the order of targets
+func dedupTargets(targets []target.Target) []target.Target {
+	merged := []target.Target{}
+	byIP := map[string]int{}
+	byHost := map[string]int{}
+	for _, t := range targets {
+		i, ok := byIP[t.IP]
+		if !ok || t.IP == "" {
+			i, ok = byHost[t.Host]
+			ok = ok && t.Host != ""
+		}
+		if ok {
+			util.Logger.Debugf("Merging duplicate target %s into %s", t, merged[i])
+			mergeTargets(&merged[i], t)
+		} else {
+			i = len(merged)
+			merged = append(merged, t)
+		}
+		if _, seen := byIP[merged[i].IP]; !seen && merged[i].IP != "" {
+			byIP[merged[i].IP] = i
+		}
+		if _, seen := byHost[merged[i].Host]; !seen && merged[i].Host != "" {
+			byHost[merged[i].Host] = i
+		}
+	}
+	return merged
+}
+
+type union struct {
+	args     []interface{}
+	children []interfaces.Discoverer
+	strict   bool
+}
+
+func (d *union) name() string {
+	if d.strict {
+		return nameUnionStrict
+	}
+	return nameUnion
+}
+
+func (d *union) Discover(input string) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(d, 1, d.args); err != nil {
+		return nil, err
+	}
+	results := make([][]target.Target, len(d.children))
+	errs := make([]error, len(d.children))
+	var wg sync.WaitGroup
+	for i, child := range d.children {
+		wg.Add(1)
+		go func(i int, child interfaces.Discoverer) {
+			defer wg.Done()
+			results[i], errs[i] = child.Discover(input)
+		}(i, child)
+	}
+	wg.Wait()
+
+	var targets []target.Target
+	for i, child := range d.children {
+		if errs[i] != nil {
+			if d.strict {
+				return nil, errs[i]
+			}
+			util.Logger.Warningf("%s failed, ignoring it: %s", child, errs[i])
+			continue
+		}
+		targets = append(targets, results[i]...)
+	}
+	return dedupTargets(targets), nil
+}
+
+func (d *union) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(d, 1, args); err != nil {
+		return err
+	}
+	d.args = args
+	d.children = []interfaces.Discoverer{}
+	for _, exp := range args {
+		child, err := makeFromSExp(exp)
+		if err != nil {
+			return err
+		}
+		d.children = append(d.children, child)
+	}
+	return nil
+}
+
+func (d *union) Children() []interface{} {
+	children := make([]interface{}, len(d.children))
+	for i, child := range d.children {
+		children[i] = child
+	}
+	return children
+}
+
+func (d *union) String() string {
+	return fmt.Sprintf("<%s %s>", d.name(), d.children)
+}
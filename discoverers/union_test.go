@@ -0,0 +1,54 @@
+package discoverers
+
+import (
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+func TestUnionStringViaMake(t *testing.T) {
+	if s := mustMake(t, "(union (const a) (comma-separated))").String(); s != "<union [<fixed [a]> <separated-by ,>]>" {
+		t.Error(s)
+	}
+	if s := mustMake(t, "(union-strict (const a))").String(); s != "<union-strict [<fixed [a]>]>" {
+		t.Error(s)
+	}
+}
+
+func TestUnionMakeWithoutArgument(t *testing.T) {
+	_, err := Make("(union)")
+	util.ExpectError(t, "<union []> requires at least 1 argument(s), got 0: [] in (union) at position 0", err)
+}
+
+func TestUnionOperation(t *testing.T) {
+	d := mustMake(t, "(union (const a b) (comma-separated) (const root@b:2222 c))")
+	target.AssertTargetListEquals(t, target.MustFromStrings("a", "root@b:2222", "x", "c"), mustDiscover(t, d, "x,a"))
+}
+
+func TestUnionChildErrors(t *testing.T) {
+	d := mustMake(t, "(union (const a) (file /nonexistent/hosts) (const b))")
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectWarningf("%s failed, ignoring it: %s", "<file /nonexistent/hosts>", "Failed to read host list: open /nonexistent/hosts: no such file or directory")
+		target.AssertTargetListEquals(t, target.MustFromStrings("a", "b"), mustDiscover(t, d, "x"))
+	})
+
+	_, err := mustMake(t, "(union-strict (const a) (file /nonexistent/hosts) (const b))").Discover("x")
+	util.ExpectError(t, "Failed to read host list: open /nonexistent/hosts: no such file or directory", err)
+}
+
+func TestDedupTargets(t *testing.T) {
+	targets := []target.Target{
+		{Host: "web1", Labels: map[string]string{"chef.roles": "web", "region": "eu"}},
+		{Host: "web1", IP: "10.0.0.1", Port: 2222, User: "root", Labels: map[string]string{"region": "us", "zone": "us-1a"}},
+		{IP: "10.0.0.1", Hostname: "web1.example.com", User: "deploy"},
+		{IP: "10.0.0.2", ProxyJump: []string{"bastion"}},
+		{Host: "web2"},
+	}
+	target.AssertTargetListEquals(t, []target.Target{
+		{Host: "web1", Hostname: "web1.example.com", IP: "10.0.0.1", Port: 2222, User: "root",
+			Labels: map[string]string{"chef.roles": "web", "region": "eu", "zone": "us-1a"}},
+		{IP: "10.0.0.2", ProxyJump: []string{"bastion"}},
+		{Host: "web2"},
+	}, dedupTargets(targets))
+}
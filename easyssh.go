@@ -6,10 +6,13 @@ import (
 	"os"
 	"strings"
 
+	"github.com/abesto/easyssh/cache"
+	"github.com/abesto/easyssh/config"
 	"github.com/abesto/easyssh/discoverers"
 	"github.com/abesto/easyssh/executors"
 	"github.com/abesto/easyssh/filters"
 	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/pipeline"
 	"github.com/abesto/easyssh/util"
 	"github.com/alexcesaro/log/stdlog"
 )
@@ -26,40 +29,58 @@ func main() {
 		user                 string
 		filterDefinition     string
 		filter               interfaces.TargetFilter
+		profileName          string
+		dryRun               bool
+		err                  error
 	)
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: %s [options] target-definition [command]
+       %s explain [options]
 
 Where
   target-definition is the input to the discoverer(s) defined with -d
   command, if provided, will be run on the targets
+  explain prints the fully expanded discoverer, filter and executor definitions
 
-Ideally a single alias should cover all your use-cases. For example:
-  smartssh_executor='(if-command (ssh-exec-parallel) (if-one-target (ssh-login) (tmux-cssh)))'
-  smartssh_discoverer='(first-matching (knife) (comma-separated))'
-  smartssh_filter='(list (ec2-instance-id us-east-1) (ec2-instance-id us-west-1))'
-  alias s="%s -e='$smartssh_executor' -d='$smartssh_discoverer' -f='$smartssh_filter'"
+Ideally a single profile in the config file should cover all your use-cases.
+The config file is read from $%s if set, otherwise from %s. For example:
+  (profile default
+    (executor (if-command (ssh-exec-parallel) (if-one-target (ssh-login) (tmux-cssh))))
+    (discoverer (first-matching (knife) (comma-separated)))
+    (filter (list (ec2-instance-id us-east-1) (ec2-instance-id us-west-1))))
+Flags override the settings of the selected profile.
 
 Configuration details:
   open https://github.com/abesto/smartssh/blob/master/README.md#configuration
 
 Options:
-`, os.Args[0], os.Args[0])
+`, os.Args[0], os.Args[0], config.EnvVar, config.Path())
 		flag.PrintDefaults()
 	}
 
 	flag.StringVar(&user, "l", "",
 		"Specifies the user to log in as on the remote machine.")
-	flag.StringVar(&discovererDefinition, "d", "(comma-separated)",
+	flag.StringVar(&discovererDefinition, "d", pipeline.DefaultDiscoverer,
 		fmt.Sprintf("Discoverer definition. Supported discoverers: %s", strings.Join(discoverers.SupportedDiscovererNames(), ", ")))
-	flag.StringVar(&executorDefinition, "e", "(ssh-login)",
+	flag.StringVar(&executorDefinition, "e", pipeline.DefaultExecutor,
 		fmt.Sprintf("Executor definition. Supported executors: %s", strings.Join(executors.SupportedExecutorNames(), ", ")))
-	flag.StringVar(&filterDefinition, "f", "(id)",
+	flag.StringVar(&filterDefinition, "f", pipeline.DefaultFilter,
 		fmt.Sprintf("Filter definition. Supported filters: %s", strings.Join(filters.SupportedFilterNames(), ", ")))
+	flag.StringVar(&profileName, "p", "",
+		fmt.Sprintf("Profile to use from the config file. Defaults to the profile called \"%s\", if it exists.", config.DefaultProfileName))
+	flag.BoolVar(&dryRun, "n", false, "Dry run: print what the executor would run on the targets, without running anything")
+	flag.BoolVar(&dryRun, "dry-run", false, "Alias of -n")
+	jsonPlan := flag.Bool("json", false, "Print the dry run plan as JSON (implies -n)")
+	flag.BoolVar(&cache.Refresh, "refresh", false, "Ignore the results cached by cached discoverers and filters, and refresh them")
 	verbose := flag.Bool("v", false, "Verbose output (alias of '-log debug')")
 	versionRequested := flag.Bool("V", false, "Display the version number and exit")
-	flag.Parse()
+	args := os.Args[1:]
+	explainRequested := len(args) > 0 && args[0] == explainSubcommand
+	if explainRequested {
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
 
 	if *versionRequested {
 		fmt.Printf("easyssh version %s build %s\n", VERSION, BUILD_DATE)
@@ -72,50 +93,104 @@ Options:
 	util.Logger = stdlog.GetFromFlags()
 	logger := util.Logger
 
-	if flag.NArg() == 0 {
+	if flag.NArg() == 0 && !explainRequested {
 		logger.Critical("Required argument for target host lookup missing")
 		flag.Usage()
-		return
+		os.Exit(util.ExitCodeUsage)
 	}
 
-	defer func() {
-		if err := recover(); err != nil {
-			// discoverer, executor and filter are created in this order
-			// if at least one of them is nil, then the creation of the first one that is nil has generated the error.
-			if discoverer == nil {
-				util.Logger.Critical("Failed to create discoverer")
-			} else if executor == nil {
-				util.Logger.Critical("Failed to create executor")
-			} else if filter == nil {
-				util.Logger.Critical("Failed to create filter")
-			}
-			switch err.(type) {
-			case string:
-				util.Logger.Critical(err.(string))
-				os.Exit(1)
-			default:
-				panic(err)
-			}
+	cfg, err := config.Load()
+	if err != nil {
+		fail("Failed to load config file", err)
+	}
+	cfg.DefineMacros()
+	profile, err := cfg.Profile(profileName)
+	if err != nil {
+		fail("Failed to load profile", err)
+	}
+	logger.Debugf("Using profile %s", profile)
+	applyProfile(profile, map[string]*string{
+		"d": &discovererDefinition,
+		"e": &executorDefinition,
+		"f": &filterDefinition,
+		"l": &user,
+	})
+
+	if explainRequested {
+		explanations := selectExplanations([]explanation{
+			{"d", "discoverer", discovererDefinition, func(d string) error { _, err := discoverers.Make(d); return err }},
+			{"f", "filter", filterDefinition, func(d string) error { _, err := filters.Make(d); return err }},
+			{"e", "executor", executorDefinition, func(d string) error { _, err := executors.Make(d); return err }},
+		})
+		allFound, err := explain(os.Stdout, explanations)
+		if err != nil {
+			fail("Failed to explain definitions", err)
 		}
-	}()
-
-	discoverer = discoverers.Make(discovererDefinition)
-	executor = executors.Make(executorDefinition)
-	filter = filters.Make(filterDefinition)
+		if !allFound {
+			os.Exit(util.ExitCodeMissingBinary)
+		}
+		return
+	}
 
-	targets := discoverer.Discover(flag.Arg(0))
-	if len(targets) == 0 {
-		util.Panicf("No targets found")
+	if discoverer, err = discoverers.Make(discovererDefinition); err != nil {
+		fail("Failed to create discoverer", err)
+	}
+	if executor, err = executors.Make(executorDefinition); err != nil {
+		fail("Failed to create executor", err)
+	}
+	if filter, err = filters.Make(filterDefinition); err != nil {
+		fail("Failed to create filter", err)
 	}
 
-	for i := range targets {
-		targets[i].User = user
+	// Let cache entries being refreshed in the background finish before exiting
+	defer cache.Close()
+	p := pipeline.New(discoverer, filter, executor)
+	p.User = user
+	input, command := flag.Arg(0), flag.Args()[1:]
+	if dryRun || *jsonPlan {
+		report, err := p.Plan(input, command)
+		if err != nil {
+			fail("Failed to plan execution", err)
+		}
+		if *jsonPlan {
+			if err := report.WriteJSON(os.Stdout); err != nil {
+				fail("Failed to write plan as JSON", err)
+			}
+		} else {
+			report.WriteText(os.Stdout)
+		}
+		return
+	}
+	if _, err := p.Run(input, command); err != nil {
+		fail("Failed to run", err)
 	}
+}
 
-	logger.Debugf("Targets before filters: %s", targets)
-	targets = filter.Filter(targets)
-	logger.Infof("Targets: %s", targets)
+/*
+fail logs err with some context, and exits with the exit code belonging to err. os.Exit skips deferred calls, so it
+closes the cache itself, so that no cache locks are left behind.
+*/
+func fail(context string, err error) {
+	util.Logger.Criticalf("%s: %s", context, err)
+	cache.Close()
+	os.Exit(util.ExitCode(err))
+}
 
-	command := flag.Args()[1:]
-	executor.Exec(targets, command)
+/*
+applyProfile sets the flags that were not explicitly set on the command line to the values defined in the profile.
+*/
+func applyProfile(profile config.Profile, flags map[string]*string) {
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	values := map[string]string{
+		"d": profile.Discoverer,
+		"e": profile.Executor,
+		"f": profile.Filter,
+		"l": profile.User,
+	}
+	for name, target := range flags {
+		if !explicit[name] && values[name] != "" {
+			*target = values[name]
+		}
+	}
 }
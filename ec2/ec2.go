@@ -0,0 +1,74 @@
+/*
+Package ec2 describes the output of aws ec2 describe-instances, and how instances in it become targets. It's shared by
+the ec2 discoverer and the ec2-instance-id filter.
+*/
+package ec2
+
+import "github.com/abesto/easyssh/target"
+
+type Instance struct {
+	InstanceId       string
+	InstanceType     string
+	PublicDnsName    string
+	PublicIpAddress  string
+	PrivateDnsName   string
+	PrivateIpAddress string
+	VpcId            string
+	SubnetId         string
+	State            State
+	Placement        Placement
+	Tags             []Tag
+}
+
+type State struct {
+	Name string
+}
+
+type Placement struct {
+	AvailabilityZone string
+}
+
+type Tag struct {
+	Key   string
+	Value string
+}
+
+type Reservation struct {
+	Instances []Instance
+}
+
+type DescribeInstancesResponse struct {
+	Reservations []Reservation
+}
+
+/*
+SetAddress points t at the public DNS name and IP address of instance, or at the private ones if the instance doesn't
+have a public IP address. Returns whether the public address was used.
+*/
+func SetAddress(t *target.Target, instance Instance) bool {
+	if instance.PublicIpAddress != "" {
+		t.IP = instance.PublicIpAddress
+		t.Host = instance.PublicDnsName
+		return true
+	}
+	t.IP = instance.PrivateIpAddress
+	t.Host = instance.PrivateDnsName
+	return false
+}
+
+// SetLabels records the identity, addresses, placement and tags of instance on t
+func SetLabels(t *target.Target, region string, instance Instance) {
+	t.SetLabel("region", region)
+	t.SetLabel("zone", instance.Placement.AvailabilityZone)
+	t.SetLabel("ec2.instance_id", instance.InstanceId)
+	t.SetLabel("ec2.instance_type", instance.InstanceType)
+	t.SetLabel("ec2.vpc_id", instance.VpcId)
+	t.SetLabel("ec2.subnet_id", instance.SubnetId)
+	t.SetLabel("ec2.public_ip", instance.PublicIpAddress)
+	t.SetLabel("ec2.public_dns", instance.PublicDnsName)
+	t.SetLabel("ec2.private_ip", instance.PrivateIpAddress)
+	t.SetLabel("ec2.private_dns", instance.PrivateDnsName)
+	for _, tag := range instance.Tags {
+		t.SetLabel("ec2.tag."+tag.Key, tag.Value)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/plan"
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
@@ -14,34 +15,66 @@ type assertCommand struct {
 	child       interfaces.Executor
 }
 
-func (e *assertCommand) Exec(targets []target.Target, command []string) {
-	util.RequireArguments(e, 1, e.initialArgs)
+func (e *assertCommand) check(command []string) error {
+	if err := util.RequireArguments(e, 1, e.initialArgs); err != nil {
+		return err
+	}
 	if e.require {
 		if len(command) == 0 {
-			util.Panicf("%s requires a command.", e)
+			return util.UsageErrorf("%s requires a command.", e)
 		}
 	} else {
 		if len(command) > 0 {
-			util.Panicf("%s doesn't accept a command, got: %s", e, command)
+			return util.UsageErrorf("%s doesn't accept a command, got: %s", e, command)
 		}
 	}
-	e.child.Exec(targets, command)
+	return nil
 }
 
-func (e *assertCommand) SetArgs(args []interface{}) {
-	util.RequireArguments(e, 1, args)
+func (e *assertCommand) Exec(targets []target.Target, command []string) error {
+	if err := e.check(command); err != nil {
+		return err
+	}
+	return e.child.Exec(targets, command)
+}
+
+func (e *assertCommand) Plan(targets []target.Target, command []string) (plan.Plan, error) {
+	if err := e.check(command); err != nil {
+		return plan.Plan{}, err
+	}
+	child, err := e.child.Plan(targets, command)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	return plan.Plan{Executor: e.name(), Children: []plan.Plan{child}}, nil
+}
+
+func (e *assertCommand) SetArgs(args []interface{}) error {
+	if err := util.RequireArguments(e, 1, args); err != nil {
+		return err
+	}
+	child, err := makeFromSExp(args[0])
+	if err != nil {
+		return err
+	}
 	e.initialArgs = args
-	e.child = makeFromSExp(args[0].([]interface{}))
+	e.child = child
+	return nil
 }
 
-func (e *assertCommand) String() string {
-	var rawName string
+func (e *assertCommand) Children() []interface{} {
+	return []interface{}{e.child}
+}
+
+func (e *assertCommand) name() string {
 	if e.require {
-		rawName = nameAssertCommand
-	} else {
-		rawName = nameAssertNoCommand
+		return nameAssertCommand
 	}
-	return fmt.Sprintf("<%s %v>", rawName, e.child)
+	return nameAssertNoCommand
+}
+
+func (e *assertCommand) String() string {
+	return fmt.Sprintf("<%s %v>", e.name(), e.child)
 }
 
 type externalMode byte
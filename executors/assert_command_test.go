@@ -2,8 +2,10 @@ package executors
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
+	"github.com/abesto/easyssh/plan"
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
@@ -26,7 +28,7 @@ func TestAssertCommandStringViaMake(t *testing.T) {
 			l.ExpectDebugf("MakeFromString %s -> %s", input, structs)
 			l.ExpectDebugf("Make %s -> %s", "[external-sequential ssh]", "<external-sequential [ssh]>")
 			l.ExpectDebugf("Make %s -> %s", structs, final)
-			Make(input)
+			mustMake(t, input)
 		})
 	}
 }
@@ -35,8 +37,8 @@ func TestAssertCommandMakeWithoutArgument(t *testing.T) {
 	for _, name := range assertCommandNames {
 		util.WithLogAssertions(t, func(l *util.MockLogger) {
 			l.ExpectDebugf("MakeFromString %s -> %s", fmt.Sprintf("(%s)", name), fmt.Sprintf("[%s]", name))
-			util.ExpectPanic(t, fmt.Sprintf("<%s %v> requires exactly 1 argument(s), got 0: []", name, nil),
-				func() { Make(fmt.Sprintf("(%s)", name)) })
+			_, err := Make(fmt.Sprintf("(%s)", name))
+			util.ExpectError(t, fmt.Sprintf("<%s %v> requires exactly 1 argument(s), got 0: [] in (%s) at position 0", name, nil, name), err)
 		})
 	}
 }
@@ -45,8 +47,8 @@ func TestAssertCommandMakeWithTooManyArguments(t *testing.T) {
 	for _, name := range assertCommandNames {
 		util.WithLogAssertions(t, func(l *util.MockLogger) {
 			l.ExpectDebugf("MakeFromString %s -> %s", fmt.Sprintf("(%s foo bar)", name), fmt.Sprintf("[%s foo bar]", name))
-			util.ExpectPanic(t, fmt.Sprintf("<%s %v> requires exactly 1 argument(s), got 2: [foo bar]", name, nil),
-				func() { Make(fmt.Sprintf("(%s foo bar)", name)) })
+			_, err := Make(fmt.Sprintf("(%s foo bar)", name))
+			util.ExpectError(t, fmt.Sprintf("<%s %v> requires exactly 1 argument(s), got 2: [foo bar] in (%s foo bar) at position 0", name, nil, name), err)
 		})
 	}
 }
@@ -54,8 +56,7 @@ func TestAssertCommandMakeWithTooManyArguments(t *testing.T) {
 func TestAssertCommandExecWithoutSetArgs(t *testing.T) {
 	for _, item := range assertCommandNamesWithRequire {
 		util.WithLogAssertions(t, func(l *util.MockLogger) {
-			util.ExpectPanic(t, fmt.Sprintf("<%s %v> requires exactly 1 argument(s), got 0: []", item.name, nil),
-				func() { (&assertCommand{require: item.require}).Exec([]target.Target{}, []string{}) })
+			util.ExpectError(t, fmt.Sprintf("<%s %v> requires exactly 1 argument(s), got 0: []", item.name, nil), (&assertCommand{require: item.require}).Exec([]target.Target{}, []string{}))
 		})
 	}
 }
@@ -63,7 +64,7 @@ func TestAssertCommandExecWithoutSetArgs(t *testing.T) {
 func TestAssertCommandSetArgs(t *testing.T) {
 	for _, item := range assertCommandNamesWithRequire {
 		input := fmt.Sprintf("(%s (ssh-exec))", item.name)
-		e := Make(input).(*assertCommand)
+		e := mustMake(t, input).(*assertCommand)
 		if e.require != item.require {
 			t.Error("require")
 		}
@@ -78,46 +79,67 @@ func TestAssertCommandSetArgs(t *testing.T) {
 
 func TestAssertCommandGetsCommand(t *testing.T) {
 	withMockInMakerMap(func() {
-		e := Make("(assert-command (mock))").(*assertCommand)
-		targets := target.FromStrings("foo", "bar")
+		e := mustMake(t, "(assert-command (mock))").(*assertCommand)
+		targets := target.MustFromStrings("foo", "bar")
 		command := []string{"ssh", "-l", "root"}
 
 		m := e.child.(*mockExecutor)
 		m.On("Exec", targets, command).Times(1)
 
-		e.Exec(targets, command)
+		util.ExpectNoError(t, e.Exec(targets, command))
 		m.AssertExpectations(t)
 	})
 }
 
 func TestAssertCommandGetsNoCommand(t *testing.T) {
 	withMockInMakerMap(func() {
-		e := Make("(assert-command (mock))").(*assertCommand)
-		targets := target.FromStrings("foo", "bar")
+		e := mustMake(t, "(assert-command (mock))").(*assertCommand)
+		targets := target.MustFromStrings("foo", "bar")
 		command := []string{}
-		util.ExpectPanic(t, "<assert-command <mock>> requires a command.", func() { e.Exec(targets, command) })
+		util.ExpectError(t, "<assert-command <mock>> requires a command.", e.Exec(targets, command))
 	})
 }
 
 func TestAssertNoCommandGetsNoCommand(t *testing.T) {
 	withMockInMakerMap(func() {
-		e := Make("(assert-no-command (mock))").(*assertCommand)
-		targets := target.FromStrings("foo", "bar")
+		e := mustMake(t, "(assert-no-command (mock))").(*assertCommand)
+		targets := target.MustFromStrings("foo", "bar")
 		command := []string{}
 
 		m := e.child.(*mockExecutor)
 		m.On("Exec", targets, command).Times(1)
 
-		e.Exec(targets, command)
+		util.ExpectNoError(t, e.Exec(targets, command))
 		m.AssertExpectations(t)
 	})
 }
 
 func TestAssertNoCommandGetsCommand(t *testing.T) {
 	withMockInMakerMap(func() {
-		e := Make("(assert-no-command (mock))").(*assertCommand)
-		targets := target.FromStrings("foo", "bar")
+		e := mustMake(t, "(assert-no-command (mock))").(*assertCommand)
+		targets := target.MustFromStrings("foo", "bar")
 		command := []string{"ssh", "-l", "root"}
-		util.ExpectPanic(t, "<assert-no-command <mock>> doesn't accept a command, got: [ssh -l root]", func() { e.Exec(targets, command) })
+		util.ExpectError(t, "<assert-no-command <mock>> doesn't accept a command, got: [ssh -l root]", e.Exec(targets, command))
+	})
+}
+
+func TestAssertCommandPlan(t *testing.T) {
+	withMockInMakerMap(func() {
+		e := mustMake(t, "(assert-command (mock))").(*assertCommand)
+		targets := target.MustFromStrings("foo", "bar")
+		command := []string{"uptime"}
+
+		m := e.child.(*mockExecutor)
+		childPlan := plan.Plan{Executor: "mock"}
+		m.On("Plan", targets, command).Return(childPlan).Times(1)
+
+		expected := plan.Plan{Executor: nameAssertCommand, Children: []plan.Plan{childPlan}}
+		if actual := mustPlan(t, e, targets, command); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected plan %v, got %v", expected, actual)
+		}
+		m.AssertExpectations(t)
+
+		_, err := e.Plan(targets, []string{})
+		util.ExpectError(t, "<assert-command <mock>> requires a command.", err)
 	})
 }
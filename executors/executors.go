@@ -1,16 +1,37 @@
 package executors
 
 import (
+	"fmt"
 	"sort"
 
 	"github.com/abesto/easyssh/fromsexp"
 	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/plugin"
 	"github.com/abesto/easyssh/util"
 )
 
-// Make creates an Executor by name
-func Make(input string) interfaces.Executor {
-	return fromsexp.MakeFromString(input, sexpTransforms, makeByName).(interfaces.Executor)
+// Make creates an Executor by name. It's not safe for concurrent use, see fromsexp.Exclusive.
+func Make(input string) (interfaces.Executor, error) {
+	e, err := fromsexp.MakeFromString(input, sexpTransforms, makeByName)
+	if err != nil {
+		return nil, err
+	}
+	return e.(interfaces.Executor), nil
+}
+
+/*
+Register makes an executor available to Make under name, next to the built-in ones. maker must return a new,
+unconfigured instance on each call; Make calls SetArgs on it. Register is not safe to call concurrently with Make,
+so it's best called from an init function.
+*/
+func Register(name string, maker func() interfaces.Executor) error {
+	for _, existing := range SupportedExecutorNames() {
+		if existing == name {
+			return fmt.Errorf("Executor \"%s\" is already defined", name)
+		}
+	}
+	executorMakerMap[name] = maker
+	return nil
 }
 
 // SupportedExecutorNames returns the names Make can take
@@ -33,8 +54,16 @@ func SupportedExecutorNames() []string {
 	return names
 }
 
-func makeFromSExp(data []interface{}) interfaces.Executor {
-	return fromsexp.Make(data, sexpTransforms, makeByName).(interfaces.Executor)
+func makeFromSExp(data interface{}) (interfaces.Executor, error) {
+	list, err := util.ListArg(data)
+	if err != nil {
+		return nil, err
+	}
+	e, err := fromsexp.Make(list, sexpTransforms, makeByName)
+	if err != nil {
+		return nil, err
+	}
+	return e.(interfaces.Executor), nil
 }
 
 const (
@@ -97,9 +126,15 @@ var sexpTransforms = []fromsexp.SexpTransform{
 	r("(ssh-exec-parallel)", "(assert-command (external-parallel ssh))"),
 	r("(csshx)", "(assert-no-command (external-interactive csshx))"),
 	r("(tmux-cssh)", "(assert-no-command (external-interactive tmux-cssh -ns))"),
+	r("(kubectl-login)", "(assert-no-command (external-sequential-interactive kubectl exec -it))"),
+	r("(kubectl-exec)", "(assert-command (external-sequential kubectl exec))"),
+	r("(kubectl-exec-parallel)", "(assert-command (external-parallel kubectl exec))"),
+	r("(docker-login)", "(assert-no-command (external-sequential-interactive docker exec -it))"),
+	r("(docker-exec)", "(assert-command (external-sequential docker exec))"),
+	r("(docker-exec-parallel)", "(assert-command (external-parallel docker exec))"),
 }
 
-func makeByName(name string) interface{} {
+func makeByName(name string) (interface{}, error) {
 	var d interfaces.Executor
 	for key, maker := range executorMakerMap {
 		if key == name {
@@ -107,7 +142,12 @@ func makeByName(name string) interface{} {
 		}
 	}
 	if d == nil {
-		util.Panicf("Executor \"%s\" is not known", name)
+		if path, ok := plugin.Lookup(plugin.KindExecutor, name); ok {
+			return plugin.NewExecutor(name, path), nil
+		}
+		err := util.ParseErrorf("Executor \"%s\" is not known", name)
+		err.Suggestion = util.Suggest(name, append(SupportedExecutorNames(), plugin.Names(plugin.KindExecutor)...))
+		return nil, err
 	}
-	return d
+	return d, nil
 }
@@ -1,17 +1,64 @@
 package executors
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/plan"
+	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
 
+func mustMake(t *testing.T, input string) interfaces.Executor {
+	e, err := Make(input)
+	util.ExpectNoError(t, err)
+	return e
+}
+
+func mustPlan(t *testing.T, e interfaces.Executor, targets []target.Target, command []string) plan.Plan {
+	p, err := e.Plan(targets, command)
+	util.ExpectNoError(t, err)
+	return p
+}
+
 func TestSupportedExecutorNames(t *testing.T) {
 	util.AssertStringListEquals(t,
-		[]string{"assert-command", "assert-no-command", "csshx", "external",
+		[]string{"assert-command", "assert-no-command", "csshx", "docker-exec",
+			"docker-exec-parallel", "docker-login", "external",
 			"external-interactive", "external-parallel", "external-sequential",
 			"external-sequential-interactive", "if-args", "if-command", "if-one-target",
-			"ssh-exec", "ssh-exec-parallel", "ssh-exec-sequential", "ssh-login",
+			"kubectl-exec", "kubectl-exec-parallel", "kubectl-login", "ssh-exec", "ssh-exec-parallel", "ssh-exec-sequential", "ssh-login",
 			"tmux-cssh"},
 		SupportedExecutorNames())
 }
+
+func TestMakeUnknownExecutor(t *testing.T) {
+	_, err := Make("(if-command (ssh-exec-paralel) (ssh-login))")
+	util.ExpectError(t, "Executor \"ssh-exec-paralel\" is not known in (ssh-exec-paralel) at position 12; did you mean ssh-exec-parallel?", err)
+}
+
+func TestExecPropagatesErrors(t *testing.T) {
+	withMockInMakerMap(func() {
+		e := mustMake(t, "(if-command (mock) (mock))").(*ifCommand)
+		targets := target.MustFromStrings("foo")
+		command := []string{"uptime"}
+		expected := errors.New("child failed")
+		e.withCommand.(*mockExecutor).On("Exec", targets, command).Return(expected).Times(1)
+		if err := e.Exec(targets, command); err != expected {
+			t.Errorf("Expected error %v, got %v", expected, err)
+		}
+	})
+}
+
+func TestRegister(t *testing.T) {
+	util.ExpectNoError(t, Register("test-registered", func() interfaces.Executor { return &mockExecutor{} }))
+	defer delete(executorMakerMap, "test-registered")
+
+	e := mustMake(t, "(assert-command (test-registered))").(*assertCommand)
+	if _, ok := e.child.(*mockExecutor); !ok {
+		t.Errorf("Unexpected child %v", e.child)
+	}
+
+	util.ExpectError(t, "Executor \"ssh-login\" is already defined", Register("ssh-login", func() interfaces.Executor { return &mockExecutor{} }))
+}
@@ -2,8 +2,11 @@ package executors
 
 import (
 	"fmt"
+	"path/filepath"
+	"reflect"
 	"strings"
 
+	"github.com/abesto/easyssh/plan"
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
@@ -20,53 +23,149 @@ type external struct {
 	commandRunner util.InteractiveCommandRunner
 	mode          externalMode
 	interactive   bool
+	tool          externalTool
 }
 
-func (e *external) makeSingleRunJob(targets []target.Target, command []string) util.InteractiveCommandRunnerJob {
+/*
+toolArgs returns the arguments to run the tool with for targets: the arguments of e, with the global arguments the tool
+needs for the targets inserted after its name. A single run can only reach targets that need the same global
+arguments, like containers in the same docker context.
+*/
+func (e *external) toolArgs(targets []target.Target) ([]string, error) {
+	var global []string
+	for i, t := range targets {
+		args := e.tool.globalArgs(t)
+		if i > 0 && !reflect.DeepEqual(args, global) {
+			return nil, util.UsageErrorf("%s can't reach %s and %s in a single run, they need different arguments %s and %s",
+				filepath.Base(e.args[0]), targets[0].FriendlyName(), t.FriendlyName(), global, args)
+		}
+		global = args
+	}
+	return append(append([]string{e.args[0]}, global...), e.args[1:]...), nil
+}
+
+func (e *external) makeSingleRunJob(targets []target.Target, command []string) (util.InteractiveCommandRunnerJob, error) {
+	argv, err := e.toolArgs(targets)
+	if err != nil {
+		return util.InteractiveCommandRunnerJob{}, err
+	}
+	for _, t := range targets {
+		args, err := e.tool.targetArgs(t)
+		if err != nil {
+			return util.InteractiveCommandRunnerJob{}, err
+		}
+		argv = append(argv, args...)
+	}
 	return util.InteractiveCommandRunnerJob{
 		Interactive: e.interactive,
 		Label:       strings.Join(target.FriendlyNames(targets), " "),
-		Argv:        append(e.args, append(target.SSHTargets(targets), command...)...),
-	}
+		Argv:        append(argv, e.tool.commandArgs(command)...),
+	}, nil
 }
 
-func (e *external) makeJobPerTarget(targets []target.Target, command []string) []util.InteractiveCommandRunnerJob {
+func (e *external) makeJobPerTarget(targets []target.Target, command []string) ([]util.InteractiveCommandRunnerJob, error) {
 	jobs := make([]util.InteractiveCommandRunnerJob, len(targets))
-	for i, target := range targets {
+	for i, t := range targets {
+		argv, err := e.toolArgs([]target.Target{t})
+		if err != nil {
+			return nil, err
+		}
+		args, err := e.tool.targetArgs(t)
+		if err != nil {
+			return nil, err
+		}
 		jobs[i] = util.InteractiveCommandRunnerJob{
 			Interactive: e.interactive,
-			Label:       target.FriendlyName(),
-			Argv:        append(e.args, append([]string{target.SSHTarget()}, command...)...),
+			Label:       t.FriendlyName(),
+			Argv:        append(append(argv, args...), e.tool.commandArgs(command)...),
 		}
 	}
-	return jobs
+	return jobs, nil
 }
 
-func (e *external) Exec(targets []target.Target, command []string) {
-	util.RequireArgumentsAtLeast(e, 1, e.initialArgs)
+func (e *external) Exec(targets []target.Target, command []string) error {
+	if err := util.RequireArgumentsAtLeast(e, 1, e.initialArgs); err != nil {
+		return err
+	}
 	if e.mode == externalModeSingleRun {
-		e.commandRunner.Run(e.makeSingleRunJob(targets, command))
-	} else if e.mode == externalModeSequential {
-		for _, job := range e.makeJobPerTarget(targets, command) {
-			e.commandRunner.Run(job)
+		job, err := e.makeSingleRunJob(targets, command)
+		if err != nil {
+			return err
 		}
+		return e.commandRunner.Run(job)
+	}
+	jobs, err := e.makeJobPerTarget(targets, command)
+	if err != nil {
+		return err
+	}
+	if e.mode == externalModeSequential {
+		// Stop at the first failure, so that a broken step of something like a rolling restart doesn't spread
+		for _, job := range jobs {
+			if err := e.commandRunner.Run(job); err != nil {
+				return err
+			}
+		}
+		return nil
 	} else if e.mode == externalModeParallel {
 		util.Logger.Infof("Parallelly executing %s on %s", command, targets)
-		e.commandRunner.RunParallel(e.makeJobPerTarget(targets, command))
+		return e.commandRunner.RunParallel(jobs)
+	}
+	return fmt.Errorf("Unknown externalMode %v", e.mode)
+}
+
+func (e *external) Plan(targets []target.Target, command []string) (plan.Plan, error) {
+	if err := util.RequireArgumentsAtLeast(e, 1, e.initialArgs); err != nil {
+		return plan.Plan{}, err
+	}
+	p := plan.Plan{Executor: e.name()}
+	var err error
+	if e.mode == externalModeSingleRun {
+		p.Mode = plan.ModeSingle
+		var job util.InteractiveCommandRunnerJob
+		job, err = e.makeSingleRunJob(targets, command)
+		p.Jobs = []util.InteractiveCommandRunnerJob{job}
+	} else if e.mode == externalModeSequential {
+		p.Mode = plan.ModeSequential
+		p.Jobs, err = e.makeJobPerTarget(targets, command)
+	} else if e.mode == externalModeParallel {
+		p.Mode = plan.ModeParallel
+		p.Jobs, err = e.makeJobPerTarget(targets, command)
 	} else {
-		util.Panicf("Unknown externalMode %v", e.mode)
+		return p, fmt.Errorf("Unknown externalMode %v", e.mode)
 	}
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	return p, nil
 }
 
-func (e *external) SetArgs(args []interface{}) {
-	util.RequireArgumentsAtLeast(e, 1, args)
+func (e *external) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(e, 1, args); err != nil {
+		return err
+	}
+	strs, err := util.ByteToStringArray(args)
+	if err != nil {
+		return err
+	}
 	e.initialArgs = args
-	e.args = util.ByteToStringArray(args)
-	util.RequireOnPath(e, e.args[0])
+	e.args = strs
+	e.tool = toolFor(strs[0])
+	return util.RequireOnPath(e, e.args[0])
 }
 
-func (e *external) String() string {
-	rawName := "external"
+func (e *external) SetInteractiveCommandRunner(r util.InteractiveCommandRunner) {
+	e.commandRunner = r
+}
+
+func (e *external) RequiredBinaries() []string {
+	if len(e.args) == 0 {
+		return []string{}
+	}
+	return e.args[:1]
+}
+
+func (e *external) name() string {
+	rawName := nameExternal
 	if e.mode == externalModeSequential {
 		rawName += "-sequential"
 	} else if e.mode == externalModeParallel {
@@ -75,5 +174,9 @@ func (e *external) String() string {
 	if e.interactive {
 		rawName += "-interactive"
 	}
-	return fmt.Sprintf("<%s %s>", rawName, e.args)
+	return rawName
+}
+
+func (e *external) String() string {
+	return fmt.Sprintf("<%s %s>", e.name(), e.args)
 }
@@ -1,9 +1,12 @@
 package executors
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 
+	"github.com/abesto/easyssh/plan"
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
@@ -20,6 +23,18 @@ var externalDefs = []struct {
 	{nameExternalSequentialInteractive, externalModeSequential, true},
 }
 
+func mustMakeSingleRunJob(t *testing.T, e *external, targets []target.Target, command []string) util.InteractiveCommandRunnerJob {
+	job, err := e.makeSingleRunJob(targets, command)
+	util.ExpectNoError(t, err)
+	return job
+}
+
+func mustMakeJobPerTarget(t *testing.T, e *external, targets []target.Target, command []string) []util.InteractiveCommandRunnerJob {
+	jobs, err := e.makeJobPerTarget(targets, command)
+	util.ExpectNoError(t, err)
+	return jobs
+}
+
 func TestExternalStringViaMake(t *testing.T) {
 	for _, item := range externalDefs {
 		name := item.name
@@ -29,7 +44,7 @@ func TestExternalStringViaMake(t *testing.T) {
 			final := fmt.Sprintf("<%s [ssh]>", name)
 			l.ExpectDebugf("MakeFromString %s -> %s", input, structs)
 			l.ExpectDebugf("Make %s -> %s", structs, final)
-			executor := Make(input).(*external)
+			executor := mustMake(t, input).(*external)
 			if executor.interactive != item.interactive {
 				t.Errorf("executor.interactive is %t for %s, expected %t", executor.interactive, name, item.interactive)
 			}
@@ -45,8 +60,8 @@ func TestExternalMakeWithoutArgument(t *testing.T) {
 		name := item.name
 		util.WithLogAssertions(t, func(l *util.MockLogger) {
 			l.ExpectDebugf("MakeFromString %s -> %s", fmt.Sprintf("(%s)", name), fmt.Sprintf("[%s]", name))
-			util.ExpectPanic(t, fmt.Sprintf("<%s %s> requires at least 1 argument(s), got 0: []", name, []string{}),
-				func() { Make(fmt.Sprintf("(%s)", name)) })
+			_, err := Make(fmt.Sprintf("(%s)", name))
+			util.ExpectError(t, fmt.Sprintf("<%s %s> requires at least 1 argument(s), got 0: [] in (%s) at position 0", name, []string{}, name), err)
 		})
 	}
 }
@@ -55,10 +70,7 @@ func TestExternalExecWithoutSetArgs(t *testing.T) {
 	for _, item := range externalDefs {
 		name := item.name
 		util.WithLogAssertions(t, func(l *util.MockLogger) {
-			util.ExpectPanic(t, fmt.Sprintf("<%s %s> requires at least 1 argument(s), got 0: []", name, []string{}),
-				func() {
-					(&external{mode: item.mode, interactive: item.interactive}).Exec([]target.Target{}, []string{})
-				})
+			util.ExpectError(t, fmt.Sprintf("<%s %s> requires at least 1 argument(s), got 0: []", name, []string{}), (&external{mode: item.mode, interactive: item.interactive}).Exec([]target.Target{}, []string{}))
 		})
 	}
 }
@@ -66,7 +78,7 @@ func TestExternalExecWithoutSetArgs(t *testing.T) {
 func TestExternalSetArgs(t *testing.T) {
 	for _, item := range externalDefs {
 		input := fmt.Sprintf("(%s ssh)", item.name)
-		e := Make(input).(*external)
+		e := mustMake(t, input).(*external)
 		if fmt.Sprintf("%s", e.initialArgs) != "[ssh]" {
 			t.Error("initialArgs", e.initialArgs)
 		}
@@ -74,11 +86,11 @@ func TestExternalSetArgs(t *testing.T) {
 }
 
 func TestExternalMakeSingleRunJob(t *testing.T) {
-	targets := target.FromStrings("foo", "bar")
+	targets := target.MustFromStrings("foo", "bar")
 	command := []string{"ls"}
 	for _, item := range externalDefs {
-		executor := Make(fmt.Sprintf("(%s csshx -l root)", item.name)).(*external)
-		job := executor.makeSingleRunJob(targets, command)
+		executor := mustMake(t, fmt.Sprintf("(%s csshx -l root)", item.name)).(*external)
+		job := mustMakeSingleRunJob(t, executor, targets, command)
 		if job.Interactive != item.interactive {
 			t.Errorf("job.Interactive for output of %s.makeSingleRunJob is %t, expected %t", item.name, job.Interactive, item.interactive)
 		}
@@ -92,11 +104,11 @@ func TestExternalMakeSingleRunJob(t *testing.T) {
 }
 
 func TestExternalMakeJobPerTarget(t *testing.T) {
-	targets := target.FromStrings("foo", "bar")
+	targets := target.MustFromStrings("foo", "bar")
 	command := []string{"ls"}
 	for _, item := range externalDefs {
-		executor := Make(fmt.Sprintf("(%s ssh)", item.name)).(*external)
-		jobs := executor.makeJobPerTarget(targets, command)
+		executor := mustMake(t, fmt.Sprintf("(%s ssh)", item.name)).(*external)
+		jobs := mustMakeJobPerTarget(t, executor, targets, command)
 		if len(jobs) != len(targets) {
 			t.Errorf("Expected to get %d jobs from %s, got %d instead: %v", len(targets), item.name, len(jobs), jobs)
 		}
@@ -115,38 +127,223 @@ func TestExternalMakeJobPerTarget(t *testing.T) {
 	}
 }
 
+func TestExternalTargetArgsWithPort(t *testing.T) {
+	targets := target.MustFromStrings("root@foo:2222", "[fe80::1]:2200", "bar")
+	cases := []struct {
+		binary   string
+		expected [][]string
+	}{
+		{"ssh", [][]string{{"-p", "2222", "root@foo"}, {"-p", "2200", "fe80::1"}, {"bar"}}},
+		{"/usr/bin/scp", [][]string{{"-P", "2222", "root@foo"}, {"-P", "2200", "[fe80::1]"}, {"bar"}}},
+		{"sftp", [][]string{{"-P", "2222", "root@foo"}, {"-P", "2200", "[fe80::1]"}, {"bar"}}},
+		{"csshx", [][]string{{"root@foo:2222"}, {"[fe80::1]:2200"}, {"bar"}}},
+		{"tmux-cssh", [][]string{{"ssh://root@foo:2222"}, {"ssh://[fe80::1]:2200"}, {"bar"}}},
+	}
+	for _, c := range cases {
+		tool := toolFor(c.binary)
+		for i, target := range targets {
+			args, err := tool.targetArgs(target)
+			util.ExpectNoError(t, err)
+			util.AssertStringListEquals(t, c.expected[i], args)
+		}
+	}
+
+	e := mustMake(t, "(external tmux-cssh -ns)").(*external)
+	util.AssertStringListEquals(t, []string{"tmux-cssh", "-ns", "ssh://root@foo:2222", "ssh://[fe80::1]:2200", "bar"},
+		mustMakeSingleRunJob(t, e, targets, []string{}).Argv)
+	e = mustMake(t, "(external-parallel ssh -t)").(*external)
+	jobs := mustMakeJobPerTarget(t, e, targets, []string{"uptime"})
+	util.AssertStringListEquals(t, []string{"ssh", "-t", "-p", "2222", "root@foo", "uptime"}, jobs[0].Argv)
+	util.AssertStringListEquals(t, []string{"ssh", "-t", "bar", "uptime"}, jobs[2].Argv)
+	if jobs[1].Label != "[fe80::1]:2200" {
+		t.Errorf("Unexpected label %s", jobs[1].Label)
+	}
+}
+
+func TestExternalTargetArgsWithProxyJump(t *testing.T) {
+	jumped := target.MustFromString("root@10.0.0.1:2222")
+	jumped.ProxyJump = []string{"bastion", "admin@inner:2200"}
+	for binary, expected := range map[string][]string{
+		"ssh":  {"-J", "bastion,admin@inner:2200", "-p", "2222", "root@10.0.0.1"},
+		"scp":  {"-J", "bastion,admin@inner:2200", "-P", "2222", "root@10.0.0.1"},
+		"sftp": {"-J", "bastion,admin@inner:2200", "-P", "2222", "root@10.0.0.1"},
+	} {
+		args, err := toolFor(binary).targetArgs(jumped)
+		util.ExpectNoError(t, err)
+		util.AssertStringListEquals(t, expected, args)
+	}
+
+	e := mustMake(t, "(external tmux-cssh -ns)").(*external)
+	_, err := e.Plan([]target.Target{jumped}, []string{})
+	util.ExpectError(t, "tmux-cssh can't connect to root@10.0.0.1:2222 through jump hosts [bastion admin@inner:2200]; only ssh, scp and sftp can", err)
+	e = mustMake(t, "(external-sequential ssh)").(*external)
+	m := &util.MockInteractiveCommandRunner{}
+	e.commandRunner = m
+	m.On("Run", util.InteractiveCommandRunnerJob{
+		Label: "root@10.0.0.1:2222", Argv: []string{"ssh", "-J", "bastion,admin@inner:2200", "-p", "2222", "root@10.0.0.1", "id"},
+	}).Return(nil).Times(1)
+	util.ExpectNoError(t, e.Exec([]target.Target{jumped}, []string{"id"}))
+	m.AssertExpectations(t)
+}
+
+func TestExternalKubectl(t *testing.T) {
+	pod := target.Target{Host: "web-1", IP: "10.1.0.5", Labels: map[string]string{
+		"kubernetes.context": "prod", "kubernetes.namespace": "shop", "kubernetes.pod": "web-1", "kubernetes.container": "app"}}
+	bare := target.Target{Host: "web-2"}
+	cases := []struct {
+		executor string
+		command  []string
+		mode     string
+		expected [][]string
+	}{
+		{"(kubectl-exec)", []string{"uptime"}, plan.ModeSequential, [][]string{
+			{"kubectl", "exec", "--context", "prod", "-n", "shop", "-c", "app", "web-1", "--", "uptime"},
+			{"kubectl", "exec", "web-2", "--", "uptime"},
+		}},
+		{"(kubectl-exec-parallel)", []string{"ls", "-l"}, plan.ModeParallel, [][]string{
+			{"kubectl", "exec", "--context", "prod", "-n", "shop", "-c", "app", "web-1", "--", "ls", "-l"},
+			{"kubectl", "exec", "web-2", "--", "ls", "-l"},
+		}},
+		{"(kubectl-login)", []string{}, plan.ModeSequential, [][]string{
+			{"kubectl", "exec", "-it", "--context", "prod", "-n", "shop", "-c", "app", "web-1", "--", "sh"},
+			{"kubectl", "exec", "-it", "web-2", "--", "sh"},
+		}},
+	}
+	for _, c := range cases {
+		p := mustPlan(t, mustMake(t, c.executor), []target.Target{pod, bare}, c.command).Children[0]
+		if p.Mode != c.mode {
+			t.Errorf("%s planned mode %s, expected %s", c.executor, p.Mode, c.mode)
+		}
+		for i, job := range p.Jobs {
+			util.AssertStringListEquals(t, c.expected[i], job.Argv)
+		}
+	}
+}
+
+func TestExternalDocker(t *testing.T) {
+	container := target.Target{Host: "shop-web-1", IP: "172.18.0.2", User: "www-data", Labels: map[string]string{
+		"docker.context": "staging", "docker.container": "shop-web-1"}}
+	bare := target.Target{Host: "db"}
+	cases := []struct {
+		executor string
+		command  []string
+		mode     string
+		expected [][]string
+	}{
+		{"(docker-exec)", []string{"uptime"}, plan.ModeSequential, [][]string{
+			{"docker", "--context", "staging", "exec", "-u", "www-data", "shop-web-1", "uptime"},
+			{"docker", "exec", "db", "uptime"},
+		}},
+		{"(docker-exec-parallel)", []string{"ls", "-l"}, plan.ModeParallel, [][]string{
+			{"docker", "--context", "staging", "exec", "-u", "www-data", "shop-web-1", "ls", "-l"},
+			{"docker", "exec", "db", "ls", "-l"},
+		}},
+		{"(docker-login)", []string{}, plan.ModeSequential, [][]string{
+			{"docker", "--context", "staging", "exec", "-it", "-u", "www-data", "shop-web-1", "sh"},
+			{"docker", "exec", "-it", "db", "sh"},
+		}},
+	}
+	for _, c := range cases {
+		p := mustPlan(t, mustMake(t, c.executor), []target.Target{container, bare}, c.command).Children[0]
+		if p.Mode != c.mode {
+			t.Errorf("%s planned mode %s, expected %s", c.executor, p.Mode, c.mode)
+		}
+		for i, job := range p.Jobs {
+			util.AssertStringListEquals(t, c.expected[i], job.Argv)
+		}
+	}
+}
+
+func TestExternalDockerSingleRun(t *testing.T) {
+	staging := target.Target{Host: "web", Labels: map[string]string{"docker.context": "staging"}}
+	e := mustMake(t, "(external docker exec)").(*external)
+	util.AssertStringListEquals(t, []string{"docker", "--context", "staging", "exec", "web", "web", "uptime"},
+		mustMakeSingleRunJob(t, e, []target.Target{staging, staging}, []string{"uptime"}).Argv)
+
+	_, err := e.makeSingleRunJob([]target.Target{staging, {Host: "db"}}, []string{"uptime"})
+	util.ExpectError(t, "docker can't reach web and db in a single run, they need different arguments [--context staging] and []", err)
+}
+
 func TestExternalExec(t *testing.T) {
-	targets := target.FromStrings("foo", "bar")
+	targets := target.MustFromStrings("foo", "bar")
 	command := []string{"ls"}
 	for _, item := range externalDefs {
-		executor := Make(fmt.Sprintf("(%s ssh)", item.name)).(*external)
+		executor := mustMake(t, fmt.Sprintf("(%s ssh)", item.name)).(*external)
 		executor.commandRunner = &util.MockInteractiveCommandRunner{}
 
 		util.WithLogAssertions(t, func(l *util.MockLogger) {
 			m := executor.commandRunner.(*util.MockInteractiveCommandRunner)
 			if executor.mode == externalModeSingleRun {
-				m.On("Run", executor.makeSingleRunJob(targets, command)).Times(1)
+				m.On("Run", mustMakeSingleRunJob(t, executor, targets, command)).Times(1)
 			} else if executor.mode == externalModeSequential {
-				for _, job := range executor.makeJobPerTarget(targets, command) {
+				for _, job := range mustMakeJobPerTarget(t, executor, targets, command) {
 					m.On("Run", job).Times(1)
 				}
 			} else if executor.mode == externalModeParallel {
 				l.ExpectInfof("Parallelly executing %s on %s", "[ls]", "[foo bar]")
-				m.On("RunParallel", executor.makeJobPerTarget(targets, command))
+				m.On("RunParallel", mustMakeJobPerTarget(t, executor, targets, command))
 			}
 
-			executor.Exec(targets, command)
+			util.ExpectNoError(t, executor.Exec(targets, command))
 			m.AssertExpectations(t)
 			l.AssertExpectations(t)
 		})
 	}
 }
 
+func TestExternalSequentialExecStopsAtFirstFailure(t *testing.T) {
+	targets := target.MustFromStrings("foo", "bar")
+	command := []string{"ls"}
+	executor := mustMake(t, "(external-sequential ssh)").(*external)
+	m := &util.MockInteractiveCommandRunner{}
+	executor.commandRunner = m
+	jobs := mustMakeJobPerTarget(t, executor, targets, command)
+	failure := &util.CommandError{Argv: jobs[0].Argv, Err: errors.New("exit status 255")}
+	m.On("Run", jobs[0]).Return(failure).Times(1)
+
+	if err := executor.Exec(targets, command); err != failure {
+		t.Errorf("Expected error %v, got %v", failure, err)
+	}
+	m.AssertExpectations(t)
+	m.AssertNotCalled(t, "Run", jobs[1])
+}
+
 func TestExternalUnknownMode(t *testing.T) {
 	var mode externalMode = 128
-	e := Make("(external ssh)").(*external)
+	e := mustMake(t, "(external ssh)").(*external)
 	e.mode = mode
-	util.ExpectPanic(t, fmt.Sprintf("Unknown externalMode %v", mode), func() {
-		e.Exec([]target.Target{}, []string{})
-	})
+	util.ExpectError(t, fmt.Sprintf("Unknown externalMode %v", mode), e.Exec([]target.Target{}, []string{}))
+}
+
+func TestExternalPlan(t *testing.T) {
+	targets := target.MustFromStrings("foo", "bar")
+	command := []string{"ls"}
+	for _, item := range externalDefs {
+		executor := mustMake(t, fmt.Sprintf("(%s ssh)", item.name)).(*external)
+		executor.commandRunner = &util.MockInteractiveCommandRunner{}
+		p := mustPlan(t, executor, targets, command)
+		if p.Executor != item.name {
+			t.Errorf("Plan of %s has Executor %s", item.name, p.Executor)
+		}
+		var expectedMode string
+		var expectedJobs []util.InteractiveCommandRunnerJob
+		if item.mode == externalModeSingleRun {
+			expectedMode = plan.ModeSingle
+			expectedJobs = []util.InteractiveCommandRunnerJob{mustMakeSingleRunJob(t, executor, targets, command)}
+		} else if item.mode == externalModeSequential {
+			expectedMode = plan.ModeSequential
+			expectedJobs = mustMakeJobPerTarget(t, executor, targets, command)
+		} else {
+			expectedMode = plan.ModeParallel
+			expectedJobs = mustMakeJobPerTarget(t, executor, targets, command)
+		}
+		if p.Mode != expectedMode {
+			t.Errorf("Plan of %s has Mode %s, expected %s", item.name, p.Mode, expectedMode)
+		}
+		if !reflect.DeepEqual(expectedJobs, p.Jobs) {
+			t.Errorf("Plan of %s has Jobs %v, expected %v", item.name, p.Jobs, expectedJobs)
+		}
+		// Nothing was run
+		executor.commandRunner.(*util.MockInteractiveCommandRunner).AssertExpectations(t)
+	}
 }
@@ -0,0 +1,195 @@
+package executors
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+/*
+externalTool knows the command-line syntax of a tool run by the external executors: how to pass it a target, with its
+port and jump hosts, and a command. The tool is chosen by the name of the binary when the executor is made.
+*/
+type externalTool interface {
+	// globalArgs returns the arguments the tool takes right after its name to reach t, before any subcommand
+	globalArgs(t target.Target) []string
+	targetArgs(t target.Target) ([]string, error)
+	commandArgs(command []string) []string
+}
+
+// externalTools maps binary names to their syntax. Tools not known here get an ssh:// URI, see uriTool.
+var externalTools = map[string]externalTool{
+	"ssh":     sshTool{portFlag: "-p"},
+	"scp":     sshTool{portFlag: "-P", bracketIPv6: true},
+	"sftp":    sshTool{portFlag: "-P", bracketIPv6: true},
+	"csshx":   csshxTool{},
+	"kubectl": kubectlTool{},
+	"docker":  dockerTool{},
+}
+
+func toolFor(binary string) externalTool {
+	name := filepath.Base(binary)
+	if tool, ok := externalTools[name]; ok {
+		return tool
+	}
+	return uriTool{name: name}
+}
+
+func rejectJumpHosts(tool string, t target.Target) error {
+	if len(t.ProxyJump) > 0 {
+		return util.UsageErrorf("%s can't connect to %s through jump hosts %s; only ssh, scp and sftp can",
+			tool, t.FriendlyName(), t.ProxyJump)
+	}
+	return nil
+}
+
+// sshTool is ssh, or a tool taking the same options, like scp and sftp, which take the port with -P
+type sshTool struct {
+	portFlag    string
+	bracketIPv6 bool
+}
+
+func (sshTool) globalArgs(t target.Target) []string {
+	return nil
+}
+
+func (s sshTool) targetArgs(t target.Target) ([]string, error) {
+	var args []string
+	if len(t.ProxyJump) > 0 {
+		args = append(args, "-J", strings.Join(t.ProxyJump, ","))
+	}
+	if t.Port == 0 {
+		return append(args, t.SSHTarget()), nil
+	}
+	host := t.SSHTarget()
+	if s.bracketIPv6 {
+		host = t.SCPTarget()
+	}
+	return append(args, s.portFlag, strconv.Itoa(t.Port), host), nil
+}
+
+func (sshTool) commandArgs(command []string) []string {
+	return command
+}
+
+// csshxTool takes the port after the host, with IPv6 addresses in square brackets
+type csshxTool struct{}
+
+func (csshxTool) globalArgs(t target.Target) []string {
+	return nil
+}
+
+func (csshxTool) targetArgs(t target.Target) ([]string, error) {
+	if err := rejectJumpHosts("csshx", t); err != nil {
+		return nil, err
+	}
+	if t.Port == 0 {
+		return []string{t.SSHTarget()}, nil
+	}
+	return []string{t.SCPTarget() + ":" + strconv.Itoa(t.Port)}, nil
+}
+
+func (csshxTool) commandArgs(command []string) []string {
+	return command
+}
+
+/*
+uriTool passes targets with a port as an ssh:// URI, which works for anything that passes it on to ssh, like
+tmux-cssh, but can't carry jump hosts
+*/
+type uriTool struct {
+	name string
+}
+
+func (uriTool) globalArgs(t target.Target) []string {
+	return nil
+}
+
+func (u uriTool) targetArgs(t target.Target) ([]string, error) {
+	if err := rejectJumpHosts(u.name, t); err != nil {
+		return nil, err
+	}
+	if t.Port == 0 {
+		return []string{t.SSHTarget()}, nil
+	}
+	return []string{t.SSHURI()}, nil
+}
+
+func (uriTool) commandArgs(command []string) []string {
+	return command
+}
+
+// containerName returns the name of the container t was found as, in the label set by a discoverer, or its address
+func containerName(t target.Target, label string) string {
+	for _, name := range []string{t.Labels[label], t.Host, t.IP} {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// kubectlTool runs kubectl exec in the pods found by the kubectl discoverer
+type kubectlTool struct{}
+
+func (kubectlTool) globalArgs(t target.Target) []string {
+	return nil
+}
+
+func (kubectlTool) targetArgs(t target.Target) ([]string, error) {
+	if err := rejectJumpHosts("kubectl", t); err != nil {
+		return nil, err
+	}
+	var args []string
+	if context := t.Labels["kubernetes.context"]; context != "" {
+		args = append(args, "--context", context)
+	}
+	if namespace := t.Labels["kubernetes.namespace"]; namespace != "" {
+		args = append(args, "-n", namespace)
+	}
+	if container := t.Labels["kubernetes.container"]; container != "" {
+		args = append(args, "-c", container)
+	}
+	return append(args, containerName(t, "kubernetes.pod")), nil
+}
+
+// commandArgs passes the command after --, and runs a shell if there is none, because kubectl exec needs a command
+func (kubectlTool) commandArgs(command []string) []string {
+	if len(command) == 0 {
+		command = []string{"sh"}
+	}
+	return append([]string{"--"}, command...)
+}
+
+// dockerTool runs docker exec in the containers found by the docker discoverer
+type dockerTool struct{}
+
+// globalArgs passes the context of the container, which docker only takes before its subcommand
+func (dockerTool) globalArgs(t target.Target) []string {
+	if context := t.Labels["docker.context"]; context != "" {
+		return []string{"--context", context}
+	}
+	return nil
+}
+
+func (dockerTool) targetArgs(t target.Target) ([]string, error) {
+	if err := rejectJumpHosts("docker", t); err != nil {
+		return nil, err
+	}
+	var args []string
+	if t.User != "" {
+		args = append(args, "-u", t.User)
+	}
+	return append(args, containerName(t, "docker.container")), nil
+}
+
+// commandArgs runs a shell if there is no command, because docker exec needs one
+func (dockerTool) commandArgs(command []string) []string {
+	if len(command) == 0 {
+		return []string{"sh"}
+	}
+	return command
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/plan"
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
@@ -14,22 +15,58 @@ type ifCommand struct {
 	withoutCommand interfaces.Executor
 }
 
-func (e *ifCommand) Exec(targets []target.Target, args []string) {
-	util.RequireArguments(e, 2, e.initialArgs)
+func (e *ifCommand) Exec(targets []target.Target, args []string) error {
+	if err := util.RequireArguments(e, 2, e.initialArgs); err != nil {
+		return err
+	}
 	if len(args) < 1 {
 		util.Logger.Debugf("%s got no command, using %s", e, e.withoutCommand)
-		e.withoutCommand.Exec(targets, args)
+		return e.withoutCommand.Exec(targets, args)
+	}
+	util.Logger.Debugf("%s got command, using %s", e, e.withCommand)
+	return e.withCommand.Exec(targets, args)
+}
+
+func (e *ifCommand) Plan(targets []target.Target, args []string) (plan.Plan, error) {
+	if err := util.RequireArguments(e, 2, e.initialArgs); err != nil {
+		return plan.Plan{}, err
+	}
+	p := plan.Plan{Executor: nameIfCommand}
+	child := e.withCommand
+	if len(args) < 1 {
+		p.Decision = "got no command"
+		child = e.withoutCommand
 	} else {
-		util.Logger.Debugf("%s got command, using %s", e, e.withCommand)
-		e.withCommand.Exec(targets, args)
+		p.Decision = "got command"
 	}
+	childPlan, err := child.Plan(targets, args)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	p.Children = []plan.Plan{childPlan}
+	return p, nil
 }
 
-func (e *ifCommand) SetArgs(args []interface{}) {
-	util.RequireArguments(e, 2, args)
-	e.withCommand = makeFromSExp(args[0].([]interface{}))
-	e.withoutCommand = makeFromSExp(args[1].([]interface{}))
+func (e *ifCommand) SetArgs(args []interface{}) error {
+	if err := util.RequireArguments(e, 2, args); err != nil {
+		return err
+	}
+	withCommand, err := makeFromSExp(args[0])
+	if err != nil {
+		return err
+	}
+	withoutCommand, err := makeFromSExp(args[1])
+	if err != nil {
+		return err
+	}
+	e.withCommand = withCommand
+	e.withoutCommand = withoutCommand
 	e.initialArgs = args
+	return nil
+}
+
+func (e *ifCommand) Children() []interface{} {
+	return []interface{}{e.withCommand, e.withoutCommand}
 }
 
 func (e *ifCommand) String() string {
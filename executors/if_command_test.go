@@ -2,8 +2,10 @@ package executors
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
+	"github.com/abesto/easyssh/plan"
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 	"github.com/stretchr/testify/mock"
@@ -18,44 +20,43 @@ func TestIfCommandStringViaMake(t *testing.T) {
 		l.ExpectDebugf("Make %s -> %s", "[external ssh]", "<external [ssh]>")
 		l.ExpectDebugf("Make %s -> %s", "[external tmux-cssh]", "<external [tmux-cssh]>")
 		l.ExpectDebugf("Make %s -> %s", structs, final)
-		Make(input)
+		mustMake(t, input)
 	})
 }
 
 func TestIfCommandMakeWithoutArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(if-command)", fmt.Sprintf("[if-command]"))
-		util.ExpectPanic(t, fmt.Sprintf("<if-command %v %v> requires exactly 2 argument(s), got 0: []", nil, nil),
-			func() { Make(fmt.Sprintf("(if-command)")) })
+		_, err := Make(fmt.Sprintf("(if-command)"))
+		util.ExpectError(t, fmt.Sprintf("<if-command %v %v> requires exactly 2 argument(s), got 0: [] in (if-command) at position 0", nil, nil), err)
 	})
 }
 
 func TestIfCommandMakeWithOneArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(if-command (external ssh))", fmt.Sprintf("[if-command [external ssh]]"))
-		util.ExpectPanic(t, fmt.Sprintf("<if-command %v %v> requires exactly 2 argument(s), got 1: [[external ssh]]", nil, nil),
-			func() { Make(fmt.Sprintf("(if-command (external ssh))")) })
+		_, err := Make(fmt.Sprintf("(if-command (external ssh))"))
+		util.ExpectError(t, fmt.Sprintf("<if-command %v %v> requires exactly 2 argument(s), got 1: [[external ssh]] in (if-command (external ssh)) at position 0", nil, nil), err)
 	})
 }
 
 func TestIfCommandMakeWithTooManyArguments(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(if-command foo bar baz)", "[if-command foo bar baz]")
-		util.ExpectPanic(t, fmt.Sprintf("<if-command %v %v> requires exactly 2 argument(s), got 3: [foo bar baz]", nil, nil),
-			func() { Make("(if-command foo bar baz)") })
+		_, err := Make("(if-command foo bar baz)")
+		util.ExpectError(t, fmt.Sprintf("<if-command %v %v> requires exactly 2 argument(s), got 3: [foo bar baz] in (if-command foo bar baz) at position 0", nil, nil), err)
 	})
 }
 
 func TestIfCommandExecWithoutSetArgs(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
-		util.ExpectPanic(t, fmt.Sprintf("<if-command %v %v> requires exactly 2 argument(s), got 0: []", nil, nil),
-			func() { (&ifCommand{}).Exec([]target.Target{}, []string{}) })
+		util.ExpectError(t, fmt.Sprintf("<if-command %v %v> requires exactly 2 argument(s), got 0: []", nil, nil), (&ifCommand{}).Exec([]target.Target{}, []string{}))
 	})
 }
 
 func TestIfCommandSetArgs(t *testing.T) {
 	input := "(if-command (ssh-exec) (csshx))"
-	e := Make(input).(*ifCommand)
+	e := mustMake(t, input).(*ifCommand)
 	if fmt.Sprintf("%s", e.withCommand) != "<assert-command <external-sequential [ssh]>>" {
 		t.Error("one", e.withCommand)
 	}
@@ -69,8 +70,8 @@ func TestIfCommandSetArgs(t *testing.T) {
 
 func TestIfCommandGetsCommand(t *testing.T) {
 	withMockInMakerMap(func() {
-		e := Make("(if-command (mock) (mock))").(*ifCommand)
-		targets := target.FromStrings("foo")
+		e := mustMake(t, "(if-command (mock) (mock))").(*ifCommand)
+		targets := target.MustFromStrings("foo")
 		command := []string{"ssh", "-l", "root"}
 
 		withCommand := e.withCommand.(*mockExecutor)
@@ -78,7 +79,7 @@ func TestIfCommandGetsCommand(t *testing.T) {
 
 		withoutCommand := e.withoutCommand.(*mockExecutor)
 
-		e.Exec(targets, command)
+		util.ExpectNoError(t, e.Exec(targets, command))
 		withCommand.AssertExpectations(t)
 		withoutCommand.AssertExpectations(t)
 	})
@@ -86,8 +87,8 @@ func TestIfCommandGetsCommand(t *testing.T) {
 
 func TestIfCommandGetsNoCommand(t *testing.T) {
 	withMockInMakerMap(func() {
-		e := Make("(if-command (mock) (mock))").(*ifCommand)
-		targets := target.FromStrings("foo")
+		e := mustMake(t, "(if-command (mock) (mock))").(*ifCommand)
+		targets := target.MustFromStrings("foo")
 		command := []string{}
 
 		withCommand := e.withCommand.(*mockExecutor)
@@ -95,8 +96,32 @@ func TestIfCommandGetsNoCommand(t *testing.T) {
 		withoutCommand := e.withoutCommand.(*mockExecutor)
 		withoutCommand.On("Exec", mock.Anything, mock.Anything).Times(1)
 
-		e.Exec(targets, command)
+		util.ExpectNoError(t, e.Exec(targets, command))
 		withCommand.AssertExpectations(t)
 		withoutCommand.AssertExpectations(t)
 	})
 }
+
+func TestIfCommandPlan(t *testing.T) {
+	withMockInMakerMap(func() {
+		e := mustMake(t, "(if-command (mock) (mock))").(*ifCommand)
+		targets := target.MustFromStrings("foo")
+		withPlan := plan.Plan{Executor: "with"}
+		withoutPlan := plan.Plan{Executor: "without"}
+		e.withCommand.(*mockExecutor).On("Plan", targets, mock.Anything).Return(withPlan)
+		e.withoutCommand.(*mockExecutor).On("Plan", targets, mock.Anything).Return(withoutPlan)
+
+		cases := []struct {
+			command  []string
+			expected plan.Plan
+		}{
+			{[]string{"uptime"}, plan.Plan{Executor: nameIfCommand, Decision: "got command", Children: []plan.Plan{withPlan}}},
+			{[]string{}, plan.Plan{Executor: nameIfCommand, Decision: "got no command", Children: []plan.Plan{withoutPlan}}},
+		}
+		for _, c := range cases {
+			if actual := mustPlan(t, e, targets, c.command); !reflect.DeepEqual(c.expected, actual) {
+				t.Errorf("Expected plan %v, got %v", c.expected, actual)
+			}
+		}
+	})
+}
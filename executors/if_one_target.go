@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/plan"
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
@@ -14,21 +15,55 @@ type ifOneTarget struct {
 	more        interfaces.Executor
 }
 
-func (e *ifOneTarget) Exec(targets []target.Target, command []string) {
-	util.RequireArguments(e, 2, e.initialArgs)
+func (e *ifOneTarget) Exec(targets []target.Target, command []string) error {
+	if err := util.RequireArguments(e, 2, e.initialArgs); err != nil {
+		return err
+	}
 	if len(targets) == 1 {
 		util.Logger.Debugf("%s got one target, using %s", e, e.one)
-		e.one.Exec(targets, command)
+		return e.one.Exec(targets, command)
+	}
+	util.Logger.Debugf("%s got more than one target, using %s", e, e.more)
+	return e.more.Exec(targets, command)
+}
+func (e *ifOneTarget) Plan(targets []target.Target, command []string) (plan.Plan, error) {
+	if err := util.RequireArguments(e, 2, e.initialArgs); err != nil {
+		return plan.Plan{}, err
+	}
+	p := plan.Plan{Executor: nameIfOneTarget}
+	child := e.more
+	if len(targets) == 1 {
+		p.Decision = "got one target"
+		child = e.one
 	} else {
-		util.Logger.Debugf("%s got more than one target, using %s", e, e.more)
-		e.more.Exec(targets, command)
+		p.Decision = "got more than one target"
 	}
+	childPlan, err := child.Plan(targets, command)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	p.Children = []plan.Plan{childPlan}
+	return p, nil
 }
-func (e *ifOneTarget) SetArgs(args []interface{}) {
-	util.RequireArguments(e, 2, args)
+func (e *ifOneTarget) SetArgs(args []interface{}) error {
+	if err := util.RequireArguments(e, 2, args); err != nil {
+		return err
+	}
+	one, err := makeFromSExp(args[0])
+	if err != nil {
+		return err
+	}
+	more, err := makeFromSExp(args[1])
+	if err != nil {
+		return err
+	}
 	e.initialArgs = args
-	e.one = makeFromSExp(args[0].([]interface{}))
-	e.more = makeFromSExp(args[1].([]interface{}))
+	e.one = one
+	e.more = more
+	return nil
+}
+func (e *ifOneTarget) Children() []interface{} {
+	return []interface{}{e.one, e.more}
 }
 func (e *ifOneTarget) String() string {
 	return fmt.Sprintf("<%s %v %v>", nameIfOneTarget, e.one, e.more)
@@ -2,10 +2,13 @@ package executors
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
+	"github.com/abesto/easyssh/plan"
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestIfOneTargetStringViaMake(t *testing.T) {
@@ -17,44 +20,43 @@ func TestIfOneTargetStringViaMake(t *testing.T) {
 		l.ExpectDebugf("Make %s -> %s", "[external ssh]", "<external [ssh]>")
 		l.ExpectDebugf("Make %s -> %s", "[external tmux-cssh]", "<external [tmux-cssh]>")
 		l.ExpectDebugf("Make %s -> %s", structs, final)
-		Make(input)
+		mustMake(t, input)
 	})
 }
 
 func TestIfOneTargetMakeWithoutArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(if-one-target)", fmt.Sprintf("[if-one-target]"))
-		util.ExpectPanic(t, fmt.Sprintf("<if-one-target %v %v> requires exactly 2 argument(s), got 0: []", nil, nil),
-			func() { Make(fmt.Sprintf("(if-one-target)")) })
+		_, err := Make(fmt.Sprintf("(if-one-target)"))
+		util.ExpectError(t, fmt.Sprintf("<if-one-target %v %v> requires exactly 2 argument(s), got 0: [] in (if-one-target) at position 0", nil, nil), err)
 	})
 }
 
 func TestIfOneTargetMakeWithOneArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(if-one-target (external ssh))", fmt.Sprintf("[if-one-target [external ssh]]"))
-		util.ExpectPanic(t, fmt.Sprintf("<if-one-target %v %v> requires exactly 2 argument(s), got 1: [[external ssh]]", nil, nil),
-			func() { Make(fmt.Sprintf("(if-one-target (external ssh))")) })
+		_, err := Make(fmt.Sprintf("(if-one-target (external ssh))"))
+		util.ExpectError(t, fmt.Sprintf("<if-one-target %v %v> requires exactly 2 argument(s), got 1: [[external ssh]] in (if-one-target (external ssh)) at position 0", nil, nil), err)
 	})
 }
 
 func TestIfOneTargetMakeWithTooManyArguments(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(if-one-target foo bar baz)", "[if-one-target foo bar baz]")
-		util.ExpectPanic(t, fmt.Sprintf("<if-one-target %v %v> requires exactly 2 argument(s), got 3: [foo bar baz]", nil, nil),
-			func() { Make("(if-one-target foo bar baz)") })
+		_, err := Make("(if-one-target foo bar baz)")
+		util.ExpectError(t, fmt.Sprintf("<if-one-target %v %v> requires exactly 2 argument(s), got 3: [foo bar baz] in (if-one-target foo bar baz) at position 0", nil, nil), err)
 	})
 }
 
 func TestIfOneTargetExecWithoutSetArgs(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
-		util.ExpectPanic(t, fmt.Sprintf("<if-one-target %v %v> requires exactly 2 argument(s), got 0: []", nil, nil),
-			func() { (&ifOneTarget{}).Exec([]target.Target{}, []string{}) })
+		util.ExpectError(t, fmt.Sprintf("<if-one-target %v %v> requires exactly 2 argument(s), got 0: []", nil, nil), (&ifOneTarget{}).Exec([]target.Target{}, []string{}))
 	})
 }
 
 func TestIfOneTargetSetArgs(t *testing.T) {
 	input := "(if-one-target (ssh-exec) (csshx))"
-	e := Make(input).(*ifOneTarget)
+	e := mustMake(t, input).(*ifOneTarget)
 	if fmt.Sprintf("%s", e.one) != "<assert-command <external-sequential [ssh]>>" {
 		t.Error("one", e.one)
 	}
@@ -68,8 +70,8 @@ func TestIfOneTargetSetArgs(t *testing.T) {
 
 func TestIfOneTargetGetsOneTarget(t *testing.T) {
 	withMockInMakerMap(func() {
-		e := Make("(if-one-target (mock) (mock))").(*ifOneTarget)
-		targets := target.FromStrings("foo")
+		e := mustMake(t, "(if-one-target (mock) (mock))").(*ifOneTarget)
+		targets := target.MustFromStrings("foo")
 		command := []string{"ssh", "-l", "root"}
 
 		one := e.one.(*mockExecutor)
@@ -77,7 +79,7 @@ func TestIfOneTargetGetsOneTarget(t *testing.T) {
 
 		more := e.more.(*mockExecutor)
 
-		e.Exec(targets, command)
+		util.ExpectNoError(t, e.Exec(targets, command))
 		one.AssertExpectations(t)
 		more.AssertExpectations(t)
 	})
@@ -85,8 +87,8 @@ func TestIfOneTargetGetsOneTarget(t *testing.T) {
 
 func TestIfOneTargetGetsMultipleTargets(t *testing.T) {
 	withMockInMakerMap(func() {
-		e := Make("(if-one-target (mock) (mock))").(*ifOneTarget)
-		targets := target.FromStrings("bar", "baz")
+		e := mustMake(t, "(if-one-target (mock) (mock))").(*ifOneTarget)
+		targets := target.MustFromStrings("bar", "baz")
 		command := []string{"ls", "/"}
 
 		one := e.one.(*mockExecutor)
@@ -94,8 +96,32 @@ func TestIfOneTargetGetsMultipleTargets(t *testing.T) {
 		more := e.more.(*mockExecutor)
 		more.On("Exec", targets, command).Times(1)
 
-		e.Exec(targets, command)
+		util.ExpectNoError(t, e.Exec(targets, command))
 		one.AssertExpectations(t)
 		more.AssertExpectations(t)
 	})
 }
+
+func TestIfOneTargetPlan(t *testing.T) {
+	withMockInMakerMap(func() {
+		e := mustMake(t, "(if-one-target (mock) (mock))").(*ifOneTarget)
+		command := []string{"ls", "/"}
+		onePlan := plan.Plan{Executor: "one"}
+		morePlan := plan.Plan{Executor: "more"}
+		e.one.(*mockExecutor).On("Plan", mock.Anything, command).Return(onePlan)
+		e.more.(*mockExecutor).On("Plan", mock.Anything, command).Return(morePlan)
+
+		cases := []struct {
+			targets  []target.Target
+			expected plan.Plan
+		}{
+			{target.MustFromStrings("foo"), plan.Plan{Executor: nameIfOneTarget, Decision: "got one target", Children: []plan.Plan{onePlan}}},
+			{target.MustFromStrings("foo", "bar"), plan.Plan{Executor: nameIfOneTarget, Decision: "got more than one target", Children: []plan.Plan{morePlan}}},
+		}
+		for _, c := range cases {
+			if actual := mustPlan(t, e, c.targets, command); !reflect.DeepEqual(c.expected, actual) {
+				t.Errorf("Expected plan %v, got %v", c.expected, actual)
+			}
+		}
+	})
+}
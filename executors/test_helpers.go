@@ -2,6 +2,7 @@ package executors
 
 import (
 	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/plan"
 	"github.com/abesto/easyssh/target"
 	"github.com/stretchr/testify/mock"
 )
@@ -10,11 +11,23 @@ type mockExecutor struct {
 	mock.Mock
 }
 
-func (e *mockExecutor) Exec(targets []target.Target, args []string) {
-	e.Called(targets, args)
+func (e *mockExecutor) Exec(targets []target.Target, args []string) error {
+	ret := e.Called(targets, args)
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret.Error(0)
 }
-func (e *mockExecutor) SetArgs(args []interface{}) {
+func (e *mockExecutor) Plan(targets []target.Target, args []string) (plan.Plan, error) {
+	ret := e.Called(targets, args)
+	if len(ret) < 2 {
+		return ret.Get(0).(plan.Plan), nil
+	}
+	return ret.Get(0).(plan.Plan), ret.Error(1)
+}
+func (e *mockExecutor) SetArgs(args []interface{}) error {
 	// We don't actually want to assert on this, so no call to e.Called
+	return nil
 }
 func (e *mockExecutor) String() string {
 	return "<mock>"
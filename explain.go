@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/abesto/easyssh/fromsexp"
+	"github.com/abesto/easyssh/interfaces"
+)
+
+// The name of the subcommand printing the expanded component trees
+const explainSubcommand = "explain"
+
+type explanation struct {
+	flagName   string
+	kind       string
+	definition string
+	make       func(string) error
+}
+
+/*
+selectExplanations keeps only the definitions whose flags were set explicitly on the command line.
+If none of them were, all definitions are kept.
+*/
+func selectExplanations(explanations []explanation) []explanation {
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	selected := []explanation{}
+	for _, e := range explanations {
+		if explicit[e.flagName] {
+			selected = append(selected, e)
+		}
+	}
+	if len(selected) == 0 {
+		return explanations
+	}
+	return selected
+}
+
+/*
+explain prints the fully expanded component tree of each definition, including the external binaries each component
+requires, and whether they're on PATH. Returns false if any required binary is missing, and an error if any of the
+definitions is invalid.
+*/
+func explain(w io.Writer, explanations []explanation) (bool, error) {
+	allFound := true
+	for i, e := range explanations {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s: %s\n", e.kind, e.definition)
+		var err error
+		// Missing binaries are reported in the tree, they must not abort building it
+		roots := fromsexp.TraceAllowingMissingBinaries(func() { err = e.make(e.definition) })
+		if err != nil {
+			return false, err
+		}
+		for _, root := range roots {
+			allFound = explainNode(w, root, 1) && allFound
+		}
+	}
+	return allFound, nil
+}
+
+func explainNode(w io.Writer, node *fromsexp.TraceNode, level int) bool {
+	indent := strings.Repeat("  ", level)
+	line := indent + strings.Join(append([]string{node.Name()}, node.Atoms()...), " ")
+	if len(node.Transforms) > 0 {
+		line += " (from " + strings.Join(node.Transforms, " -> ") + ")"
+	}
+	fmt.Fprintln(w, line)
+
+	allFound := true
+	if r, ok := node.Object.(interfaces.RequiresBinaries); ok {
+		for _, binary := range r.RequiredBinaries() {
+			path, err := exec.LookPath(binary)
+			if err == nil {
+				fmt.Fprintf(w, "%s  requires %s: found at %s\n", indent, binary, path)
+			} else {
+				fmt.Fprintf(w, "%s  requires %s: NOT FOUND on PATH\n", indent, binary)
+				allFound = false
+			}
+		}
+	}
+
+	for _, child := range node.Children {
+		allFound = explainNode(w, child, level+1) && allFound
+	}
+	return allFound
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/abesto/easyssh/executors"
+	"github.com/abesto/easyssh/filters"
+	"github.com/abesto/easyssh/util"
+)
+
+func TestExplain(t *testing.T) {
+	var buffer bytes.Buffer
+	found, err := explain(&buffer, []explanation{
+		{"f", "filter", "(list (first) (id))", func(d string) error { _, err := filters.Make(d); return err }},
+		{"e", "executor", "(if-command (ssh-exec) (external-interactive easyssh-test-missing-binary))",
+			func(d string) error { _, err := executors.Make(d); return err }},
+	})
+	util.ExpectNoError(t, err)
+	if found {
+		t.Error("explain reported all binaries as found")
+	}
+	expectedPrefix := `filter: (list (first) (id))
+  list
+    first
+    id
+
+executor: (if-command (ssh-exec) (external-interactive easyssh-test-missing-binary))
+  if-command
+    assert-command (from ssh-exec -> ssh-exec-sequential)
+      external-sequential ssh
+        requires ssh: found at `
+	expectedSuffix := `
+    external-interactive easyssh-test-missing-binary
+      requires easyssh-test-missing-binary: NOT FOUND on PATH
+`
+	actual := buffer.String()
+	if !bytes.HasPrefix([]byte(actual), []byte(expectedPrefix)) || !bytes.HasSuffix([]byte(actual), []byte(expectedSuffix)) {
+		t.Errorf("Unexpected output:\n%s", actual)
+	}
+	_, err = executors.Make("(external-interactive easyssh-test-missing-binary)")
+	util.ExpectError(t, "easyssh-test-missing-binary is not found on PATH, but is required by <external-interactive [easyssh-test-missing-binary]>", err)
+}
+
+func TestExplainInvalidDefinition(t *testing.T) {
+	var buffer bytes.Buffer
+	found, err := explain(&buffer, []explanation{
+		{"f", "filter", "(list (frist))", func(d string) error { _, err := filters.Make(d); return err }},
+	})
+	if found {
+		t.Error("explain reported success for an invalid definition")
+	}
+	util.ExpectError(t, "filter \"frist\" is not known in (frist) at position 6; did you mean first?", err)
+}
@@ -0,0 +1,73 @@
+package filters
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/abesto/easyssh/cache"
+	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+type cached struct {
+	args       []interface{}
+	ttl        time.Duration
+	maxStale   time.Duration
+	definition string
+	child      interfaces.TargetFilter
+}
+
+func (f *cached) Filter(targets []target.Target) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(f, 2, f.args); err != nil {
+		return nil, err
+	}
+	input, err := json.Marshal(targets)
+	if err != nil {
+		return nil, err
+	}
+	key := cache.Key("filter", f.definition, string(input))
+	return cache.Get(key, f.ttl, f.maxStale, func() ([]target.Target, error) {
+		return f.child.Filter(targets)
+	})
+}
+
+func (f *cached) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(f, 2, args); err != nil {
+		return err
+	}
+	if err := util.RequireArgumentsAtMost(f, 3, args); err != nil {
+		return err
+	}
+	ttl, maxStale, err := cache.Args(args)
+	if err != nil {
+		return err
+	}
+	var child interfaces.TargetFilter
+	definition, err := cache.ExpandedDefinition(func() (err error) {
+		child, err = makeFromSExp(args[len(args)-1])
+		return
+	})
+	if err != nil {
+		return err
+	}
+	f.args = args
+	f.ttl, f.maxStale, f.definition = ttl, maxStale, definition
+	f.child = child
+	return nil
+}
+
+func (f *cached) Children() []interface{} {
+	return []interface{}{f.child}
+}
+
+func (f *cached) String() string {
+	if f.child == nil {
+		return fmt.Sprintf("<%s>", nameCached)
+	}
+	if f.maxStale > 0 {
+		return fmt.Sprintf("<%s %s %s %s>", nameCached, f.ttl, f.maxStale, f.child)
+	}
+	return fmt.Sprintf("<%s %s %s>", nameCached, f.ttl, f.child)
+}
@@ -0,0 +1,72 @@
+package filters
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// countingFilter labels targets with the number of times it was called
+type countingFilter struct {
+	calls int
+}
+
+func (f *countingFilter) Filter(targets []target.Target) ([]target.Target, error) {
+	f.calls++
+	output := make([]target.Target, len(targets))
+	for i, t := range targets {
+		output[i] = target.Target{Host: t.Host}
+		output[i].SetLabel("calls", strconv.Itoa(f.calls))
+	}
+	return output, nil
+}
+
+func (f *countingFilter) SetArgs(args []interface{}) error {
+	return nil
+}
+
+func (f *countingFilter) String() string {
+	return "<counting>"
+}
+
+func TestCachedStringViaMake(t *testing.T) {
+	if s := mustMake(t, "(cached 10m (id))").String(); s != "<cached 10m0s <id>>" {
+		t.Error(s)
+	}
+	if s := mustMake(t, "(cached 10m 1h (id))").String(); s != "<cached 10m0s 1h0m0s <id>>" {
+		t.Error(s)
+	}
+}
+
+func TestCachedMakeWithoutChild(t *testing.T) {
+	_, err := Make("(cached 10m)")
+	util.ExpectError(t, "<cached> requires at least 2 argument(s), got 1: [10m] in (cached 10m) at position 0", err)
+}
+
+func TestCachedOperation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "easyssh-filters-test")
+	util.ExpectNoError(t, err)
+	defer os.RemoveAll(dir)
+	originalCacheHome := os.Getenv("XDG_CACHE_HOME")
+	defer os.Setenv("XDG_CACHE_HOME", originalCacheHome)
+	os.Setenv("XDG_CACHE_HOME", dir)
+
+	f := mustMake(t, "(cached 10m (id))").(*cached)
+	child := &countingFilter{}
+	f.child = child
+	calls := func(targets []target.Target) string { return targets[0].Labels["calls"] }
+
+	if c := calls(mustFilter(t, f, target.MustFromStrings("a"))); c != "1" {
+		t.Error(c)
+	}
+	if c := calls(mustFilter(t, f, target.MustFromStrings("a"))); c != "1" {
+		t.Error("same targets should be cached", c)
+	}
+	if c := calls(mustFilter(t, f, target.MustFromStrings("b"))); c != "2" {
+		t.Error("different targets shouldn't be cached", c)
+	}
+}
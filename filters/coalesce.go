@@ -12,26 +12,45 @@ type coalesce struct {
 	coalesceOrder []string
 }
 
-func (f *coalesce) Filter(targets []target.Target) []target.Target {
-	util.RequireArgumentsAtLeast(f, 1, f.args)
+func (f *coalesce) Filter(targets []target.Target) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(f, 1, f.args); err != nil {
+		return nil, err
+	}
 	newTargets := make([]target.Target, len(targets))
 	for i, t := range targets {
 		newTargets[i] = t
 		newTargets[i].CoalesceOrder = f.coalesceOrder
 	}
 	util.Logger.Debugf("Set CoalesceOrder of all targets to %s", f.coalesceOrder)
-	return newTargets
+	return newTargets, nil
 }
 
-func (f *coalesce) SetArgs(args []interface{}) {
-	util.RequireArgumentsAtLeast(f, 1, args)
+func (f *coalesce) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(f, 1, args); err != nil {
+		return err
+	}
+	coalesceOrder, err := util.ByteToStringArray(args)
+	if err != nil {
+		return err
+	}
 	f.args = args
-	f.coalesceOrder = util.ByteToStringArray(args)
+	f.coalesceOrder = coalesceOrder
 	for i, coalescer := range f.coalesceOrder {
 		if _, ok := target.Coalescers[coalescer]; !ok {
-			util.Panicf("Unknown target coalescer %s (index %d) in filter %s", coalescer, i, f)
+			err := util.ParseErrorf("Unknown target coalescer %s (index %d)", coalescer, i)
+			err.Suggestion = util.Suggest(coalescer, coalescerNames())
+			return err
 		}
 	}
+	return nil
+}
+
+func coalescerNames() []string {
+	names := make([]string, 0, len(target.Coalescers))
+	for name := range target.Coalescers {
+		names = append(names, name)
+	}
+	return names
 }
 
 func (f *coalesce) String() string {
@@ -15,39 +15,40 @@ func TestCoalesceStringViaMake(t *testing.T) {
 		final := "<coalesce [ip host hostname]>"
 		l.ExpectDebugf("MakeFromString %s -> %s", input, structs)
 		l.ExpectDebugf("Make %s -> %s", structs, final)
-		Make(input)
+		mustMake(t, input)
 	})
 }
 
 func TestCoalesceMakeWithoutArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(coalesce)", "[coalesce]")
-		util.ExpectPanic(t, "<coalesce []> requires at least 1 argument(s), got 0: []",
-			func() { Make("(coalesce)") })
+		_, err := Make("(coalesce)")
+		util.ExpectError(t, "<coalesce []> requires at least 1 argument(s), got 0: [] in (coalesce) at position 0", err)
 	})
 }
 
 func TestCoalesceFilterWithoutSetArgs(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
-		util.ExpectPanic(t, "<coalesce []> requires at least 1 argument(s), got 0: []",
-			func() { (&coalesce{}).Filter([]target.Target{}) })
+		_, err := (&coalesce{}).Filter([]target.Target{})
+		util.ExpectError(t, "<coalesce []> requires at least 1 argument(s), got 0: []", err)
 	})
 }
 
 func TestCoalesceSetArgs(t *testing.T) {
 	input := "(coalesce host hostname)"
-	f := Make(input).(*coalesce)
+	f := mustMake(t, input).(*coalesce)
 	assert.Equal(t, f.coalesceOrder, []string{"host", "hostname"})
 }
 
 func TestUnknownCoalescer(t *testing.T) {
-	util.ExpectPanic(t, "Unknown target coalescer foobar (index 1) in filter <coalesce [ip foobar hostname barbaz]>", func() {
-		Make("(coalesce ip foobar hostname barbaz)")
-	})
+	_, err := Make("(coalesce ip foobar hostname barbaz)")
+	util.ExpectError(t, "Unknown target coalescer foobar (index 1) in (coalesce ip foobar hostname barbaz) at position 0", err)
+	_, err = Make("(coalesce ip hostnme)")
+	util.ExpectError(t, "Unknown target coalescer hostnme (index 1) in (coalesce ip hostnme) at position 0; did you mean hostname?", err)
 }
 
 func TestCoalesceOperation(t *testing.T) {
-	f := Make("(coalesce host)").(*coalesce)
+	f := mustMake(t, "(coalesce host)").(*coalesce)
 	cases := []struct {
 		expected string
 		target   target.Target
@@ -56,7 +57,7 @@ func TestCoalesceOperation(t *testing.T) {
 		{"bar", target.Target{Host: "bar"}},
 	}
 	for _, c := range cases {
-		target := f.Filter([]target.Target{c.target})[0]
+		target := mustFilter(t, f, []target.Target{c.target})[0]
 		assert.Equal(t, []string{"host"}, target.CoalesceOrder)
 		assert.Equal(t, c.expected, target.SSHTarget())
 	}
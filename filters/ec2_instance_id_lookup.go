@@ -7,6 +7,7 @@ import (
 
 	"strings"
 
+	"github.com/abesto/easyssh/ec2"
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
@@ -27,22 +28,6 @@ func (p realEc2InstanceIdParser) Parse(input string) string {
 	return longID
 }
 
-type ec2Instance struct {
-	InstanceId       string
-	PublicDnsName    string
-	PublicIpAddress  string
-	PrivateDnsName   string
-	PrivateIpAddress string
-}
-
-type ec2Reservation struct {
-	Instances []ec2Instance
-}
-
-type ec2DescribeInstanceApiResponse struct {
-	Reservations []ec2Reservation
-}
-
 type ec2InstanceIdLookup struct {
 	args          []interface{}
 	region        string
@@ -50,12 +35,14 @@ type ec2InstanceIdLookup struct {
 	idParser      ec2InstanceIdParser
 }
 
-func (f *ec2InstanceIdLookup) Filter(targets []target.Target) []target.Target {
-	util.RequireArguments(f, 1, f.args)
+func (f *ec2InstanceIdLookup) Filter(targets []target.Target) ([]target.Target, error) {
+	if err := util.RequireArguments(f, 1, f.args); err != nil {
+		return nil, err
+	}
 
 	if len(targets) == 0 {
 		util.Logger.Debugf("%s received no targets, skipping lookup", f)
-		return targets
+		return targets, nil
 	}
 
 	idToIndex := map[string]int{}
@@ -72,7 +59,7 @@ func (f *ec2InstanceIdLookup) Filter(targets []target.Target) []target.Target {
 
 	if len(ids) == 0 {
 		util.Logger.Debugf("%s received no targets that look like they have EC2 instance IDs", f)
-		return targets
+		return targets, nil
 	}
 
 	util.Logger.Infof("EC2 Instance lookup: %s in %s", ids, f.region)
@@ -81,17 +68,17 @@ func (f *ec2InstanceIdLookup) Filter(targets []target.Target) []target.Target {
 	util.Logger.Debugf("Response from AWS API: %s", outputs.Combined)
 	if outputs.Error != nil {
 		util.Logger.Infof("EC2 Instance lookup failed in region %s (aws command failed): %s", f.region, strings.TrimSpace(string(outputs.Combined)))
-		return targets
+		return targets, nil
 	}
 
-	var data ec2DescribeInstanceApiResponse
+	var data ec2.DescribeInstancesResponse
 	if err := json.Unmarshal(outputs.Combined, &data); err != nil {
-		panic(fmt.Sprintf("Invalid JSON returned by AWS API.\nError: %s\nJSON follows this line\n%s", err.Error(), outputs.Combined))
+		return nil, &util.InvalidOutputError{Source: "aws ec2 describe-instances", Err: err, Output: outputs.Combined}
 	}
 
 	if data.Reservations == nil || len(data.Reservations) == 0 {
 		util.Logger.Infof("EC2 instance lookup failed in region %s (Reservations is empty in the received JSON)", f.region)
-		return targets
+		return targets, nil
 	}
 
 	for _, reservation := range data.Reservations {
@@ -99,25 +86,35 @@ func (f *ec2InstanceIdLookup) Filter(targets []target.Target) []target.Target {
 			id := instance.InstanceId
 			idx := idToIndex[id]
 			inputTargetName := targets[idx].Host
-			targets[idx].IP = instance.PublicIpAddress
-			targets[idx].Host = instance.PublicDnsName
-			if targets[idx].IP == "" {
-				targets[idx].IP = instance.PrivateIpAddress
-				targets[idx].Host = instance.PrivateDnsName
-				util.Logger.Infof("AWS API returned PrivateIpAddress=%s PrivateDnsName=%s for %s (%s)", targets[idx].IP, targets[idx].Host, inputTargetName, id)
-			} else {
+			if ec2.SetAddress(&targets[idx], instance) {
 				util.Logger.Infof("AWS API returned PublicIpAddress=%s PublicDnsName=%s for %s (%s)", targets[idx].IP, targets[idx].Host, inputTargetName, id)
+			} else {
+				util.Logger.Infof("AWS API returned PrivateIpAddress=%s PrivateDnsName=%s for %s (%s)", targets[idx].IP, targets[idx].Host, inputTargetName, id)
 			}
+			ec2.SetLabels(&targets[idx], f.region, instance)
 		}
 	}
 
-	return targets
+	return targets, nil
 }
-func (f *ec2InstanceIdLookup) SetArgs(args []interface{}) {
-	util.RequireArguments(f, 1, args)
+
+func (f *ec2InstanceIdLookup) SetArgs(args []interface{}) error {
+	if err := util.RequireArguments(f, 1, args); err != nil {
+		return err
+	}
+	region, err := util.StringArg(args[0])
+	if err != nil {
+		return err
+	}
 	f.args = args
-	f.region = string(args[0].([]byte))
-	util.RequireOnPath(f, "aws")
+	f.region = region
+	return util.RequireOnPath(f, "aws")
+}
+func (f *ec2InstanceIdLookup) SetCommandRunner(r util.CommandRunner) {
+	f.commandRunner = r
+}
+func (f *ec2InstanceIdLookup) RequiredBinaries() []string {
+	return []string{"aws"}
 }
 func (f *ec2InstanceIdLookup) String() string {
 	return fmt.Sprintf("<%s %s>", nameEc2InstanceId, f.region)
@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/mock"
 
+	"github.com/abesto/easyssh/ec2"
 	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
@@ -29,30 +30,30 @@ func TestEc2InstanceIdLookupStringViaMake(t *testing.T) {
 		final := "<ec2-instance-id test-region>"
 		l.ExpectDebugf("MakeFromString %s -> %s", input, structs)
 		l.ExpectDebugf("Make %s -> %s", structs, final)
-		Make(input)
+		mustMake(t, input)
 	})
 }
 
 func TestEc2InstanceIdLookupMakeWithoutArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(ec2-instance-id)", "[ec2-instance-id]")
-		util.ExpectPanic(t, "<ec2-instance-id > requires exactly 1 argument(s), got 0: []",
-			func() { Make("(ec2-instance-id)") })
+		_, err := Make("(ec2-instance-id)")
+		util.ExpectError(t, "<ec2-instance-id > requires exactly 1 argument(s), got 0: [] in (ec2-instance-id) at position 0", err)
 	})
 }
 
 func TestEc2InstanceIdLookupFilterWithoutSetArgs(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
-		util.ExpectPanic(t, "<ec2-instance-id > requires exactly 1 argument(s), got 0: []",
-			func() { (&ec2InstanceIdLookup{}).Filter([]target.Target{}) })
+		_, err := (&ec2InstanceIdLookup{}).Filter([]target.Target{})
+		util.ExpectError(t, "<ec2-instance-id > requires exactly 1 argument(s), got 0: []", err)
 	})
 }
 
 func TestEc2InstanceIdSetTooManyArgs(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(ec2-instance-id foo bar)", "[ec2-instance-id foo bar]")
-		util.ExpectPanic(t, "<ec2-instance-id > requires exactly 1 argument(s), got 2: [foo bar]",
-			func() { Make("(ec2-instance-id foo bar)") })
+		_, err := Make("(ec2-instance-id foo bar)")
+		util.ExpectError(t, "<ec2-instance-id > requires exactly 1 argument(s), got 2: [foo bar] in (ec2-instance-id foo bar) at position 0", err)
 	})
 }
 
@@ -60,7 +61,7 @@ func TestEc2InstanceIdSetArgs(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(ec2-instance-id foo)", "[ec2-instance-id foo]").Times(1)
 		l.ExpectDebugf("Make %s -> %s", "[ec2-instance-id foo]", "<ec2-instance-id foo>").Times(1)
-		f := Make("(ec2-instance-id foo)").(*ec2InstanceIdLookup)
+		f := mustMake(t, "(ec2-instance-id foo)").(*ec2InstanceIdLookup)
 		if f.region != "foo" {
 			t.Errorf("Expected region to be foo, was %s", f.region)
 		}
@@ -99,7 +100,7 @@ func awsReturns(r *util.MockCommandRunner, instanceIds []string, region string,
 }
 
 func assertFilterResults(t *testing.T, f *ec2InstanceIdLookup, input []target.Target, expectedOutput []target.Target) {
-	actualOutput := f.Filter(input)
+	actualOutput := mustFilter(t, f, input)
 	if len(input) != len(actualOutput) {
 		t.Fail()
 	}
@@ -116,7 +117,7 @@ func TestEc2InstanceIdLookupFails(t *testing.T) {
 		msg := "A client error (InvalidInstanceID.NotFound) occurred when calling the DescribeInstances operation: The instance ID 'i-deadbeef' does not exist"
 		host := "dummy-instance-id"
 		instanceId := host + ".instanceid"
-		targets := target.FromStrings(host, host)
+		targets := target.MustFromStrings(host, host)
 		l.ExpectInfof("EC2 Instance lookup: %s in %s", "[dummy-instance-id.instanceid dummy-instance-id.instanceid]", f.region)
 		l.ExpectDebugf("Response from AWS API: %s", msg)
 		l.ExpectInfof("EC2 Instance lookup failed in region %s (aws command failed): %s", f.region, msg)
@@ -139,25 +140,25 @@ func TestEc2InstanceIdLookupInvalidJson(t *testing.T) {
 		l.ExpectInfof("EC2 Instance lookup: %s in %s", "[dummy-instance-id.instanceid]", f.region)
 		// On the AWS API returns invalid JSON
 		awsReturns(r, []string{instanceId}, f.region, invalidJson, nil).Times(1)
-		// I get a fatal error for filtering
-		util.ExpectPanic(t, fmt.Sprintf("Invalid JSON returned by AWS API.\nError: invalid character 'H' looking for beginning of value\nJSON follows this line\n%s", invalidJson),
-			func() { f.Filter([]target.Target{target.FromString(host)}) })
+		// I get an error for filtering
+		_, err := f.Filter([]target.Target{target.MustFromString(host)})
+		util.ExpectError(t, "Failed to parse output of aws ec2 describe-instances: invalid character 'H' looking for beginning of value", err)
 		r.AssertExpectations(t)
 	})
 }
 
 func jsonWithoutReservations() string {
-	bytes, _ := json.Marshal(ec2DescribeInstanceApiResponse{Reservations: []ec2Reservation{}})
+	bytes, _ := json.Marshal(ec2.DescribeInstancesResponse{Reservations: []ec2.Reservation{}})
 	return string(bytes)
 }
 
 func jsonWithIp(ip string, dnsName string, instanceId string) string {
-	bytes, _ := json.Marshal(ec2DescribeInstanceApiResponse{Reservations: []ec2Reservation{{Instances: []ec2Instance{{PublicIpAddress: ip, PublicDnsName: dnsName, InstanceId: instanceId}}}}})
+	bytes, _ := json.Marshal(ec2.DescribeInstancesResponse{Reservations: []ec2.Reservation{{Instances: []ec2.Instance{{PublicIpAddress: ip, PublicDnsName: dnsName, InstanceId: instanceId}}}}})
 	return string(bytes)
 }
 
 func jsonWithPrivateIp(ip string, dnsName string, instanceId string) string {
-	bytes, _ := json.Marshal(ec2DescribeInstanceApiResponse{Reservations: []ec2Reservation{{Instances: []ec2Instance{{PrivateIpAddress: ip, PrivateDnsName: dnsName, InstanceId: instanceId}}}}})
+	bytes, _ := json.Marshal(ec2.DescribeInstancesResponse{Reservations: []ec2.Reservation{{Instances: []ec2.Instance{{PrivateIpAddress: ip, PrivateDnsName: dnsName, InstanceId: instanceId}}}}})
 	return string(bytes)
 }
 
@@ -191,7 +192,7 @@ func makeInputAndOutputTargets(cases []lookupCase, shouldRewrite bool) ([]target
 	inputTargets := make([]target.Target, len(cases))
 	outputTargets := make([]target.Target, len(cases))
 	for i, c := range cases {
-		inputTargets[i] = target.FromString(c.inputHost)
+		inputTargets[i] = target.MustFromString(c.inputHost)
 		if shouldRewrite {
 			var ip string
 			if c.publicIp != "" {
@@ -199,18 +200,18 @@ func makeInputAndOutputTargets(cases []lookupCase, shouldRewrite bool) ([]target
 			} else {
 				ip = c.privateIp
 			}
-			outputTargets[i] = target.FromString(ip)
+			outputTargets[i] = target.MustFromString(ip)
 		} else {
-			outputTargets[i] = target.FromString(c.inputHost)
+			outputTargets[i] = target.MustFromString(c.inputHost)
 		}
 	}
 	return inputTargets, outputTargets
 }
 
 func mergeJsonsOfCases(cases []lookupCase) string {
-	mergedData := ec2DescribeInstanceApiResponse{}
+	mergedData := ec2.DescribeInstancesResponse{}
 	for _, c := range cases {
-		var caseData ec2DescribeInstanceApiResponse
+		var caseData ec2.DescribeInstancesResponse
 		json.Unmarshal([]byte(c.json), &caseData)
 		mergedData.Reservations = append(mergedData.Reservations, caseData.Reservations...)
 	}
@@ -281,3 +282,19 @@ func TestEc2InstanceIdLookupPrivateFallback(t *testing.T) {
 		r.AssertExpectations(t)
 	})
 }
+
+func TestEc2InstanceIdLookupLabels(t *testing.T) {
+	r, f := givenAnEc2InstanceIdLookupWithMockedParserAndRunner(true)
+	f.idParser = realEc2InstanceIdParser{}
+	output := `{"Reservations": [{"Instances": [{
+		"InstanceId": "i-12345678", "InstanceType": "t3.micro", "PublicIpAddress": "1.1.1.1", "PublicDnsName": "public-1",
+		"VpcId": "vpc-1", "SubnetId": "subnet-1", "Placement": {"AvailabilityZone": "dummy-region-1a"},
+		"Tags": [{"Key": "Name", "Value": "app-1"}, {"Key": "Env", "Value": "prod"}]}]}]}`
+	awsReturns(r, []string{"i-12345678"}, f.region, output, nil).Times(1)
+	targets := mustFilter(t, f, target.MustFromStrings("i-12345678"))
+	target.AssertTargetListEquals(t, []target.Target{{Host: "public-1", IP: "1.1.1.1", Labels: map[string]string{
+		"region": "dummy-region", "zone": "dummy-region-1a", "ec2.instance_id": "i-12345678", "ec2.instance_type": "t3.micro",
+		"ec2.vpc_id": "vpc-1", "ec2.subnet_id": "subnet-1", "ec2.public_ip": "1.1.1.1", "ec2.public_dns": "public-1",
+		"ec2.tag.Name": "app-1", "ec2.tag.Env": "prod",
+	}}}, targets)
+}
@@ -26,30 +26,50 @@ func (m *realTmpFileMaker) make(dir, prefix string) (*os.File, error) {
 	return ioutil.TempFile(dir, prefix)
 }
 
-func (f *external) Filter(targets []target.Target) []target.Target {
-	util.RequireArgumentsAtLeast(f, 1, f.initialArgs)
+func (f *external) Filter(targets []target.Target) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(f, 1, f.initialArgs); err != nil {
+		return nil, err
+	}
 	tmpFile, err := f.tmpFileMaker.make("", "easyssh")
-	defer os.Remove(tmpFile.Name())
 	if err != nil {
-		util.Panicf(err.Error())
+		return nil, err
 	}
+	defer os.Remove(tmpFile.Name())
 	tmpFile.Write([]byte(strings.Join(target.SSHTargets(targets), "\n")))
-	output := f.commandRunner.CombinedOutputWithStdinOrPanic(os.Stdin, f.argv[0], append(f.argv[1:], tmpFile.Name()))
+	output, err := f.commandRunner.CombinedOutputWithStdin(os.Stdin, f.argv[0], append(f.argv[1:], tmpFile.Name()))
+	if err != nil {
+		return nil, err
+	}
 	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	newTargets := make([]target.Target, len(lines))
-	for i := 0; i < len(lines); i++ {
-		newTargets[i] = target.FromString(lines[i])
+	newTargets, err := target.FromStrings(lines...)
+	if err != nil {
+		return nil, &util.InvalidOutputError{Source: strings.Join(f.argv, " "), Err: err, Output: output}
 	}
-	return newTargets
+	return newTargets, nil
 }
 
-func (f *external) SetArgs(args []interface{}) {
-	util.RequireArgumentsAtLeast(f, 1, args)
+func (f *external) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(f, 1, args); err != nil {
+		return err
+	}
+	argv, err := util.ByteToStringArray(args)
+	if err != nil {
+		return err
+	}
 	f.initialArgs = args
-	f.argv = make([]string, len(args))
-	for i := 0; i < len(args); i++ {
-		f.argv[i] = string(args[i].([]uint8))
+	f.argv = argv
+	return nil
+}
+
+func (f *external) SetCommandRunner(r util.CommandRunner) {
+	f.commandRunner = r
+}
+
+func (f *external) RequiredBinaries() []string {
+	if len(f.argv) == 0 {
+		return []string{}
 	}
+	return f.argv[:1]
 }
 
 func (f *external) String() string {
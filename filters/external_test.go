@@ -20,27 +20,27 @@ func TestExternalStringViaMake(t *testing.T) {
 		final := "<external [grep myservice]>"
 		l.ExpectDebugf("MakeFromString %s -> %s", input, structs)
 		l.ExpectDebugf("Make %s -> %s", structs, final)
-		Make(input)
+		mustMake(t, input)
 	})
 }
 
 func TestExternalMakeWithoutArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(external)", "[external]")
-		util.ExpectPanic(t, "<external []> requires at least 1 argument(s), got 0: []",
-			func() { Make("(external)") })
+		_, err := Make("(external)")
+		util.ExpectError(t, "<external []> requires at least 1 argument(s), got 0: [] in (external) at position 0", err)
 	})
 }
 
 func TestExternalFilterWithoutSetArgs(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
-		util.ExpectPanic(t, "<external []> requires at least 1 argument(s), got 0: []",
-			func() { (&external{}).Filter(target.FromStrings()) })
+		_, err := (&external{}).Filter(target.MustFromStrings())
+		util.ExpectError(t, "<external []> requires at least 1 argument(s), got 0: []", err)
 	})
 }
 
 func TestExternalSetArgs(t *testing.T) {
-	f := Make("(external grep foobar)").(*external)
+	f := mustMake(t, "(external grep foobar)").(*external)
 	if len(f.argv) != 2 || f.argv[0] != "grep" || f.argv[1] != "foobar" {
 		t.Error("argv", f.argv)
 	}
@@ -63,20 +63,20 @@ func (m *mockTmpFileMaker) make(dir, prefix string) (*os.File, error) {
 
 func TestExternalOperation(t *testing.T) {
 	// This filter
-	f := Make("(external grep -v bar)").(*external)
+	f := mustMake(t, "(external grep -v bar)").(*external)
 	// Will call "grep -v bar", which will return "foo\baz"
 	r := &util.MockCommandRunner{}
-	r.On("CombinedOutputWithStdinOrPanic", os.Stdin, "grep", []string{"-v", "bar", os.Stdin.Name()}).Return([]byte("foo\nbaz")).Times(1)
+	r.On("CombinedOutputWithStdin", os.Stdin, "grep", []string{"-v", "bar", os.Stdin.Name()}).Return([]byte("foo\nbaz"), nil).Times(1)
 	f.commandRunner = r
 	// Via this temporary file
 	m := &mockTmpFileMaker{}
 	m.On("make", "", "easyssh").Return(os.Stdin, nil)
 	f.tmpFileMaker = m
 	// When passed these targets
-	input := target.FromStrings("foo", "bar", "foobar", "baz")
+	input := target.MustFromStrings("foo", "bar", "foobar", "baz")
 	// And return these.
 	expectedOutput := []target.Target{input[0], input[3]}
-	output := f.Filter(input)
+	output := mustFilter(t, f, input)
 	if !reflect.DeepEqual(output, expectedOutput) {
 		t.Error(input, output, expectedOutput)
 	}
@@ -1,15 +1,35 @@
 package filters
 
 import (
+	"fmt"
 	"sort"
 
 	"github.com/abesto/easyssh/fromsexp"
 	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/plugin"
 	"github.com/abesto/easyssh/util"
 )
 
-func Make(input string) interfaces.TargetFilter {
-	return fromsexp.MakeFromString(input, nil, makeByName).(interfaces.TargetFilter)
+// Make creates a filter from its definition. It's not safe for concurrent use, see fromsexp.Exclusive.
+func Make(input string) (interfaces.TargetFilter, error) {
+	f, err := fromsexp.MakeFromString(input, nil, makeByName)
+	if err != nil {
+		return nil, err
+	}
+	return f.(interfaces.TargetFilter), nil
+}
+
+/*
+Register makes a filter available to Make under name, next to the built-in ones. maker must return a new,
+unconfigured instance on each call; Make calls SetArgs on it. Register is not safe to call concurrently with Make,
+so it's best called from an init function.
+*/
+func Register(name string, maker func() interfaces.TargetFilter) error {
+	if _, exists := filterMakerMap[name]; exists {
+		return fmt.Errorf("filter \"%s\" is already defined", name)
+	}
+	filterMakerMap[name] = maker
+	return nil
 }
 
 func SupportedFilterNames() []string {
@@ -23,8 +43,16 @@ func SupportedFilterNames() []string {
 	return keys
 }
 
-func makeFromSExp(data []interface{}) interfaces.TargetFilter {
-	return fromsexp.Make(data, nil, makeByName).(interfaces.TargetFilter)
+func makeFromSExp(data interface{}) (interfaces.TargetFilter, error) {
+	list, err := util.ListArg(data)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fromsexp.Make(list, nil, makeByName)
+	if err != nil {
+		return nil, err
+	}
+	return f.(interfaces.TargetFilter), nil
 }
 
 const (
@@ -34,6 +62,8 @@ const (
 	nameFirst         = "first"
 	nameExternal      = "external"
 	nameCoalesce      = "coalesce"
+	nameVia           = "via"
+	nameCached        = "cached"
 )
 
 var filterMakerMap = map[string]func() interfaces.TargetFilter{
@@ -51,9 +81,11 @@ var filterMakerMap = map[string]func() interfaces.TargetFilter{
 		}
 	},
 	nameCoalesce: func() interfaces.TargetFilter { return &coalesce{} },
+	nameVia:      func() interfaces.TargetFilter { return &via{} },
+	nameCached:   func() interfaces.TargetFilter { return &cached{} },
 }
 
-func makeByName(name string) interface{} {
+func makeByName(name string) (interface{}, error) {
 	var d interfaces.TargetFilter
 	for key, maker := range filterMakerMap {
 		if key == name {
@@ -61,7 +93,12 @@ func makeByName(name string) interface{} {
 		}
 	}
 	if d == nil {
-		util.Panicf("filter \"%s\" is not known", name)
+		if path, ok := plugin.Lookup(plugin.KindFilter, name); ok {
+			return plugin.NewFilter(name, path), nil
+		}
+		err := util.ParseErrorf("filter \"%s\" is not known", name)
+		err.Suggestion = util.Suggest(name, append(SupportedFilterNames(), plugin.Names(plugin.KindFilter)...))
+		return nil, err
 	}
-	return d
+	return d, nil
 }
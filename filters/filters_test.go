@@ -4,11 +4,25 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/target"
 	"github.com/abesto/easyssh/util"
 )
 
+func mustMake(t *testing.T, input string) interfaces.TargetFilter {
+	f, err := Make(input)
+	util.ExpectNoError(t, err)
+	return f
+}
+
+func mustFilter(t *testing.T, f interfaces.TargetFilter, targets []target.Target) []target.Target {
+	output, err := f.Filter(targets)
+	util.ExpectNoError(t, err)
+	return output
+}
+
 func TestSupportedFilterNames(t *testing.T) {
-	expectedNames := []string{"coalesce", "first", "external", "ec2-instance-id", "list", "id"}
+	expectedNames := []string{"coalesce", "first", "external", "ec2-instance-id", "list", "id", "via", "cached"}
 	actualNames := SupportedFilterNames()
 
 	sort.Strings(expectedNames)
@@ -25,7 +39,19 @@ func TestSupportedFilterNames(t *testing.T) {
 }
 
 func TestMakeFilterWrongName(t *testing.T) {
-	util.ExpectPanic(t, "filter \"foo-bar\" is not known", func() {
-		Make("(list (foo-bar))")
-	})
+	_, err := Make("(list (foo-bar))")
+	util.ExpectError(t, "filter \"foo-bar\" is not known in (foo-bar) at position 6", err)
+	_, err = Make("(list (firts))")
+	util.ExpectError(t, "filter \"firts\" is not known in (firts) at position 6; did you mean first?", err)
+
+}
+
+func TestRegister(t *testing.T) {
+	util.ExpectNoError(t, Register("test-registered", func() interfaces.TargetFilter { return &first{} }))
+	defer delete(filterMakerMap, "test-registered")
+
+	f := mustMake(t, "(list (test-registered))")
+	target.AssertTargetListEquals(t, target.MustFromStrings("a"), mustFilter(t, f, target.MustFromStrings("a", "b")))
+
+	util.ExpectError(t, "filter \"id\" is already defined", Register("id", func() interfaces.TargetFilter { return &id{} }))
 }
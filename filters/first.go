@@ -9,14 +9,14 @@ import (
 
 type first struct{}
 
-func (f *first) Filter(targets []target.Target) []target.Target {
+func (f *first) Filter(targets []target.Target) ([]target.Target, error) {
 	if len(targets) > 0 {
-		return targets[0:1]
+		return targets[0:1], nil
 	}
-	return targets
+	return targets, nil
 }
-func (f *first) SetArgs(args []interface{}) {
-	util.RequireNoArguments(f, args)
+func (f *first) SetArgs(args []interface{}) error {
+	return util.RequireNoArguments(f, args)
 }
 func (f *first) String() string {
 	return fmt.Sprintf("<%s>", nameFirst)
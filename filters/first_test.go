@@ -13,19 +13,20 @@ func TestFirstStringViaMake(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(first)", "[first]")
 		l.ExpectDebugf("Make %s -> %s", "[first]", "<first>")
-		Make("(first)")
+		mustMake(t, "(first)")
 	})
 }
 
 func TestFirstMakeWithArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(first foo)", "[first foo]")
-		util.ExpectPanic(t, "<first> doesn't take any arguments, got 1: [foo]", func() { Make("(first foo)") })
+		_, err := Make("(first foo)")
+		util.ExpectError(t, "<first> doesn't take any arguments, got 1: [foo] in (first foo) at position 0", err)
 	})
 }
 
 func TestFirstOperation(t *testing.T) {
-	f := Make("(first)").(*first)
+	f := mustMake(t, "(first)").(*first)
 
 	cases := []struct {
 		input          []string
@@ -37,8 +38,8 @@ func TestFirstOperation(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		output := f.Filter(target.FromStrings(c.input...))
-		expectedOutput := target.FromStrings(c.expectedOutput...)
+		output := mustFilter(t, f, target.MustFromStrings(c.input...))
+		expectedOutput := target.MustFromStrings(c.expectedOutput...)
 		if len(output) != len(expectedOutput) {
 			t.Error(c, output)
 		}
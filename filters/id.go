@@ -9,11 +9,11 @@ import (
 
 type id struct{}
 
-func (f *id) Filter(targets []target.Target) []target.Target {
-	return targets
+func (f *id) Filter(targets []target.Target) ([]target.Target, error) {
+	return targets, nil
 }
-func (f *id) SetArgs(args []interface{}) {
-	util.RequireNoArguments(f, args)
+func (f *id) SetArgs(args []interface{}) error {
+	return util.RequireNoArguments(f, args)
 }
 func (f *id) String() string {
 	return fmt.Sprintf("<%s>", nameId)
@@ -12,22 +12,23 @@ func TestIdStringViaMake(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(id)", "[id]")
 		l.ExpectDebugf("Make %s -> %s", "[id]", "<id>")
-		Make("(id)")
+		mustMake(t, "(id)")
 	})
 }
 
 func TestIdMakeWithArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(id foo)", "[id foo]")
-		util.ExpectPanic(t, "<id> doesn't take any arguments, got 1: [foo]", func() { Make("(id foo)") })
+		_, err := Make("(id foo)")
+		util.ExpectError(t, "<id> doesn't take any arguments, got 1: [foo] in (id foo) at position 0", err)
 	})
 }
 
 func TestIdOperation(t *testing.T) {
-	f := Make("(id)").(*id)
+	f := mustMake(t, "(id)").(*id)
 
-	before := target.FromStrings("one", "two")
-	after := f.Filter(before)
+	before := target.MustFromStrings("one", "two")
+	after := mustFilter(t, f, before)
 	if !reflect.DeepEqual(before, after) {
 		t.Error(before, after)
 	}
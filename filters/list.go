@@ -13,21 +13,40 @@ type list struct {
 	children []interfaces.TargetFilter
 }
 
-func (f *list) Filter(targets []target.Target) []target.Target {
-	util.RequireArgumentsAtLeast(f, 1, f.args)
+func (f *list) Filter(targets []target.Target) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(f, 1, f.args); err != nil {
+		return nil, err
+	}
 	for _, child := range f.children {
-		targets = child.Filter(targets)
+		var err error
+		if targets, err = child.Filter(targets); err != nil {
+			return nil, err
+		}
 		util.Logger.Debugf("Targets after filter %s: %s", child, targets)
 	}
-	return targets
+	return targets, nil
 }
-func (f *list) SetArgs(args []interface{}) {
-	util.RequireArgumentsAtLeast(f, 1, args)
+func (f *list) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(f, 1, args); err != nil {
+		return err
+	}
 	f.args = args
 	f.children = make([]interfaces.TargetFilter, len(args))
 	for i, def := range args {
-		f.children[i] = makeFromSExp(def.([]interface{}))
+		child, err := makeFromSExp(def)
+		if err != nil {
+			return err
+		}
+		f.children[i] = child
+	}
+	return nil
+}
+func (f *list) Children() []interface{} {
+	children := make([]interface{}, len(f.children))
+	for i, child := range f.children {
+		children[i] = child
 	}
+	return children
 }
 func (f *list) String() string {
 	return fmt.Sprintf("<%s %s>", nameList, f.children)
@@ -18,28 +18,28 @@ func TestListStringViaMake(t *testing.T) {
 		l.ExpectDebugf("Make %s -> %s", "[id]", "<id>")
 		l.ExpectDebugf("Make %s -> %s", "[id]", "<id>")
 		l.ExpectDebugf("Make %s -> %s", structs, final)
-		Make(input)
+		mustMake(t, input)
 	})
 }
 
 func TestListMakeWithoutArgument(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("MakeFromString %s -> %s", "(list)", "[list]")
-		util.ExpectPanic(t, "<list []> requires at least 1 argument(s), got 0: []",
-			func() { Make("(list)") })
+		_, err := Make("(list)")
+		util.ExpectError(t, "<list []> requires at least 1 argument(s), got 0: [] in (list) at position 0", err)
 	})
 }
 
 func TestListFilterWithoutSetArgs(t *testing.T) {
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
-		util.ExpectPanic(t, "<list []> requires at least 1 argument(s), got 0: []",
-			func() { (&list{}).Filter([]target.Target{}) })
+		_, err := (&list{}).Filter([]target.Target{})
+		util.ExpectError(t, "<list []> requires at least 1 argument(s), got 0: []", err)
 	})
 }
 
 func TestListSetArgs(t *testing.T) {
 	input := "(list (id) (id))"
-	f := Make(input).(*list)
+	f := mustMake(t, input).(*list)
 	if len(f.children) != 2 || f.children[0].String() != "<id>" || f.children[1].String() != "<id>" {
 		t.Error("children", f.children)
 	}
@@ -53,17 +53,22 @@ type appendString struct {
 	stringToAppend string
 }
 
-func (f *appendString) Filter(targets []target.Target) []target.Target {
-	util.RequireArgumentsAtLeast(f, 1, f.args)
+func (f *appendString) Filter(targets []target.Target) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(f, 1, f.args); err != nil {
+		return nil, err
+	}
 	for i := range targets {
 		targets[i].Host += f.stringToAppend
 	}
-	return targets
+	return targets, nil
 }
-func (f *appendString) SetArgs(args []interface{}) {
-	util.RequireArgumentsAtLeast(f, 1, args)
+func (f *appendString) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(f, 1, args); err != nil {
+		return err
+	}
 	f.args = args
 	f.stringToAppend = string(args[0].([]byte))
+	return nil
 }
 func (f *appendString) String() string {
 	return fmt.Sprintf("<%s %s>", "append-string", f.stringToAppend)
@@ -74,13 +79,13 @@ func TestListOperation(t *testing.T) {
 		return &appendString{}
 	}
 
-	f := Make("(list (append-string foo) (append-string bar))").(*list)
+	f := mustMake(t, "(list (append-string foo) (append-string bar))").(*list)
 
 	var ts []target.Target
 	util.WithLogAssertions(t, func(l *util.MockLogger) {
 		l.ExpectDebugf("Targets after filter %s: %s", "<append-string foo>", "[onefoo twofoo]")
 		l.ExpectDebugf("Targets after filter %s: %s", "<append-string bar>", "[onefoobar twofoobar]")
-		ts = f.Filter(target.FromStrings("one", "two"))
+		ts = mustFilter(t, f, target.MustFromStrings("one", "two"))
 	})
 
 	if len(ts) != 2 || ts[0].Host != "onefoobar" || ts[1].Host != "twofoobar" {
@@ -0,0 +1,155 @@
+package filters
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// The kinds of rules accepted by via, besides a plain list of jump hosts that applies to all targets
+const (
+	viaRuleCIDR   = "cidr"
+	viaRuleHost   = "host"
+	viaRuleRegion = "region"
+)
+
+type viaRule struct {
+	kind    string
+	pattern string
+	network *net.IPNet
+	hops    []string
+}
+
+func (r viaRule) matches(t target.Target) bool {
+	switch r.kind {
+	case viaRuleCIDR:
+		ip := net.ParseIP(t.IP)
+		return ip != nil && r.network.Contains(ip)
+	case viaRuleHost:
+		for _, host := range []string{t.Host, t.Hostname} {
+			if matched, _ := path.Match(r.pattern, host); host != "" && matched {
+				return true
+			}
+		}
+		return false
+	case viaRuleRegion:
+		matched, _ := path.Match(r.pattern, t.Labels["region"])
+		return t.Labels["region"] != "" && matched
+	}
+	return true
+}
+
+func (r viaRule) String() string {
+	if r.kind == "" {
+		return strings.Join(r.hops, " ")
+	}
+	return fmt.Sprintf("(%s %s %s)", r.kind, r.pattern, strings.Join(r.hops, " "))
+}
+
+type via struct {
+	args  []interface{}
+	rules []viaRule
+}
+
+func (f *via) Filter(targets []target.Target) ([]target.Target, error) {
+	if err := util.RequireArgumentsAtLeast(f, 1, f.args); err != nil {
+		return nil, err
+	}
+	newTargets := make([]target.Target, len(targets))
+	for i, t := range targets {
+		newTargets[i] = t
+		if len(t.ProxyJump) > 0 {
+			// Jump hosts set by the discoverer, like ProxyJump in ssh_config, are more specific than any rule
+			util.Logger.Debugf("Keeping jump hosts %s of %s", t.ProxyJump, t.FriendlyName())
+			continue
+		}
+		for _, rule := range f.rules {
+			if rule.matches(t) {
+				newTargets[i].ProxyJump = append([]string{}, rule.hops...)
+				util.Logger.Debugf("Reaching %s via %s", t.FriendlyName(), rule.hops)
+				break
+			}
+		}
+	}
+	return newTargets, nil
+}
+
+func (f *via) SetArgs(args []interface{}) error {
+	if err := util.RequireArgumentsAtLeast(f, 1, args); err != nil {
+		return err
+	}
+	var rules []viaRule
+	if _, isList := args[0].([]interface{}); isList {
+		for _, arg := range args {
+			rule, err := parseViaRule(arg)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+	} else {
+		hops, err := parseViaHops(args)
+		if err != nil {
+			return err
+		}
+		rules = []viaRule{{hops: hops}}
+	}
+	f.args = args
+	f.rules = rules
+	return nil
+}
+
+func parseViaRule(arg interface{}) (viaRule, error) {
+	var rule viaRule
+	list, err := util.ListArg(arg)
+	if err != nil {
+		return rule, util.ParseErrorf("Expected only rules after the first rule, got: %s", arg)
+	}
+	strs, err := util.ByteToStringArray(list)
+	if err != nil {
+		return rule, err
+	}
+	if len(strs) == 0 {
+		return rule, util.ParseErrorf("Expected a rule, got an empty list")
+	}
+	rule.kind = strs[0]
+	if rule.kind != viaRuleCIDR && rule.kind != viaRuleHost && rule.kind != viaRuleRegion {
+		err := util.ParseErrorf("Unknown via rule %s", rule.kind)
+		err.Suggestion = util.Suggest(rule.kind, []string{viaRuleCIDR, viaRuleHost, viaRuleRegion})
+		return rule, err
+	}
+	if len(strs) < 3 {
+		return rule, util.ParseErrorf("%s rules require a pattern and at least one jump host, got: %s", rule.kind, strs[1:])
+	}
+	rule.pattern = strs[1]
+	if rule.kind == viaRuleCIDR {
+		if _, rule.network, err = net.ParseCIDR(rule.pattern); err != nil {
+			return rule, util.ParseErrorf("Invalid CIDR %s", rule.pattern)
+		}
+	} else if _, err := path.Match(rule.pattern, ""); err != nil {
+		return rule, util.ParseErrorf("Invalid glob %s: %s", rule.pattern, err)
+	}
+	rule.hops, err = parseViaHops(list[2:])
+	return rule, err
+}
+
+func parseViaHops(args []interface{}) ([]string, error) {
+	hops, err := util.ByteToStringArray(args)
+	if err != nil {
+		return nil, err
+	}
+	for _, hop := range hops {
+		if _, err := target.FromString(hop); err != nil {
+			return nil, util.ParseErrorf("Invalid jump host: %s", err)
+		}
+	}
+	return hops, nil
+}
+
+func (f *via) String() string {
+	return fmt.Sprintf("<%s %s>", nameVia, f.rules)
+}
@@ -0,0 +1,89 @@
+package filters
+
+import (
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+func TestViaStringViaMake(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		input := "(via (cidr 10.0.0.0/8 bastion) (host *.eu bastion-eu inner))"
+		structs := "[via [cidr 10.0.0.0/8 bastion] [host *.eu bastion-eu inner]]"
+		final := "<via [(cidr 10.0.0.0/8 bastion) (host *.eu bastion-eu inner)]>"
+		l.ExpectDebugf("MakeFromString %s -> %s", input, structs)
+		l.ExpectDebugf("Make %s -> %s", structs, final)
+		mustMake(t, input)
+	})
+}
+
+func TestViaMakeWithoutArgument(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		l.ExpectDebugf("MakeFromString %s -> %s", "(via)", "[via]")
+		_, err := Make("(via)")
+		util.ExpectError(t, "<via []> requires at least 1 argument(s), got 0: [] in (via) at position 0", err)
+	})
+}
+
+func TestViaFilterWithoutSetArgs(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		_, err := (&via{}).Filter([]target.Target{})
+		util.ExpectError(t, "<via []> requires at least 1 argument(s), got 0: []", err)
+	})
+}
+
+func TestViaInvalidDefinitions(t *testing.T) {
+	cases := []struct {
+		input string
+		err   string
+	}{
+		{"(via bastion (cidr 10.0.0.0/8 b))", "Expected a string, got a list: [cidr 10.0.0.0/8 b] in (via bastion (cidr 10.0.0.0/8 b)) at position 0"},
+		{"(via (cidr 10.0.0.0/8 b) bastion)", "Expected only rules after the first rule, got: bastion in (via (cidr 10.0.0.0/8 b) bastion) at position 0"},
+		{"(via (cdir 10.0.0.0/8 b))", "Unknown via rule cdir in (via (cdir 10.0.0.0/8 b)) at position 0; did you mean cidr?"},
+		{"(via (host web-*))", "host rules require a pattern and at least one jump host, got: [web-*] in (via (host web-*)) at position 0"},
+		{"(via (cidr 10.0.0.0 b))", "Invalid CIDR 10.0.0.0 in (via (cidr 10.0.0.0 b)) at position 0"},
+		{"(via (region [ b))", "Invalid glob [: syntax error in pattern in (via (region [ b)) at position 0"},
+		{"(via a@b@c)", "Invalid jump host: Invalid target \"a@b@c\": more than one @ character in (via a@b@c) at position 0"},
+	}
+	for _, c := range cases {
+		_, err := Make(c.input)
+		util.ExpectError(t, c.err, err)
+	}
+}
+
+func TestViaFixed(t *testing.T) {
+	f := mustMake(t, "(via bastion admin@inner:2200)")
+	targets := mustFilter(t, f, target.MustFromStrings("a", "10.0.0.1"))
+	for _, actual := range targets {
+		util.AssertStringListEquals(t, []string{"bastion", "admin@inner:2200"}, actual.ProxyJump)
+	}
+}
+
+func TestViaRules(t *testing.T) {
+	f := mustMake(t, "(via (cidr 10.1.0.0/16 bastion-private) (host *.eu.example.com bastion-eu) (region us-* bastion-us))")
+	eu := target.Target{Host: "app.eu.example.com", IP: "10.1.2.3"}
+	us := target.Target{Host: "i-1", Labels: map[string]string{"region": "us-east-1"}}
+	euByHostname := target.Target{IP: "1.2.3.4", Hostname: "db.eu.example.com"}
+	direct := target.Target{Host: "public.example.com", IP: "1.2.3.5", ProxyJump: []string{"existing"}}
+	targets := mustFilter(t, f, []target.Target{eu, us, euByHostname, direct})
+	expected := [][]string{{"bastion-private"}, {"bastion-us"}, {"bastion-eu"}, {"existing"}}
+	for i, hops := range expected {
+		util.AssertStringListEquals(t, hops, targets[i].ProxyJump)
+	}
+}
+
+func TestViaKeepsExistingJumpHosts(t *testing.T) {
+	f := mustMake(t, "(via bastion)")
+	targets := target.MustFromStrings("a", "b")
+	targets[0].ProxyJump = []string{"gateway"}
+	filtered := mustFilter(t, f, targets)
+	util.AssertStringListEquals(t, []string{"gateway"}, filtered[0].ProxyJump)
+	util.AssertStringListEquals(t, []string{"bastion"}, filtered[1].ProxyJump)
+
+	// Targets don't share the jump hosts of the rule they matched
+	filtered = mustFilter(t, f, target.MustFromStrings("a", "b"))
+	filtered[0].ProxyJump[0] = "changed"
+	util.AssertStringListEquals(t, []string{"bastion"}, filtered[1].ProxyJump)
+	util.AssertStringListEquals(t, []string{"bastion"}, mustFilter(t, f, target.MustFromStrings("c"))[0].ProxyJump)
+}
@@ -1,45 +1,202 @@
 package fromsexp
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/abesto/easyssh/interfaces"
 	"github.com/abesto/easyssh/util"
 	"github.com/abesto/sexp"
 )
 
-func MakeFromString(input string, transforms []SexpTransform, makeByName func(name string) interface{}) interface{} {
+func MakeFromString(input string, transforms []SexpTransform, makeByName func(name string) (interface{}, error)) (interface{}, error) {
 	data, err := sexp.Unmarshal([]byte(input))
 	if err != nil {
-		util.Panicf(err.Error())
+		return nil, &util.ParseError{Msg: err.Error(), Expr: input, Pos: -1}
 	}
 	util.Logger.Debugf("MakeFromString %s -> %s", input, data)
-	var result = Make(data, transforms, makeByName)
+
+	previous, previousPos := positions, enclosingPos
+	positions, enclosingPos = listPositions(input, data), strings.Index(input, "(")
+	defer func() { positions, enclosingPos = previous, previousPos }()
+
+	return Make(data, transforms, makeByName)
+}
+
+// positions maps the first element of each list parsed by the innermost MakeFromString call to its offset in the input
+var positions map[*interface{}]int
+
+// listPositions returns the offset of the opening bracket of each non-empty list in data, keyed by its first element
+func listPositions(input string, data []interface{}) map[*interface{}]int {
+	offsets := []int{}
+	lexer := sexp.NewLexer([]byte(input))
+	for item := lexer.Next(); item.Type != sexp.ItemEOF && item.Type != sexp.ItemError; item = lexer.Next() {
+		if item.Type == sexp.ItemBracketLeft {
+			offsets = append(offsets, item.Position)
+		}
+	}
+	result := map[*interface{}]int{}
+	var walk func([]interface{})
+	walk = func(list []interface{}) {
+		if len(offsets) == 0 {
+			return
+		}
+		if len(list) > 0 {
+			result[&list[0]] = offsets[0]
+		}
+		offsets = offsets[1:]
+		for _, item := range list {
+			if child, ok := item.([]interface{}); ok {
+				walk(child)
+			}
+		}
+	}
+	walk(data)
 	return result
 }
 
-func Make(data []interface{}, transforms []SexpTransform, makeByName func(name string) interface{}) interface{} {
-	// Apply any transforms
-	if transforms != nil {
+func positionOf(data []interface{}) int {
+	if len(data) == 0 {
+		return -1
+	}
+	if pos, ok := positions[&data[0]]; ok {
+		return pos
+	}
+	return -1
+}
+
+var depth = 0
+
+var buildMutex sync.Mutex
+
+/*
+Exclusive runs f holding the lock that serializes building components. Make, MakeFromString, Trace and the macro
+functions share package-level state, like the defined macros, the nesting depth and the active trace, so programs
+building components from several goroutines must call them, and the Make functions of the discoverers, filters and
+executors packages, only from inside Exclusive. pipeline.FromDefinitions and Config.DefineMacros do.
+*/
+func Exclusive(f func()) {
+	buildMutex.Lock()
+	defer buildMutex.Unlock()
+	f()
+}
+
+// enclosingPos is the position of the closest enclosing definition with a known position
+var enclosingPos = -1
+
+func Make(data []interface{}, transforms []SexpTransform, makeByName func(name string) (interface{}, error)) (interface{}, error) {
+	depth++
+	previousPos := enclosingPos
+	defer func() {
+		depth--
+		enclosingPos = previousPos
+	}()
+	original := data
+	if pos := positionOf(data); pos >= 0 {
+		enclosingPos = pos
+	}
+
+	o, err := build(data, transforms, makeByName)
+	if parseErr, ok := err.(*util.ParseError); ok && parseErr.Expr == "" {
+		parseErr.Expr = Format(original)
+		parseErr.Pos = enclosingPos
+	}
+	return o, err
+}
+
+func build(data []interface{}, transforms []SexpTransform, makeByName func(name string) (interface{}, error)) (interface{}, error) {
+	if depth > MaxDepth {
+		return nil, util.ParseErrorf("Maximum nesting depth %d exceeded while making %s", MaxDepth, data)
+	}
+
+	var node *TraceNode
+	if activeTracer != nil {
+		node = activeTracer.push(data)
+		defer activeTracer.pop()
+	}
+
+	// Apply any transforms, including user-defined macros, until none of them matches
+	transforms = append(macroTransforms(), transforms...)
+	for expansions := 0; ; expansions++ {
+		if expansions > MaxDepth {
+			return nil, util.ParseErrorf("Maximum expansion depth %d exceeded while expanding %s", MaxDepth, data)
+		}
+		changed := false
 		for _, item := range transforms {
 			if item.Matches(data) {
-				newData := item.Transform(data)
+				newData, err := item.Transform(data)
+				if err != nil {
+					return nil, err
+				}
 				util.Logger.Debugf("Transform: %s -> %s", data, newData)
 				data = newData
+				changed = true
+				if node != nil {
+					node.Transforms = append(node.Transforms, item.Name)
+				}
 			}
 		}
+		if !changed {
+			break
+		}
+	}
+	if len(data) == 0 {
+		return nil, util.ParseErrorf("Expected a non-empty list")
+	}
+	nameBytes, ok := data[0].([]byte)
+	if !ok {
+		return nil, util.ParseErrorf("Expected a name as the first element of a list, got a list: %s", data[0])
 	}
+
 	// Build using provided constructor
-	//	util.Logger.Debug(data)
-	var name = string(data[0].([]byte))
-	var o = makeByName(name).(interfaces.HasSetArgs)
-	o.SetArgs(data[1:])
+	made, err := makeByName(string(nameBytes))
+	if err != nil {
+		return nil, err
+	}
+	var o = made.(interfaces.HasSetArgs)
+	if node != nil {
+		node.Expanded = data
+		node.Object = o
+	}
+	if err := o.SetArgs(data[1:]); err != nil {
+		// Checking PATH is the last thing components do in SetArgs, so they are complete even if it fails
+		missing, ok := err.(*util.MissingBinaryError)
+		if !ok || !activeTracer.allowsMissingBinaries() {
+			return nil, err
+		}
+		util.Logger.Debugf("%s", missing)
+	}
 	util.Logger.Debugf("Make %s -> %s", data, o)
-	return o
+	return o, nil
+}
+
+/*
+Format renders data as an S-Expression, the way a user would write it. Atoms are quoted only if they contain
+whitespace or brackets.
+*/
+func Format(data interface{}) string {
+	switch d := data.(type) {
+	case []interface{}:
+		items := make([]string, len(d))
+		for i, item := range d {
+			items[i] = Format(item)
+		}
+		return "(" + strings.Join(items, " ") + ")"
+	case []byte:
+		str := string(d)
+		if str == "" || strings.ContainsAny(str, " \t\n()\"") {
+			return strconv.Quote(str)
+		}
+		return str
+	}
+	return fmt.Sprintf("%v", data)
 }
 
 type SexpTransformMatcher func(input []interface{}) bool
-type SexpTransformFunction func(input []interface{}) []interface{}
+type SexpTransformFunction func(input []interface{}) ([]interface{}, error)
 
 type SexpTransform struct {
 	Name      string
@@ -47,9 +204,9 @@ type SexpTransform struct {
 	Transform SexpTransformFunction
 }
 
-func (t SexpTransform) TransformIfMatches(input []interface{}) []interface{} {
+func (t SexpTransform) TransformIfMatches(input []interface{}) ([]interface{}, error) {
 	if !t.Matches(input) {
-		return input
+		return input, nil
 	}
 	return t.Transform(input)
 }
@@ -66,8 +223,8 @@ func Replace(original string, replacement string) SexpTransform {
 	return SexpTransform{
 		Name:    string(originalData[0].([]byte)),
 		Matches: func(input []interface{}) bool { return reflect.DeepEqual(originalData, input) },
-		Transform: func(input []interface{}) []interface{} {
-			return replacementData
+		Transform: func(input []interface{}) ([]interface{}, error) {
+			return replacementData, nil
 		},
 	}
 }
@@ -82,11 +239,11 @@ func Alias(from string, to string) SexpTransform {
 			}
 			return reflect.DeepEqual(input[0], fromBytes)
 		},
-		Transform: func(input []interface{}) []interface{} {
+		Transform: func(input []interface{}) ([]interface{}, error) {
 			output := make([]interface{}, len(input))
 			copy(output[1:], input[1:])
 			output[0] = []byte(to)
-			return output
+			return output, nil
 		},
 	}
 }
@@ -15,16 +15,20 @@ type MockWithMakeByName struct {
 	mock.Mock
 }
 
-func (m *MockWithMakeByName) makeByName(name string) interface{} {
-	return m.Called(name).Get(0)
+func (m *MockWithMakeByName) makeByName(name string) (interface{}, error) {
+	ret := m.Called(name)
+	err, _ := ret.Get(1).(error)
+	return ret.Get(0), err
 }
 
 type MockHasSetArgs struct {
 	mock.Mock
 }
 
-func (s *MockHasSetArgs) SetArgs(args []interface{}) {
-	s.Called(fmt.Sprintf("%s", args))
+func (s *MockHasSetArgs) SetArgs(args []interface{}) error {
+	ret := s.Called(fmt.Sprintf("%s", args))
+	err, _ := ret.Get(0).(error)
+	return err
 }
 
 func TestAlias(t *testing.T) {
@@ -41,7 +45,8 @@ func TestAlias(t *testing.T) {
 	for _, item := range cases {
 		inputData, _ := sexp.Unmarshal([]byte(item.input))
 		expectedData, _ := sexp.Unmarshal([]byte(item.expected))
-		actualData := transform.TransformIfMatches(inputData)
+		actualData, err := transform.TransformIfMatches(inputData)
+		util.ExpectNoError(t, err)
 		if !reflect.DeepEqual(expectedData, actualData) {
 			t.Errorf("%v returned %s for input %s. Expected %s.", transform, actualData, inputData, expectedData)
 		}
@@ -65,7 +70,8 @@ func TestReplace(t *testing.T) {
 	for _, item := range cases {
 		inputData, _ := sexp.Unmarshal([]byte(item.input))
 		expectedData, _ := sexp.Unmarshal([]byte(item.expected))
-		actualData := transform.TransformIfMatches(inputData)
+		actualData, err := transform.TransformIfMatches(inputData)
+		util.ExpectNoError(t, err)
 		if !reflect.DeepEqual(expectedData, actualData) {
 			t.Errorf("%v returned %s for input %s. Expected %s.", transform, actualData, inputData, expectedData)
 		}
@@ -77,9 +83,10 @@ func TestMakeFromStringWithoutTransforms(t *testing.T) {
 	input := "(foo bar baz)"
 	expectedFoo := &MockHasSetArgs{}
 
-	m.On("makeByName", "foo").Times(1).Return(expectedFoo)
-	expectedFoo.On("SetArgs", "[bar baz]").Times(1)
-	actualFoo := MakeFromString(input, []SexpTransform{}, m.makeByName)
+	m.On("makeByName", "foo").Times(1).Return(expectedFoo, nil)
+	expectedFoo.On("SetArgs", "[bar baz]").Times(1).Return(nil)
+	actualFoo, err := MakeFromString(input, []SexpTransform{}, m.makeByName)
+	util.ExpectNoError(t, err)
 
 	if actualFoo != expectedFoo {
 		t.Errorf("MakeFromString returned %v, expected: %v", actualFoo, expectedFoo)
@@ -98,9 +105,10 @@ func TestMakeFromStringWithTransforms(t *testing.T) {
 		Replace("(say (xxx (yyy)))", "(say hello world)"),
 	}
 
-	m.On("makeByName", "say").Times(1).Return(expectedFoo)
-	expectedFoo.On("SetArgs", "[hello world]").Times(1)
-	actualFoo := MakeFromString(input, transforms, m.makeByName)
+	m.On("makeByName", "say").Times(1).Return(expectedFoo, nil)
+	expectedFoo.On("SetArgs", "[hello world]").Times(1).Return(nil)
+	actualFoo, err := MakeFromString(input, transforms, m.makeByName)
+	util.ExpectNoError(t, err)
 
 	if actualFoo != expectedFoo {
 		t.Errorf("MakeFromString returned %v, expected: %v", actualFoo, expectedFoo)
@@ -111,14 +119,49 @@ func TestMakeFromStringWithTransforms(t *testing.T) {
 }
 
 func TestInvalidInputs(t *testing.T) {
-	makeByName := func(s string) interface{} { return nil }
+	makeByName := func(s string) (interface{}, error) { return nil, nil }
 	transforms := []SexpTransform{}
 	cases := [](func()){
-		func() { MakeFromString("---qqqq", transforms, makeByName) },
 		func() { Replace("---", "(x)") },
 		func() { Replace("(x)", "---") },
 	}
 	for _, item := range cases {
 		util.ExpectPanic(t, nil, item)
 	}
+
+	_, err := MakeFromString("(foo", transforms, makeByName)
+	if parseErr, ok := err.(*util.ParseError); !ok || parseErr.Expr != "(foo" || parseErr.Pos != -1 {
+		t.Errorf("Unexpected error for a syntax error: %#v", err)
+	}
+	_, err = MakeFromString("()", transforms, makeByName)
+	util.ExpectError(t, "Expected a non-empty list in () at position 0", err)
+	_, err = MakeFromString("((foo) bar)", transforms, makeByName)
+	util.ExpectError(t, "Expected a name as the first element of a list, got a list: [foo] in ((foo) bar) at position 0", err)
+}
+
+func TestErrorPositions(t *testing.T) {
+	var makeByName func(string) (interface{}, error)
+	makeByName = func(name string) (interface{}, error) {
+		if name == "bad" {
+			return nil, &util.ParseError{Msg: "Unknown bad", Pos: -1, Suggestion: "good"}
+		}
+		return &setArgsMakingChildren{makeByName, []SexpTransform{Alias("worse", "bad")}}, nil
+	}
+
+	_, err := MakeFromString("(outer x (inner (bad y)))", nil, makeByName)
+	util.ExpectError(t, "Unknown bad in (bad y) at position 16; did you mean good?", err)
+	if util.ExitCode(err) != util.ExitCodeParse {
+		t.Errorf("Unexpected exit code %d", util.ExitCode(err))
+	}
+
+	// Definitions resulting from transforms are reported at the position of the definition they came from
+	_, err = MakeFromString("(outer (worse))", nil, makeByName)
+	util.ExpectError(t, "Unknown bad in (worse) at position 7; did you mean good?", err)
+
+	// Other errors are passed through unchanged
+	targetErr := &util.InvalidTargetError{Input: "x", Msg: "y"}
+	_, err = MakeFromString("(foo)", nil, func(string) (interface{}, error) { return nil, targetErr })
+	if err != targetErr {
+		t.Errorf("Unexpected error %v", err)
+	}
 }
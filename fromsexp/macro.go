@@ -0,0 +1,152 @@
+package fromsexp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/abesto/easyssh/util"
+)
+
+// MaxDepth limits how deeply macros can expand into each other, and how deeply components can be nested
+const MaxDepth = 64
+
+// MacroParamPrefix marks the atoms in a macro definition that are parameters
+const MacroParamPrefix = "$"
+
+/*
+Macro is a user-defined transform: (name args...) is replaced with Body, after replacing each occurrence of the
+n-th parameter in Body with the n-th argument.
+*/
+type Macro struct {
+	Name   string
+	Params []string
+	Body   []interface{}
+}
+
+var macros = map[string]Macro{}
+
+/*
+ParseMacro creates a Macro from the arguments of a defmacro form: ((name $param...) body)
+*/
+func ParseMacro(args []interface{}) (Macro, error) {
+	var m Macro
+	if len(args) != 2 {
+		return m, util.ParseErrorf("defmacro requires exactly 2 arguments (signature and body), got %d: %s", len(args), args)
+	}
+	signature, ok := args[0].([]interface{})
+	if !ok || len(signature) == 0 {
+		return m, util.ParseErrorf("The signature of a macro must be a non-empty list, got: %s", args[0])
+	}
+	body, ok := args[1].([]interface{})
+	if !ok || len(body) == 0 {
+		return m, util.ParseErrorf("The body of a macro must be a non-empty list, got: %s", args[1])
+	}
+	name, err := macroAtom(signature[0])
+	if err != nil {
+		return m, err
+	}
+	m = Macro{Name: name, Body: body}
+	seen := map[string]bool{}
+	for _, param := range signature[1:] {
+		name, err := macroAtom(param)
+		if err != nil {
+			return m, err
+		}
+		if !strings.HasPrefix(name, MacroParamPrefix) {
+			return m, util.ParseErrorf("Parameter %s of macro %s must start with %s", name, m.Name, MacroParamPrefix)
+		}
+		if seen[name] {
+			return m, util.ParseErrorf("Parameter %s of macro %s is defined more than once", name, m.Name)
+		}
+		seen[name] = true
+		m.Params = append(m.Params, name)
+	}
+	return m, nil
+}
+
+func macroAtom(data interface{}) (string, error) {
+	bytes, ok := data.([]byte)
+	if !ok {
+		return "", util.ParseErrorf("Expected a string in a macro signature, got a list: %s", data)
+	}
+	return string(bytes), nil
+}
+
+/*
+DefineMacro makes m available to all subsequent Make calls, regardless of the kind of component being made.
+A macro with the same name is replaced.
+*/
+func DefineMacro(m Macro) {
+	util.Logger.Debugf("Defining macro %s", m)
+	macros[m.Name] = m
+}
+
+// UndefineMacro removes the macro called name
+func UndefineMacro(name string) {
+	delete(macros, name)
+}
+
+// MacroNames returns the sorted names of all defined macros
+func MacroNames() []string {
+	names := make([]string, 0, len(macros))
+	for name := range macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func macroTransforms() []SexpTransform {
+	transforms := make([]SexpTransform, 0, len(macros))
+	for _, name := range MacroNames() {
+		transforms = append(transforms, macros[name].Transform())
+	}
+	return transforms
+}
+
+/*
+Transform returns the SexpTransform applying the macro
+*/
+func (m Macro) Transform() SexpTransform {
+	nameBytes := []byte(m.Name)
+	return SexpTransform{
+		Name: m.Name,
+		Matches: func(input []interface{}) bool {
+			return len(input) > 0 && reflect.DeepEqual(input[0], nameBytes)
+		},
+		Transform: func(input []interface{}) ([]interface{}, error) {
+			args := input[1:]
+			if len(args) != len(m.Params) {
+				return nil, util.ParseErrorf("Macro %s requires exactly %d argument(s), got %d: %s", m.Name, len(m.Params), len(args), args)
+			}
+			bindings := map[string]interface{}{}
+			for i, param := range m.Params {
+				bindings[param] = args[i]
+			}
+			return substitute(m.Body, bindings).([]interface{}), nil
+		},
+	}
+}
+
+func substitute(data interface{}, bindings map[string]interface{}) interface{} {
+	switch d := data.(type) {
+	case []byte:
+		if value, ok := bindings[string(d)]; ok {
+			return value
+		}
+		return d
+	case []interface{}:
+		output := make([]interface{}, len(d))
+		for i, item := range d {
+			output[i] = substitute(item, bindings)
+		}
+		return output
+	}
+	return data
+}
+
+func (m Macro) String() string {
+	return fmt.Sprintf("<macro (%s) -> %s>", strings.Join(append([]string{m.Name}, m.Params...), " "), m.Body)
+}
@@ -0,0 +1,167 @@
+package fromsexp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/abesto/sexp"
+
+	"github.com/abesto/easyssh/util"
+)
+
+func parseMacro(input string) (Macro, error) {
+	data, _ := sexp.Unmarshal([]byte(input))
+	return ParseMacro(data[1:])
+}
+
+func mustParseMacro(input string) Macro {
+	m, err := parseMacro(input)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func withMacros(definitions []string, f func()) {
+	defined := []string{}
+	for _, definition := range definitions {
+		m := mustParseMacro(definition)
+		DefineMacro(m)
+		defined = append(defined, m.Name)
+	}
+	defer func() {
+		for _, name := range defined {
+			UndefineMacro(name)
+		}
+	}()
+	f()
+}
+
+func TestParseMacro(t *testing.T) {
+	m := mustParseMacro("(defmacro (prod-exec $n $cmd) (assert-command (external-parallel $n $cmd)))")
+	if m.Name != "prod-exec" {
+		t.Error("name", m.Name)
+	}
+	util.AssertStringListEquals(t, []string{"$n", "$cmd"}, m.Params)
+	if m.String() != "<macro (prod-exec $n $cmd) -> [assert-command [external-parallel $n $cmd]]>" {
+		t.Error("string", m.String())
+	}
+}
+
+func TestParseMacroErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		err   string
+	}{
+		{"(defmacro (foo))", "defmacro requires exactly 2 arguments (signature and body), got 1: [[foo]]"},
+		{"(defmacro foo (bar))", "The signature of a macro must be a non-empty list, got: foo"},
+		{"(defmacro () (bar))", "The signature of a macro must be a non-empty list, got: []"},
+		{"(defmacro (foo) bar)", "The body of a macro must be a non-empty list, got: bar"},
+		{"(defmacro ((foo)) (bar))", "Expected a string in a macro signature, got a list: [foo]"},
+		{"(defmacro (foo x) (bar))", "Parameter x of macro foo must start with $"},
+		{"(defmacro (foo $x $x) (bar))", "Parameter $x of macro foo is defined more than once"},
+	}
+	for _, c := range cases {
+		_, err := parseMacro(c.input)
+		util.ExpectError(t, c.err, err)
+	}
+}
+
+func TestMacroTransform(t *testing.T) {
+	transform := mustParseMacro("(defmacro (wrap $a $b) (outer $a (inner $b) $a))").Transform()
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"(foo)", "(foo)"},
+		{"(wrap-not x y)", "(wrap-not x y)"},
+		{"(wrap x y)", "(outer x (inner y) x)"},
+		{"(wrap (x 1) y)", "(outer (x 1) (inner y) (x 1))"},
+	}
+	for _, item := range cases {
+		inputData, _ := sexp.Unmarshal([]byte(item.input))
+		expectedData, _ := sexp.Unmarshal([]byte(item.expected))
+		actualData, err := transform.TransformIfMatches(inputData)
+		util.ExpectNoError(t, err)
+		if !reflect.DeepEqual(expectedData, actualData) {
+			t.Errorf("%v returned %s for input %s. Expected %s.", transform, actualData, inputData, expectedData)
+		}
+	}
+	inputData, _ := sexp.Unmarshal([]byte("(wrap x)"))
+	_, err := transform.TransformIfMatches(inputData)
+	util.ExpectError(t, "Macro wrap requires exactly 2 argument(s), got 1: [x]", err)
+}
+
+func TestMakeWithNestedMacros(t *testing.T) {
+	definitions := []string{
+		"(defmacro (outer $x) (middle $x))",
+		"(defmacro (middle $x) (say $x))",
+	}
+	withMacros(definitions, func() {
+		m := &MockWithMakeByName{}
+		expected := &MockHasSetArgs{}
+		m.On("makeByName", "say").Times(1).Return(expected, nil)
+		expected.On("SetArgs", "[hello]").Times(1).Return(nil)
+		actual, err := MakeFromString("(outer hello)", []SexpTransform{}, m.makeByName)
+		util.ExpectNoError(t, err)
+		if actual != expected {
+			t.Errorf("MakeFromString returned %v, expected: %v", actual, expected)
+		}
+		m.AssertExpectations(t)
+		expected.AssertExpectations(t)
+	})
+}
+
+func TestMakeWithMacroExpandingToBuiltinTransform(t *testing.T) {
+	withMacros([]string{"(defmacro (greet $x) (aaa $x))"}, func() {
+		m := &MockWithMakeByName{}
+		expected := &MockHasSetArgs{}
+		m.On("makeByName", "say").Times(1).Return(expected, nil)
+		expected.On("SetArgs", "[world]").Times(1).Return(nil)
+		MakeFromString("(greet world)", []SexpTransform{Alias("aaa", "say")}, m.makeByName)
+		m.AssertExpectations(t)
+		expected.AssertExpectations(t)
+	})
+}
+
+type setArgsMakingChildren struct {
+	makeByName func(string) (interface{}, error)
+	transforms []SexpTransform
+}
+
+func (s *setArgsMakingChildren) SetArgs(args []interface{}) error {
+	for _, arg := range args {
+		if child, ok := arg.([]interface{}); ok {
+			if _, err := Make(child, s.transforms, s.makeByName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestMakeWithRecursiveMacros(t *testing.T) {
+	var makeByName func(string) (interface{}, error)
+	makeByName = func(string) (interface{}, error) { return &setArgsMakingChildren{makeByName, nil}, nil }
+
+	withMacros([]string{"(defmacro (forever $x) (forever $x))"}, func() {
+		_, err := MakeFromString("(forever x)", nil, makeByName)
+		util.ExpectError(t, "Maximum expansion depth 64 exceeded while expanding [forever x] in (forever x) at position 0", err)
+	})
+
+	withMacros([]string{"(defmacro (deeper $x) (node (deeper $x)))"}, func() {
+		_, err := MakeFromString("(deeper x)", nil, makeByName)
+		if util.ExitCode(err) != util.ExitCodeParse {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if depth != 0 {
+			t.Errorf("depth is %d after a failed Make, expected 0", depth)
+		}
+	})
+
+	withMacros([]string{"(defmacro (twice $x) (node $x $x))"}, func() {
+		// Recursion through arguments terminates
+		_, err := MakeFromString("(twice (twice (twice (leaf))))", nil, makeByName)
+		util.ExpectNoError(t, err)
+	})
+}
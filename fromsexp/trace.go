@@ -0,0 +1,118 @@
+package fromsexp
+
+/*
+TraceNode records how Make built a single component.
+*/
+type TraceNode struct {
+	Definition []interface{} // The definition as it was passed to Make
+	Expanded   []interface{} // The definition after applying all transforms
+	Transforms []string      // The names of the transforms (aliases, replacements, macros) applied, in order
+	Object     interface{}   // The component built by Make
+	Children   []*TraceNode  // The components built while setting the arguments of Object
+}
+
+// Name returns the name of the component after all transforms
+func (n *TraceNode) Name() string {
+	return string(n.Expanded[0].([]byte))
+}
+
+// Atoms returns the arguments of the component that are strings, as opposed to child definitions
+func (n *TraceNode) Atoms() []string {
+	atoms := []string{}
+	for _, arg := range n.Expanded[1:] {
+		if bytes, ok := arg.([]byte); ok {
+			atoms = append(atoms, string(bytes))
+		}
+	}
+	return atoms
+}
+
+/*
+ExpandedDefinition returns the definition of the component with all transforms applied, including in the definitions
+of its children, like (first-matching (knife) (separated-by ,)) for (first-matching (knife) (comma-separated))
+*/
+func (n *TraceNode) ExpandedDefinition() []interface{} {
+	return n.expandChildren(n.Expanded).([]interface{})
+}
+
+// expandChildren replaces the definitions of the children of n in data with their expanded definitions
+func (n *TraceNode) expandChildren(data interface{}) interface{} {
+	list, ok := data.([]interface{})
+	if !ok || len(list) == 0 {
+		return data
+	}
+	for _, child := range n.Children {
+		// Children are made from the very lists found in the definition of their parent
+		if len(child.Definition) == len(list) && &child.Definition[0] == &list[0] {
+			return child.ExpandedDefinition()
+		}
+	}
+	expanded := make([]interface{}, len(list))
+	for i, item := range list {
+		expanded[i] = n.expandChildren(item)
+	}
+	return expanded
+}
+
+type tracer struct {
+	roots   []*TraceNode
+	stack   []*TraceNode
+	parent  *tracer
+	lenient bool
+}
+
+var activeTracer *tracer
+
+/*
+Trace calls f, and returns a tree of the components made by Make while f was running. Traces can be nested: the
+components traced by an inner Trace are also part of the tree of the outer one.
+*/
+func Trace(f func()) []*TraceNode {
+	return trace(f, false)
+}
+
+/*
+TraceAllowingMissingBinaries is like Trace, but components requiring binaries that are not on PATH are made anyway,
+instead of failing. Useful when definitions are only inspected, not run.
+*/
+func TraceAllowingMissingBinaries(f func()) []*TraceNode {
+	return trace(f, true)
+}
+
+func trace(f func(), lenient bool) []*TraceNode {
+	previous := activeTracer
+	activeTracer = &tracer{parent: previous, lenient: lenient}
+	defer func() { activeTracer = previous }()
+	f()
+	return activeTracer.roots
+}
+
+// allowsMissingBinaries tells whether t, or a trace it's nested in, was started by TraceAllowingMissingBinaries
+func (t *tracer) allowsMissingBinaries() bool {
+	for ; t != nil; t = t.parent {
+		if t.lenient {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tracer) push(definition []interface{}) *TraceNode {
+	node := &TraceNode{Definition: definition}
+	if len(t.stack) == 0 {
+		t.roots = append(t.roots, node)
+		if t.parent != nil && len(t.parent.stack) > 0 {
+			parent := t.parent.stack[len(t.parent.stack)-1]
+			parent.Children = append(parent.Children, node)
+		}
+	} else {
+		parent := t.stack[len(t.stack)-1]
+		parent.Children = append(parent.Children, node)
+	}
+	t.stack = append(t.stack, node)
+	return node
+}
+
+func (t *tracer) pop() {
+	t.stack = t.stack[:len(t.stack)-1]
+}
@@ -0,0 +1,86 @@
+package fromsexp
+
+import (
+	"testing"
+
+	"github.com/abesto/easyssh/util"
+)
+
+func TestTrace(t *testing.T) {
+	transforms := []SexpTransform{Alias("aaa", "parent"), Replace("(bbb)", "(leaf x y)")}
+	var makeByName func(string) (interface{}, error)
+	makeByName = func(string) (interface{}, error) { return &setArgsMakingChildren{makeByName, transforms}, nil }
+
+	roots := Trace(func() {
+		MakeFromString("(aaa (bbb) (leaf z))", transforms, makeByName)
+		MakeFromString("(other)", transforms, makeByName)
+	})
+
+	if len(roots) != 2 {
+		t.Fatalf("Expected 2 roots, got %d", len(roots))
+	}
+	parent := roots[0]
+	if parent.Name() != "parent" || len(parent.Children) != 2 {
+		t.Errorf("Unexpected root node %v", parent)
+	}
+	util.AssertStringListEquals(t, []string{"aaa"}, parent.Transforms)
+	util.AssertStringListEquals(t, []string{}, parent.Atoms())
+	if _, ok := parent.Object.(*setArgsMakingChildren); !ok {
+		t.Errorf("Unexpected object %v", parent.Object)
+	}
+
+	first := parent.Children[0]
+	if first.Name() != "leaf" {
+		t.Errorf("Unexpected name %s", first.Name())
+	}
+	util.AssertStringListEquals(t, []string{"bbb"}, first.Transforms)
+	util.AssertStringListEquals(t, []string{"x", "y"}, first.Atoms())
+
+	second := parent.Children[1]
+	util.AssertStringListEquals(t, []string{}, second.Transforms)
+	util.AssertStringListEquals(t, []string{"z"}, second.Atoms())
+
+	if expanded := Format(parent.ExpandedDefinition()); expanded != "(parent (leaf x y) (leaf z))" {
+		t.Errorf("Unexpected expanded definition %s", expanded)
+	}
+
+	if roots[1].Name() != "other" || len(roots[1].Children) != 0 {
+		t.Errorf("Unexpected second root %v", roots[1])
+	}
+	if activeTracer != nil {
+		t.Error("Trace left a tracer active")
+	}
+}
+
+func TestNestedTrace(t *testing.T) {
+	transforms := []SexpTransform{Replace("(bbb)", "(leaf x y)")}
+	var makeByName func(string) (interface{}, error)
+	var inner []*TraceNode
+	makeByName = func(name string) (interface{}, error) {
+		if name != "wrapper" {
+			return &setArgsMakingChildren{makeByName, transforms}, nil
+		}
+		return &setArgsFunc{func(args []interface{}) error {
+			inner = Trace(func() { Make(args[0].([]interface{}), transforms, makeByName) })
+			return nil
+		}}, nil
+	}
+
+	roots := Trace(func() { MakeFromString("(wrapper (parent (bbb)))", transforms, makeByName) })
+
+	if len(inner) != 1 || Format(inner[0].ExpandedDefinition()) != "(parent (leaf x y))" {
+		t.Errorf("Unexpected inner trace %v", inner)
+	}
+	if len(roots) != 1 || len(roots[0].Children) != 1 || roots[0].Children[0] != inner[0] {
+		t.Errorf("Inner trace is not part of the outer one: %v", roots)
+	}
+}
+
+// setArgsFunc is a component that calls a function with its arguments
+type setArgsFunc struct {
+	f func([]interface{}) error
+}
+
+func (s *setArgsFunc) SetArgs(args []interface{}) error {
+	return s.f(args)
+}
@@ -3,27 +3,51 @@ package interfaces
 import (
 	"fmt"
 
+	"github.com/abesto/easyssh/plan"
 	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
 )
 
 type Discoverer interface {
 	HasSetArgs
 	fmt.Stringer
-	Discover(input string) []target.Target
+	Discover(input string) ([]target.Target, error)
 }
 
 type HasSetArgs interface {
-	SetArgs(args []interface{})
+	SetArgs(args []interface{}) error
+}
+
+// RequiresBinaries is implemented by components that run external commands
+type RequiresBinaries interface {
+	RequiredBinaries() []string
+}
+
+// HasChildren is implemented by components built from other components, like first-matching or if-command
+type HasChildren interface {
+	Children() []interface{}
+}
+
+// UsesCommandRunner is implemented by components that run external commands and capture their output
+type UsesCommandRunner interface {
+	SetCommandRunner(r util.CommandRunner)
+}
+
+// UsesInteractiveCommandRunner is implemented by components that run external commands attached to the terminal
+type UsesInteractiveCommandRunner interface {
+	SetInteractiveCommandRunner(r util.InteractiveCommandRunner)
 }
 
 type TargetFilter interface {
 	HasSetArgs
 	fmt.Stringer
-	Filter(targets []target.Target) []target.Target
+	Filter(targets []target.Target) ([]target.Target, error)
 }
 
 type Executor interface {
 	HasSetArgs
 	fmt.Stringer
-	Exec(targets []target.Target, command []string)
+	Exec(targets []target.Target, command []string) error
+	// Plan describes what Exec would do with the same arguments, without running anything
+	Plan(targets []target.Target, command []string) (plan.Plan, error)
 }
@@ -0,0 +1,184 @@
+/*
+Package pipeline wires a discoverer, a filter and an executor together the same way the easyssh binary does, so that
+other Go programs can reuse them without shelling out to easyssh.
+
+	p, err := pipeline.FromDefinitions("(knife)", "(ec2-instance-id us-east-1)", "(ssh-exec-parallel)")
+	if err != nil {
+		return err
+	}
+	targets, err := p.Run("roles:app", []string{"uptime"})
+*/
+package pipeline
+
+import (
+	"github.com/abesto/easyssh/discoverers"
+	"github.com/abesto/easyssh/executors"
+	"github.com/abesto/easyssh/filters"
+	"github.com/abesto/easyssh/fromsexp"
+	"github.com/abesto/easyssh/interfaces"
+	"github.com/abesto/easyssh/plan"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// The definitions used by FromDefinitions for empty arguments, and by the easyssh binary when no flag or profile sets them
+const (
+	DefaultDiscoverer = "(comma-separated)"
+	DefaultFilter     = "(id)"
+	DefaultExecutor   = "(ssh-login)"
+)
+
+/*
+Pipeline discovers targets, filters them, and runs a command on them.
+Filter may be nil, meaning no filtering. If User is set, it overrides the user of all discovered targets.
+*/
+type Pipeline struct {
+	Discoverer interfaces.Discoverer
+	Filter     interfaces.TargetFilter
+	Executor   interfaces.Executor
+	User       string
+}
+
+// New creates a Pipeline from already constructed components
+func New(discoverer interfaces.Discoverer, filter interfaces.TargetFilter, executor interfaces.Executor) *Pipeline {
+	return &Pipeline{Discoverer: discoverer, Filter: filter, Executor: executor}
+}
+
+/*
+FromDefinitions creates a Pipeline from discoverer, filter and executor definitions, as accepted by the -d, -f and -e
+flags of easyssh. Empty definitions are replaced with DefaultDiscoverer, DefaultFilter and DefaultExecutor.
+
+FromDefinitions is safe for concurrent use: building components isn't, so it holds fromsexp.Exclusive while doing so.
+The components it returns are not, each Pipeline must be used by one goroutine at a time.
+*/
+func FromDefinitions(discovererDefinition, filterDefinition, executorDefinition string) (p *Pipeline, err error) {
+	if discovererDefinition == "" {
+		discovererDefinition = DefaultDiscoverer
+	}
+	if filterDefinition == "" {
+		filterDefinition = DefaultFilter
+	}
+	if executorDefinition == "" {
+		executorDefinition = DefaultExecutor
+	}
+	fromsexp.Exclusive(func() {
+		p, err = fromDefinitions(discovererDefinition, filterDefinition, executorDefinition)
+	})
+	return
+}
+
+func fromDefinitions(discovererDefinition, filterDefinition, executorDefinition string) (*Pipeline, error) {
+	discoverer, err := discoverers.Make(discovererDefinition)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := filters.Make(filterDefinition)
+	if err != nil {
+		return nil, err
+	}
+	executor, err := executors.Make(executorDefinition)
+	if err != nil {
+		return nil, err
+	}
+	return New(discoverer, filter, executor), nil
+}
+
+/*
+SetCommandRunner makes all components of the pipeline that capture the output of external commands (like knife or
+aws) use r to run them.
+*/
+func (p *Pipeline) SetCommandRunner(r util.CommandRunner) {
+	p.walk(func(component interface{}) {
+		if c, ok := component.(interfaces.UsesCommandRunner); ok {
+			c.SetCommandRunner(r)
+		}
+	})
+}
+
+/*
+SetInteractiveCommandRunner makes all components of the pipeline that run external commands attached to the terminal
+(like ssh) use r to run them.
+*/
+func (p *Pipeline) SetInteractiveCommandRunner(r util.InteractiveCommandRunner) {
+	p.walk(func(component interface{}) {
+		if c, ok := component.(interfaces.UsesInteractiveCommandRunner); ok {
+			c.SetInteractiveCommandRunner(r)
+		}
+	})
+}
+
+func (p *Pipeline) walk(f func(interface{})) {
+	for _, component := range []interface{}{p.Discoverer, p.Filter, p.Executor} {
+		if component != nil {
+			walk(component, f)
+		}
+	}
+}
+
+func walk(component interface{}, f func(interface{})) {
+	f(component)
+	if parent, ok := component.(interfaces.HasChildren); ok {
+		for _, child := range parent.Children() {
+			walk(child, f)
+		}
+	}
+}
+
+/*
+Targets discovers the targets matching input, and filters them. Returns a NoTargetsError if nothing was discovered.
+*/
+func (p *Pipeline) Targets(input string) ([]target.Target, error) {
+	targets, err := p.Discoverer.Discover(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, &util.NoTargetsError{Input: input}
+	}
+	if p.User != "" {
+		for i := range targets {
+			targets[i].User = p.User
+		}
+	}
+	util.Logger.Debugf("Targets before filters: %s", targets)
+	if p.Filter != nil {
+		if targets, err = p.Filter.Filter(targets); err != nil {
+			return nil, err
+		}
+	}
+	util.Logger.Infof("Targets: %s", targets)
+	for _, t := range targets {
+		if len(t.Labels) > 0 {
+			util.Logger.Debugf("Labels of %s: %s", t.FriendlyName(), target.FormatLabels(t.Labels))
+		}
+	}
+	return targets, nil
+}
+
+/*
+Plan finds the targets matching input, and describes what the executor would do with them and command, without
+running anything.
+*/
+func (p *Pipeline) Plan(input string, command []string) (plan.Report, error) {
+	targets, err := p.Targets(input)
+	if err != nil {
+		return plan.Report{}, err
+	}
+	executorPlan, err := p.Executor.Plan(targets, command)
+	if err != nil {
+		return plan.Report{}, err
+	}
+	return plan.Report{Targets: targets, Command: command, Plan: executorPlan}, nil
+}
+
+/*
+Run finds the targets matching input, and runs command on them with the executor. The targets are returned even if
+the executor fails.
+*/
+func (p *Pipeline) Run(input string, command []string) ([]target.Target, error) {
+	targets, err := p.Targets(input)
+	if err != nil {
+		return nil, err
+	}
+	return targets, p.Executor.Exec(targets, command)
+}
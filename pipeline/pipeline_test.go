@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/abesto/easyssh/plan"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+func mustFromDefinitions(t *testing.T, discoverer, filter, executor string) *Pipeline {
+	p, err := FromDefinitions(discoverer, filter, executor)
+	if err != nil {
+		t.Fatalf("Expected no error, got \"%s\"", err)
+	}
+	return p
+}
+
+func TestFromDefinitionsDefaults(t *testing.T) {
+	p := mustFromDefinitions(t, "", "", "")
+	if p.Discoverer.String() != "<separated-by ,>" || p.Filter.String() != "<id>" ||
+		p.Executor.String() != "<assert-no-command <external-sequential-interactive [ssh]>>" {
+		t.Errorf("Unexpected components %s %s %s", p.Discoverer, p.Filter, p.Executor)
+	}
+}
+
+func TestFromDefinitionsErrors(t *testing.T) {
+	_, err := FromDefinitions("(comma-separatd)", "", "")
+	util.ExpectError(t, "Discoverer \"comma-separatd\" is not known in (comma-separatd) at position 0; did you mean comma-separated?", err)
+	_, err = FromDefinitions("", "(frist)", "")
+	util.ExpectError(t, "filter \"frist\" is not known in (frist) at position 0; did you mean first?", err)
+	_, err = FromDefinitions("", "", "(ssh-logn)")
+	util.ExpectError(t, "Executor \"ssh-logn\" is not known in (ssh-logn) at position 0; did you mean ssh-login?", err)
+}
+
+func TestFromDefinitionsConcurrently(t *testing.T) {
+	errs := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func() {
+			_, err := FromDefinitions("(first-matching (const a) (union (comma-separated) (const b)))", "(list (id) (first))", "(if-one-target (ssh-login) (ssh-exec))")
+			errs <- err
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		util.ExpectNoError(t, <-errs)
+	}
+}
+
+func TestTargets(t *testing.T) {
+	p := mustFromDefinitions(t, "(comma-separated)", "(first)", "")
+	p.User = "root"
+	targets, err := p.Targets("foo,bar")
+	util.ExpectNoError(t, err)
+	target.AssertTargetListEquals(t, target.MustFromStrings("root@foo"), targets)
+
+	p.Filter = nil
+	targets, err = p.Targets("foo,bar")
+	util.ExpectNoError(t, err)
+	target.AssertTargetListEquals(t, target.MustFromStrings("root@foo", "root@bar"), targets)
+
+	_, err = p.Targets("")
+	util.ExpectError(t, "No targets found for ", err)
+	if util.ExitCode(err) != util.ExitCodeNoTargets {
+		t.Errorf("Unexpected exit code %d", util.ExitCode(err))
+	}
+}
+
+func TestRunWithInjectedInteractiveCommandRunner(t *testing.T) {
+	p := mustFromDefinitions(t, "(comma-separated)", "", "(if-command (ssh-exec-parallel) (ssh-login))")
+	r := &util.MockInteractiveCommandRunner{}
+	p.SetInteractiveCommandRunner(r)
+	r.On("RunParallel", []util.InteractiveCommandRunnerJob{
+		{Label: "foo", Argv: []string{"ssh", "foo", "uptime"}},
+		{Label: "bar", Argv: []string{"ssh", "bar", "uptime"}},
+	}).Return(nil).Times(1)
+
+	targets, err := p.Run("foo,bar", []string{"uptime"})
+	util.ExpectNoError(t, err)
+	target.AssertTargetListEquals(t, target.MustFromStrings("foo", "bar"), targets)
+	r.AssertExpectations(t)
+}
+
+func TestRunWithInjectedCommandRunner(t *testing.T) {
+	p := mustFromDefinitions(t, "(first-matching (knife) (comma-separated))", "(list (id) (ec2-instance-id us-east-1))", "")
+	r := &util.MockCommandRunner{}
+	p.SetCommandRunner(r)
+	r.On("Outputs", "knife", []string{"search", "node", "-F", "json", "roles:app"}).Return(util.CommandRunnerOutputs{
+		Stdout: []byte(`{"rows": [{"name": "app1", "automatic": {"fqdn": "app1.example.com", "ipaddress": "10.0.0.1"}}]}`),
+	}).Times(1)
+
+	report, err := p.Plan("roles:app", []string{})
+	util.ExpectNoError(t, err)
+	target.AssertTargetListEquals(t, []target.Target{{Host: "app1.example.com", IP: "10.0.0.1", Labels: map[string]string{"chef.node": "app1"}}}, report.Targets)
+	if report.Plan.Executor != "assert-no-command" || len(report.Plan.Children) != 1 || report.Plan.Children[0].Mode != plan.ModeSequential {
+		t.Errorf("Unexpected plan %v", report.Plan)
+	}
+	r.AssertExpectations(t)
+}
+
+func TestRunReturnsExecutorErrors(t *testing.T) {
+	p := mustFromDefinitions(t, "(comma-separated)", "", "(ssh-login)")
+	targets, err := p.Run("foo", []string{"uptime"})
+	util.ExpectError(t, "<assert-no-command <external-sequential-interactive [ssh]>> doesn't accept a command, got: [uptime]", err)
+	target.AssertTargetListEquals(t, target.MustFromStrings("foo"), targets)
+}
+
+func TestFilterWithInjectedCommandRunner(t *testing.T) {
+	p := mustFromDefinitions(t, "(comma-separated)", "(ec2-instance-id us-east-1)", "")
+	r := &util.MockCommandRunner{}
+	p.SetCommandRunner(r)
+	r.On("Outputs", "aws", []string{"ec2", "describe-instances", "--region", "us-east-1", "--instance-ids", "i-0123456789abcdef0"}).Return(util.CommandRunnerOutputs{
+		Combined: []byte(`{"Reservations": [{"Instances": [{"InstanceId": "i-0123456789abcdef0", "PublicIpAddress": "1.2.3.4", "PublicDnsName": "web.example.com"}]}]}`),
+	}).Times(1)
+
+	targets, err := p.Targets("i-0123456789abcdef0")
+	util.ExpectNoError(t, err)
+	if len(targets) != 1 || targets[0].IP != "1.2.3.4" || targets[0].Host != "web.example.com" {
+		t.Errorf("Unexpected targets %v", targets)
+	}
+	r.AssertExpectations(t)
+}
@@ -0,0 +1,89 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// How the jobs of a Plan are run
+const (
+	ModeSingle     = "single"
+	ModeSequential = "sequential"
+	ModeParallel   = "parallel"
+)
+
+/*
+Plan describes what an executor would do if it was run, without running anything.
+Combinators describe the decision they made in Decision, and include the plan of the executor they picked in Children.
+Executors that run external commands describe how the commands are run in Mode, and the commands themselves in Jobs.
+*/
+type Plan struct {
+	Executor string                             `json:"executor"`
+	Decision string                             `json:"decision,omitempty"`
+	Mode     string                             `json:"mode,omitempty"`
+	Jobs     []util.InteractiveCommandRunnerJob `json:"jobs,omitempty"`
+	Children []Plan                             `json:"children,omitempty"`
+}
+
+/*
+Report is the full description of a dry run: the targets after filtering, the command, and the plan of the executor.
+*/
+type Report struct {
+	Targets []target.Target `json:"targets"`
+	Command []string        `json:"command"`
+	Plan    Plan            `json:"plan"`
+}
+
+// WriteJSON writes the report as indented JSON
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes the report in a human-readable format
+func (r Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Targets: %s\n", strings.Join(target.FriendlyNames(r.Targets), " "))
+	fmt.Fprintf(w, "Command: %s\n", ShellQuote(r.Command))
+	r.Plan.writeText(w, 0)
+}
+
+func (p Plan) writeText(w io.Writer, level int) {
+	indent := strings.Repeat("  ", level)
+	line := indent + p.Executor
+	if p.Mode != "" {
+		line += " (" + p.Mode + ")"
+	}
+	if p.Decision != "" {
+		line += ": " + p.Decision
+	}
+	fmt.Fprintln(w, line)
+	for _, job := range p.Jobs {
+		fmt.Fprintf(w, "%s  [%s] %s\n", indent, job.Label, ShellQuote(job.Argv))
+	}
+	for _, child := range p.Children {
+		child.writeText(w, level+1)
+	}
+}
+
+var shellSafe = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
+// ShellQuote formats argv so that it can be pasted into a POSIX shell
+func ShellQuote(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if shellSafe.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,116 @@
+package plan
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+func givenAReport() Report {
+	return Report{
+		Targets: target.MustFromStrings("root@foo", "bar"),
+		Command: []string{"echo", "it's <done>"},
+		Plan: Plan{
+			Executor: "if-command",
+			Decision: "got command",
+			Children: []Plan{{
+				Executor: "external-parallel",
+				Mode:     ModeParallel,
+				Jobs: []util.InteractiveCommandRunnerJob{
+					{Label: "root@foo", Argv: []string{"ssh", "root@foo", "echo", "it's <done>"}},
+					{Label: "bar", Argv: []string{"ssh", "bar", "echo", "it's <done>"}},
+				},
+			}},
+		},
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var buffer bytes.Buffer
+	givenAReport().WriteText(&buffer)
+	expected := `Targets: root@foo bar
+Command: echo 'it'\''s <done>'
+if-command: got command
+  external-parallel (parallel)
+    [root@foo] ssh root@foo echo 'it'\''s <done>'
+    [bar] ssh bar echo 'it'\''s <done>'
+`
+	if buffer.String() != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, buffer.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := givenAReport().WriteJSON(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "targets": [
+    {
+      "host": "foo",
+      "user": "root"
+    },
+    {
+      "host": "bar"
+    }
+  ],
+  "command": [
+    "echo",
+    "it's <done>"
+  ],
+  "plan": {
+    "executor": "if-command",
+    "decision": "got command",
+    "children": [
+      {
+        "executor": "external-parallel",
+        "mode": "parallel",
+        "jobs": [
+          {
+            "interactive": false,
+            "label": "root@foo",
+            "argv": [
+              "ssh",
+              "root@foo",
+              "echo",
+              "it's <done>"
+            ]
+          },
+          {
+            "interactive": false,
+            "label": "bar",
+            "argv": [
+              "ssh",
+              "bar",
+              "echo",
+              "it's <done>"
+            ]
+          }
+        ]
+      }
+    ]
+  }
+}
+`
+	if buffer.String() != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, buffer.String())
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	cases := map[string][]string{
+		"":                        {},
+		"ssh -p 22 root@host":     {"ssh", "-p", "22", "root@host"},
+		"echo 'a b' '$HOME' ''":   {"echo", "a b", "$HOME", ""},
+		`echo 'it'\''s'`:          {"echo", "it's"},
+		"ls /var/log/syslog,info": {"ls", "/var/log/syslog,info"},
+	}
+	for expected, argv := range cases {
+		if actual := ShellQuote(argv); actual != expected {
+			t.Errorf("ShellQuote(%q) = %s, expected %s", argv, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/abesto/easyssh/plan"
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// component holds what discoverer, filter and executor plugins have in common
+type component struct {
+	kind          string
+	name          string
+	path          string
+	args          []interface{}
+	commandRunner util.CommandRunner
+}
+
+func (c *component) SetArgs(args []interface{}) error {
+	c.args = args
+	return nil
+}
+
+func (c *component) SetCommandRunner(r util.CommandRunner) {
+	c.commandRunner = r
+}
+
+func (c *component) RequiredBinaries() []string {
+	return []string{filepath.Base(c.path)}
+}
+
+func (c *component) String() string {
+	return fmt.Sprintf("<%s %s>", c.name, c.args)
+}
+
+func (c *component) call(request Request) (Response, error) {
+	request.Kind = c.kind
+	request.Name = c.name
+	request.Args = jsonArgs(c.args)
+	return call(c.commandRunner, c.path, request)
+}
+
+// Discoverer is a discoverer implemented by a plugin executable
+type Discoverer struct {
+	component
+}
+
+// NewDiscoverer creates a discoverer backed by the plugin executable at path
+func NewDiscoverer(name, path string) *Discoverer {
+	return &Discoverer{component{KindDiscoverer, name, path, nil, util.RealCommandRunner{}}}
+}
+
+func (d *Discoverer) Discover(input string) ([]target.Target, error) {
+	response, err := d.call(Request{Input: input})
+	if err != nil {
+		return nil, err
+	}
+	return response.Targets, nil
+}
+
+// Filter is a filter implemented by a plugin executable
+type Filter struct {
+	component
+}
+
+// NewFilter creates a filter backed by the plugin executable at path
+func NewFilter(name, path string) *Filter {
+	return &Filter{component{KindFilter, name, path, nil, util.RealCommandRunner{}}}
+}
+
+func (f *Filter) Filter(targets []target.Target) ([]target.Target, error) {
+	response, err := f.call(Request{Targets: targets})
+	if err != nil {
+		return nil, err
+	}
+	return response.Targets, nil
+}
+
+/*
+Executor is an executor implemented by a plugin executable.
+The standard input and output of the plugin are used by the protocol, so it can't run interactive commands; the output
+of each target is logged once the plugin exits.
+*/
+type Executor struct {
+	component
+}
+
+// NewExecutor creates an executor backed by the plugin executable at path
+func NewExecutor(name, path string) *Executor {
+	return &Executor{component{KindExecutor, name, path, nil, util.RealCommandRunner{}}}
+}
+
+func (e *Executor) Exec(targets []target.Target, command []string) error {
+	response, err := e.call(Request{Targets: targets, Command: command})
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, result := range response.Results {
+		label := e.name
+		if !result.Target.IsEmpty() {
+			label = result.Target.FriendlyName()
+		}
+		if output := strings.TrimSpace(result.Output); output != "" {
+			for _, line := range strings.Split(output, "\n") {
+				util.Logger.Noticef("[%s] %s", label, line)
+			}
+		}
+		if result.ExitCode == 0 && result.Error == "" {
+			continue
+		}
+		msg := result.Error
+		if msg == "" {
+			msg = fmt.Sprintf("exit status %d", result.ExitCode)
+		}
+		util.Logger.Errorf("[%s] %s", label, msg)
+		if firstErr == nil {
+			firstErr = &util.CommandError{Argv: append([]string{e.path, label}, command...), Err: fmt.Errorf("%s", msg)}
+		}
+	}
+	return firstErr
+}
+
+func (e *Executor) Plan(targets []target.Target, command []string) (plan.Plan, error) {
+	return plan.Plan{
+		Executor: e.name,
+		Decision: fmt.Sprintf("plugin %s receives %d target(s)", e.path, len(targets)),
+		Mode:     plan.ModeSingle,
+		Jobs:     []util.InteractiveCommandRunnerJob{{Label: e.name, Argv: []string{e.path}}},
+	}, nil
+}
@@ -0,0 +1,168 @@
+/*
+Package plugin implements the protocol easyssh uses to talk to discoverers, filters and executors that live in separate
+executables. An executable named easyssh-<kind>-<name> on the PATH, where kind is discoverer, filter or executor, is
+available as (<name> ...) wherever a component of that kind is expected, unless a built-in or registered component
+already uses that name.
+
+The executable is run without arguments once per Discover, Filter or Exec call. It receives a single Request as JSON on
+its standard input, and must write a single Response as JSON to its standard output, then exit with status 0. Anything
+written to standard error is logged. A plugin that exits with a non-zero status, or sets Response.Error, fails the
+component. Requests and responses carry the protocol version; easyssh refuses responses with a different version.
+*/
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// ProtocolVersion is the version of the protocol described in this package, sent in every Request
+const ProtocolVersion = 1
+
+// The kinds of components a plugin can implement
+const (
+	KindDiscoverer = "discoverer"
+	KindFilter     = "filter"
+	KindExecutor   = "executor"
+)
+
+/*
+Request is sent to the plugin on its standard input.
+Args holds the s-expression arguments of the component: atoms as strings, lists as arrays.
+Discoverers get Input, filters get Targets, executors get Targets and Command.
+*/
+type Request struct {
+	Version int             `json:"version"`
+	Kind    string          `json:"kind"`
+	Name    string          `json:"name"`
+	Args    []interface{}   `json:"args"`
+	Input   string          `json:"input,omitempty"`
+	Targets []target.Target `json:"targets,omitempty"`
+	Command []string        `json:"command,omitempty"`
+}
+
+/*
+Response is read from the standard output of the plugin.
+Discoverers and filters return Targets, executors return one Result per target in Results.
+*/
+type Response struct {
+	Version int             `json:"version"`
+	Error   string          `json:"error,omitempty"`
+	Targets []target.Target `json:"targets,omitempty"`
+	Results []Result        `json:"results,omitempty"`
+}
+
+// Result describes what an executor plugin did on a single target
+type Result struct {
+	Target   target.Target `json:"target"`
+	ExitCode int           `json:"exit_code"`
+	Output   string        `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ExecutableName returns the name of the executable implementing the plugin of the given kind and name
+func ExecutableName(kind, name string) string {
+	return fmt.Sprintf("easyssh-%s-%s", kind, name)
+}
+
+// Lookup returns the path of the executable implementing the plugin of the given kind and name, if it's on the PATH
+func Lookup(kind, name string) (string, bool) {
+	if !validName.MatchString(name) {
+		return "", false
+	}
+	path, err := util.LookPath(ExecutableName(kind, name))
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// Names returns the names of all plugins of the given kind on the PATH
+func Names(kind string) []string {
+	prefix := ExecutableName(kind, "")
+	seen := map[string]bool{}
+	names := []string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		matches, _ := filepath.Glob(filepath.Join(dir, prefix+"*"))
+		for _, match := range matches {
+			name := strings.TrimPrefix(filepath.Base(match), prefix)
+			if info, err := os.Stat(match); err != nil || info.IsDir() || info.Mode()&0111 == 0 || seen[name] {
+				continue
+			}
+			if validName.MatchString(name) {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// jsonArgs converts s-expression arguments into values that encode to JSON strings and arrays
+func jsonArgs(args []interface{}) []interface{} {
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch typed := arg.(type) {
+		case []byte:
+			converted[i] = string(typed)
+		case []interface{}:
+			converted[i] = jsonArgs(typed)
+		default:
+			converted[i] = typed
+		}
+	}
+	return converted
+}
+
+// call sends request to the plugin at path, and parses its response
+func call(r util.CommandRunner, path string, request Request) (Response, error) {
+	var response Response
+	request.Version = ProtocolVersion
+	input, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+	outputs := r.OutputsWithStdin(bytes.NewReader(input), path, []string{})
+	if len(outputs.Stderr) > 0 {
+		util.Logger.Debugf("Plugin %s wrote to stderr: %s", path, outputs.Stderr)
+	}
+	if outputs.Error != nil {
+		return response, &util.CommandError{Argv: []string{path}, Err: outputs.Error, Output: outputs.Stderr}
+	}
+	if err := json.Unmarshal(outputs.Stdout, &response); err != nil {
+		return response, &util.InvalidOutputError{Source: path, Err: err, Output: outputs.Stdout}
+	}
+	if response.Version != ProtocolVersion {
+		return response, &util.InvalidOutputError{
+			Source: path,
+			Err:    fmt.Errorf("unsupported protocol version %d, expected %d", response.Version, ProtocolVersion),
+			Output: outputs.Stdout,
+		}
+	}
+	if response.Error != "" {
+		return response, &util.CommandError{Argv: []string{path}, Err: errors.New(response.Error)}
+	}
+	for _, t := range response.Targets {
+		if t.IsEmpty() {
+			return response, &util.InvalidOutputError{
+				Source: path,
+				Err:    errors.New("at least one of Target.IP and Target.Host must be set"),
+				Output: outputs.Stdout,
+			}
+		}
+	}
+	return response, nil
+}
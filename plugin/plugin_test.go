@@ -0,0 +1,167 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/abesto/easyssh/target"
+	"github.com/abesto/easyssh/util"
+)
+
+// requestMatching matches the stdin of a plugin call if it decodes to expected
+func requestMatching(t *testing.T, expected Request) interface{} {
+	return mock.MatchedBy(func(stdin io.Reader) bool {
+		var actual Request
+		if seeker, ok := stdin.(io.Seeker); ok {
+			// The mock may match the same call more than once
+			seeker.Seek(0, io.SeekStart)
+		}
+		bytes, err := ioutil.ReadAll(stdin)
+		if err != nil || json.Unmarshal(bytes, &actual) != nil {
+			return false
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Logf("Unexpected request %s", bytes)
+			return false
+		}
+		return true
+	})
+}
+
+func TestDiscover(t *testing.T) {
+	r := &util.MockCommandRunner{}
+	d := NewDiscoverer("foo", "/bin/easyssh-discoverer-foo")
+	d.SetCommandRunner(r)
+	d.SetArgs([]interface{}{[]byte("a"), []interface{}{[]byte("b"), []byte("c")}})
+	r.On("OutputsWithStdin", requestMatching(t, Request{
+		Version: ProtocolVersion, Kind: KindDiscoverer, Name: "foo",
+		Args:  []interface{}{"a", []interface{}{"b", "c"}},
+		Input: "in",
+	}), "/bin/easyssh-discoverer-foo", []string{}).Return(util.CommandRunnerOutputs{
+		Stdout: []byte(`{"version": 1, "targets": [{"host": "h", "hostname": "h.example.com", "ip": "10.0.0.1", "user": "u", "coalesce_order": ["hostname"]}]}`),
+	})
+	targets, err := d.Discover("in")
+	util.ExpectNoError(t, err)
+	target.AssertTargetListEquals(t, []target.Target{
+		{Host: "h", Hostname: "h.example.com", IP: "10.0.0.1", User: "u", CoalesceOrder: []string{"hostname"}},
+	}, targets)
+	if d.String() != "<foo [a [b c]]>" {
+		t.Errorf("Unexpected string %s", d)
+	}
+	r.AssertExpectations(t)
+}
+
+func TestFilter(t *testing.T) {
+	r := &util.MockCommandRunner{}
+	f := NewFilter("bar", "easyssh-filter-bar")
+	f.SetCommandRunner(r)
+	f.SetArgs([]interface{}{})
+	r.On("OutputsWithStdin", requestMatching(t, Request{
+		Version: ProtocolVersion, Kind: KindFilter, Name: "bar", Args: []interface{}{},
+		Targets: []target.Target{{Host: "a", IP: "10.0.0.1"}, {Host: "b"}},
+	}), "easyssh-filter-bar", []string{}).Return(util.CommandRunnerOutputs{
+		Stdout: []byte(`{"version": 1, "targets": [{"host": "b"}]}`),
+	})
+	targets, err := f.Filter([]target.Target{{Host: "a", IP: "10.0.0.1"}, {Host: "b"}})
+	util.ExpectNoError(t, err)
+	target.AssertTargetListEquals(t, target.MustFromStrings("b"), targets)
+	r.AssertExpectations(t)
+}
+
+func TestExec(t *testing.T) {
+	util.WithLogAssertions(t, func(l *util.MockLogger) {
+		r := &util.MockCommandRunner{}
+		e := NewExecutor("baz", "easyssh-executor-baz")
+		e.SetCommandRunner(r)
+		r.On("OutputsWithStdin", requestMatching(t, Request{
+			Version: ProtocolVersion, Kind: KindExecutor, Name: "baz", Args: []interface{}{},
+			Targets: target.MustFromStrings("a", "b"), Command: []string{"uptime"},
+		}), "easyssh-executor-baz", []string{}).Return(util.CommandRunnerOutputs{
+			Stdout: []byte(`{"version": 1, "results": [
+				{"target": {"host": "a"}, "exit_code": 0, "output": "up 1 day\n"},
+				{"target": {"host": "b"}, "exit_code": 3, "output": "oops"}]}`),
+		})
+		l.On("Noticef", "[%s] %s", "a", "up 1 day").Return()
+		l.On("Noticef", "[%s] %s", "b", "oops").Return()
+		l.ExpectErrorf("[%s] %s", "b", "exit status 3")
+		err := e.Exec(target.MustFromStrings("a", "b"), []string{"uptime"})
+		util.ExpectError(t, "[easyssh-executor-baz b uptime] failed: exit status 3", err)
+		r.AssertExpectations(t)
+	})
+}
+
+func TestCallErrors(t *testing.T) {
+	cases := []struct {
+		outputs util.CommandRunnerOutputs
+		err     string
+	}{
+		{util.CommandRunnerOutputs{Error: errors.New("exit status 1"), Stderr: []byte("boom")},
+			"[p] failed: exit status 1\nOutput:\nboom"},
+		{util.CommandRunnerOutputs{Stdout: []byte("nope")},
+			"Failed to parse output of p: invalid character 'o' in literal null (expecting 'u')"},
+		{util.CommandRunnerOutputs{Stdout: []byte(`{"version": 2}`)},
+			"Failed to parse output of p: unsupported protocol version 2, expected 1"},
+		{util.CommandRunnerOutputs{Stdout: []byte(`{"version": 1, "error": "no such role"}`)},
+			"[p] failed: no such role"},
+		{util.CommandRunnerOutputs{Stdout: []byte(`{"version": 1, "targets": [{"user": "u"}]}`)},
+			"Failed to parse output of p: at least one of Target.IP and Target.Host must be set"},
+	}
+	for _, c := range cases {
+		r := &util.MockCommandRunner{}
+		d := NewDiscoverer("d", "p")
+		d.SetCommandRunner(r)
+		r.On("OutputsWithStdin", mock.Anything, "p", []string{}).Return(c.outputs)
+		_, err := d.Discover("")
+		util.ExpectError(t, c.err, err)
+	}
+}
+
+func withPluginOnPath(t *testing.T, name, script string, f func(dir string)) {
+	dir, err := ioutil.TempDir("", "easyssh-plugin-test")
+	util.ExpectNoError(t, err)
+	defer os.RemoveAll(dir)
+	util.ExpectNoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755))
+	oldPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", oldPath)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+	f(dir)
+}
+
+func TestLookupAndNames(t *testing.T) {
+	withPluginOnPath(t, "easyssh-filter-qux", "#!/bin/sh\n", func(dir string) {
+		path, ok := Lookup(KindFilter, "qux")
+		if !ok || path != filepath.Join(dir, "easyssh-filter-qux") {
+			t.Errorf("Unexpected lookup result %s %v", path, ok)
+		}
+		if _, ok := Lookup(KindDiscoverer, "qux"); ok {
+			t.Error("Found a filter plugin as a discoverer")
+		}
+		if _, ok := Lookup(KindFilter, "../qux"); ok {
+			t.Error("Found a plugin with an invalid name")
+		}
+		if names := Names(KindFilter); !reflect.DeepEqual(names, []string{"qux"}) {
+			t.Errorf("Unexpected names %s", names)
+		}
+	})
+}
+
+func TestRealPluginExecutable(t *testing.T) {
+	script := "#!/bin/sh\ngrep -q '\"input\":\"x\"' && echo '{\"version\": 1, \"targets\": [{\"host\": \"from-plugin\"}]}'\n"
+	withPluginOnPath(t, "easyssh-discoverer-real", script, func(dir string) {
+		path, ok := Lookup(KindDiscoverer, "real")
+		if !ok {
+			t.Fatal("Plugin not found")
+		}
+		targets, err := NewDiscoverer("real", path).Discover("x")
+		util.ExpectNoError(t, err)
+		target.AssertTargetListEquals(t, target.MustFromStrings("from-plugin"), targets)
+	})
+}
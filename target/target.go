@@ -1,7 +1,10 @@
 package target
 
 import (
+	"fmt"
 	"net"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/abesto/easyssh/util"
@@ -11,11 +14,37 @@ import (
 Target describes a single machine that easyssh will operate on.
 */
 type Target struct {
-	Host          string // Used to reference the host from the outside, generally an Host
-	Hostname      string // What the host calls itself
-	IP            string
-	User          string
-	CoalesceOrder []string
+	Host          string   `json:"host,omitempty"`     // Used to reference the host from the outside, generally an Host
+	Hostname      string   `json:"hostname,omitempty"` // What the host calls itself
+	IP            string   `json:"ip,omitempty"`
+	User          string   `json:"user,omitempty"`
+	Port          int      `json:"port,omitempty"`       // Zero means the default port of the tool connecting to the target
+	ProxyJump     []string `json:"proxy_jump,omitempty"` // Jump hosts to connect through, in order, like ssh -J
+	CoalesceOrder []string `json:"coalesce_order,omitempty"`
+	// Metadata learned by discoverers and filters, like Chef roles or EC2 tags. Keys are prefixed with their source,
+	// like chef.roles or ec2.tag.Name, except for ones any source may set, like region and zone.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// SetLabel sets a label on the target, creating the label map if needed. Empty values are not stored.
+func (t *Target) SetLabel(key, value string) {
+	if value == "" {
+		return
+	}
+	if t.Labels == nil {
+		t.Labels = map[string]string{}
+	}
+	t.Labels[key] = value
+}
+
+// FormatLabels formats labels as space-separated key=value pairs, sorted by key
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
 }
 
 func (t Target) withUser(s string) string {
@@ -62,10 +91,50 @@ func (t Target) SSHTarget() string {
 
 /*
 FriendlyName returns the most descriptive name available for the target.
-Specifically, the first non-empty value of Hostname, Host, IP
+Specifically, the first non-empty value of Hostname, Host, IP, followed by the port if it's set
 */
 func (t Target) FriendlyName() string {
-	return t.firstNonEmptyStringWithUser(t.Hostname, t.Host, t.IP)
+	t.verify()
+	return t.withUser(t.withPort(firstNonEmptyString(t.Hostname, t.Host, t.IP)))
+}
+
+// bracketed wraps IPv6 addresses in square brackets, as required when they're followed by a port or a path
+func bracketed(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+func (t Target) withPort(host string) string {
+	if t.Port == 0 {
+		return host
+	}
+	return fmt.Sprintf("%s:%d", bracketed(host), t.Port)
+}
+
+/*
+SCPTarget is SSHTarget with IPv6 addresses wrapped in square brackets, as scp, sftp and csshx expect them.
+It doesn't include the port.
+*/
+func (t Target) SCPTarget() string {
+	stripped := t
+	stripped.User = ""
+	return t.withUser(bracketed(stripped.SSHTarget()))
+}
+
+/*
+SSHURI returns the target as an ssh://[user@]host[:port] URI, understood by ssh since OpenSSH 7.7.
+The host is chosen the same way as in SSHTarget.
+*/
+func (t Target) SSHURI() string {
+	stripped := t
+	stripped.User = ""
+	host := bracketed(stripped.SSHTarget())
+	if t.Port != 0 {
+		host += ":" + strconv.Itoa(t.Port)
+	}
+	return "ssh://" + t.withUser(host)
 }
 
 func (t Target) String() string {
@@ -105,15 +174,20 @@ func FriendlyNames(ts []Target) []string {
 }
 
 /*
-FromString creates a Target from a string description of the form [user@]<ip|fqdn>
+FromString creates a Target from a string description of the form [ssh://][user@]<ip|fqdn>[:port].
+IPv6 addresses followed by a port must be wrapped in square brackets, like [fe80::1]:2222.
 */
-func FromString(str string) Target {
+func FromString(str string) (Target, error) {
+	var target Target
 	if len(str) == 0 {
-		util.Panicf("FromString(str string) Target got an empty string")
+		return target, &util.InvalidTargetError{Input: str, Msg: "empty string"}
+	}
+	withoutScheme := str
+	if strings.HasPrefix(str, "ssh://") {
+		withoutScheme = strings.TrimSuffix(strings.TrimPrefix(str, "ssh://"), "/")
 	}
-	var parts = strings.Split(str, "@")
+	var parts = strings.Split(withoutScheme, "@")
 	var hostDef string
-	var target Target
 
 	if len(parts) == 1 {
 		hostDef = parts[0]
@@ -121,26 +195,71 @@ func FromString(str string) Target {
 		target.User = parts[0]
 		hostDef = parts[1]
 	} else {
-		util.Panicf("FromString(str string) Target got a string containing more than one @ character")
+		return target, &util.InvalidTargetError{Input: str, Msg: "more than one @ character"}
+	}
+
+	hostDef, port, err := splitHostPort(hostDef)
+	if err != nil {
+		return target, &util.InvalidTargetError{Input: str, Msg: err.Error()}
 	}
+	target.Port = port
 
 	if net.ParseIP(hostDef) != nil {
 		target.IP = hostDef
 	} else {
 		target.Host = hostDef
 	}
-	target.verify()
+	if target.IsEmpty() {
+		return target, &util.InvalidTargetError{Input: str, Msg: "at least one of Target.IP and Target.Host must be set"}
+	}
+
+	return target, nil
+}
 
-	return target
+/*
+splitHostPort splits host:port and [host]:port. The port is optional, and a host with more than one colon without
+brackets is taken to be an IPv6 address without a port.
+*/
+func splitHostPort(hostDef string) (string, int, error) {
+	var host, port string
+	if strings.HasPrefix(hostDef, "[") {
+		end := strings.Index(hostDef, "]")
+		if end == -1 {
+			return "", 0, fmt.Errorf("missing ] after IPv6 address")
+		}
+		host = hostDef[1:end]
+		rest := hostDef[end+1:]
+		if rest == "" {
+			return host, 0, nil
+		}
+		if !strings.HasPrefix(rest, ":") {
+			return "", 0, fmt.Errorf("expected :port after ], got %s", rest)
+		}
+		port = rest[1:]
+	} else if strings.Count(hostDef, ":") == 1 {
+		parts := strings.SplitN(hostDef, ":", 2)
+		host, port = parts[0], parts[1]
+	} else {
+		return hostDef, 0, nil
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 1 || portNumber > 65535 {
+		return "", 0, fmt.Errorf("invalid port \"%s\"", port)
+	}
+	return host, portNumber, nil
 }
 
 /*
 FromStrings maps FromString over...string
 */
-func FromStrings(targetStrings ...string) []Target {
+func FromStrings(targetStrings ...string) ([]Target, error) {
 	targets := make([]Target, len(targetStrings))
 	for i := 0; i < len(targetStrings); i++ {
-		targets[i] = FromString(targetStrings[i])
+		target, err := FromString(targetStrings[i])
+		if err != nil {
+			return nil, err
+		}
+		targets[i] = target
 	}
-	return targets
+	return targets, nil
 }
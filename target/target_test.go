@@ -38,6 +38,8 @@ func TestFriendlyName(t *testing.T) {
 		{Target{Host: "host-4.test", Hostname: "host-4", IP: "4.4.4.4", User: "user-4"}, "user-4@host-4"},
 		{Target{Host: "host-5.test", IP: "5.5.5.5", User: "user-5"}, "user-5@host-5.test"},
 		{Target{IP: "6.6.6.6", User: "user-6"}, "user-6@6.6.6.6"},
+		{Target{Host: "host-7.test", User: "user-7", Port: 2222}, "user-7@host-7.test:2222"},
+		{Target{IP: "::8", Port: 2222}, "[::8]:2222"},
 	}
 	for _, item := range cases {
 		actual := item.target.FriendlyName()
@@ -48,6 +50,27 @@ func TestFriendlyName(t *testing.T) {
 	util.ExpectPanic(t, "At least one of Target.IP and Target.Host must be set", func() { _ = Target{User: "user-3"}.FriendlyName() })
 }
 
+func TestSSHURIAndSCPTarget(t *testing.T) {
+	cases := []struct {
+		target Target
+		uri    string
+		scp    string
+	}{
+		{Target{Host: "host-1.test"}, "ssh://host-1.test", "host-1.test"},
+		{Target{Host: "host-2.test", User: "user-2", Port: 2222}, "ssh://user-2@host-2.test:2222", "user-2@host-2.test"},
+		{Target{Host: "host-3.test", IP: "::3", User: "user-3", Port: 22}, "ssh://user-3@[::3]:22", "user-3@[::3]"},
+		{Target{IP: "::4"}, "ssh://[::4]", "[::4]"},
+	}
+	for _, item := range cases {
+		if actual := item.target.SSHURI(); actual != item.uri {
+			t.Errorf("Expected: %s. Actual: %s.", item.uri, actual)
+		}
+		if actual := item.target.SCPTarget(); actual != item.scp {
+			t.Errorf("Expected: %s. Actual: %s.", item.scp, actual)
+		}
+	}
+}
+
 func TestFromString(t *testing.T) {
 	happyCases := []struct {
 		input    string
@@ -65,23 +88,65 @@ func TestFromString(t *testing.T) {
 		{"::7", Target{IP: "::7"}},
 		{"user-5@::8", Target{IP: "::8", User: "user-5"}},
 		{"@::9", Target{IP: "::9"}},
+		// Ports
+		{"host-10:2222", Target{Host: "host-10", Port: 2222}},
+		{"user-11@11.11.11.11:22", Target{IP: "11.11.11.11", User: "user-11", Port: 22}},
+		{"[fe80::12]:2200", Target{IP: "fe80::12", Port: 2200}},
+		{"user-13@[::13]", Target{IP: "::13", User: "user-13"}},
+		// URIs
+		{"ssh://host-14", Target{Host: "host-14"}},
+		{"ssh://user-15@host-15:2222/", Target{Host: "host-15", User: "user-15", Port: 2222}},
+		{"ssh://user-16@[::16]:65535", Target{IP: "::16", User: "user-16", Port: 65535}},
 	}
 	sadCases := []struct {
-		input    string
-		panicMsg string
+		input string
+		err   string
 	}{
-		{"", "FromString(str string) Target got an empty string"},
-		{"@", "At least one of Target.IP and Target.Host must be set"},
-		{"user-1@", "At least one of Target.IP and Target.Host must be set"},
-		{"a@b@c", "FromString(str string) Target got a string containing more than one @ character"},
+		{"", "Invalid target \"\": empty string"},
+		{"@", "Invalid target \"@\": at least one of Target.IP and Target.Host must be set"},
+		{"user-1@", "Invalid target \"user-1@\": at least one of Target.IP and Target.Host must be set"},
+		{"a@b@c", "Invalid target \"a@b@c\": more than one @ character"},
+		{"host:", "Invalid target \"host:\": invalid port \"\""},
+		{"host:ssh", "Invalid target \"host:ssh\": invalid port \"ssh\""},
+		{"host:65536", "Invalid target \"host:65536\": invalid port \"65536\""},
+		{"[::1", "Invalid target \"[::1\": missing ] after IPv6 address"},
+		{"[::1]2222", "Invalid target \"[::1]2222\": expected :port after ], got 2222"},
+		{"ssh://:22", "Invalid target \"ssh://:22\": at least one of Target.IP and Target.Host must be set"},
 	}
 	for _, happy := range happyCases {
-		actual := FromString(happy.input)
+		actual, err := FromString(happy.input)
+		util.ExpectNoError(t, err)
 		if !reflect.DeepEqual(actual, happy.expected) {
 			t.Errorf("Actual: %s. Expected: %s.", actual, happy.expected)
 		}
 	}
 	for _, sad := range sadCases {
-		util.ExpectPanic(t, sad.panicMsg, func() { FromString(sad.input) })
+		_, err := FromString(sad.input)
+		util.ExpectError(t, sad.err, err)
+		if util.ExitCode(err) != util.ExitCodeInvalidTarget {
+			t.Errorf("Exit code for %s is %d", err, util.ExitCode(err))
+		}
+	}
+}
+
+func TestFromStrings(t *testing.T) {
+	targets, err := FromStrings("a", "root@b")
+	util.ExpectNoError(t, err)
+	AssertTargetListEquals(t, []Target{{Host: "a"}, {Host: "b", User: "root"}}, targets)
+
+	_, err = FromStrings("a", "")
+	util.ExpectError(t, "Invalid target \"\": empty string", err)
+}
+
+func TestLabels(t *testing.T) {
+	var target Target
+	target.SetLabel("empty", "")
+	if target.Labels != nil {
+		t.Errorf("Empty label was stored: %v", target.Labels)
+	}
+	target.SetLabel("b", "2")
+	target.SetLabel("a", "1")
+	if actual := FormatLabels(target.Labels); actual != "a=1 b=2" {
+		t.Errorf("Unexpected formatted labels: %s", actual)
 	}
 }
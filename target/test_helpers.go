@@ -17,3 +17,19 @@ func AssertTargetListEquals(t mock.TestingT, expected []Target, actual []Target)
 		}
 	}
 }
+
+/*
+MustFromStrings is FromStrings for tests: it panics on invalid input
+*/
+func MustFromStrings(targetStrings ...string) []Target {
+	targets, err := FromStrings(targetStrings...)
+	if err != nil {
+		panic(err)
+	}
+	return targets
+}
+
+// MustFromString is FromString for tests: it panics on invalid input
+func MustFromString(str string) Target {
+	return MustFromStrings(str)[0]
+}
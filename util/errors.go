@@ -0,0 +1,230 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Exit codes of easyssh for each class of errors
+const (
+	ExitCodeGeneric       = 1
+	ExitCodeParse         = 2
+	ExitCodeConfig        = 3
+	ExitCodeMissingBinary = 4
+	ExitCodeInvalidTarget = 5
+	ExitCodeNoTargets     = 6
+	ExitCodeCommand       = 7
+	ExitCodeInvalidOutput = 8
+	ExitCodeUsage         = 9
+)
+
+/*
+ExitCode returns the exit code for err: the result of err.ExitCode() if err has such a method, ExitCodeGeneric
+otherwise.
+*/
+func ExitCode(err error) int {
+	if e, ok := err.(interface {
+		ExitCode() int
+	}); ok {
+		return e.ExitCode()
+	}
+	return ExitCodeGeneric
+}
+
+/*
+ParseError is returned for invalid discoverer, filter and executor definitions.
+Expr and Pos point at the offending sub-expression; Pos is -1 if the position is not known.
+*/
+type ParseError struct {
+	Msg        string
+	Expr       string
+	Pos        int
+	Suggestion string
+}
+
+// ParseErrorf creates a ParseError that is not yet associated with an expression
+func ParseErrorf(msg string, args ...interface{}) *ParseError {
+	return &ParseError{Msg: fmt.Sprintf(msg, args...), Pos: -1}
+}
+
+func (e *ParseError) Error() string {
+	msg := e.Msg
+	if e.Expr != "" {
+		msg += " in " + e.Expr
+		if e.Pos >= 0 {
+			msg += fmt.Sprintf(" at position %d", e.Pos)
+		}
+	}
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf("; did you mean %s?", e.Suggestion)
+	}
+	return msg
+}
+
+func (e *ParseError) ExitCode() int {
+	return ExitCodeParse
+}
+
+// ConfigError is returned for invalid or unreadable config files
+type ConfigError struct {
+	Msg string
+}
+
+func ConfigErrorf(msg string, args ...interface{}) *ConfigError {
+	return &ConfigError{Msg: fmt.Sprintf(msg, args...)}
+}
+
+func (e *ConfigError) Error() string {
+	return e.Msg
+}
+
+func (e *ConfigError) ExitCode() int {
+	return ExitCodeConfig
+}
+
+// MissingBinaryError is returned when an external command required by a component is not on PATH
+type MissingBinaryError struct {
+	Binary     string
+	RequiredBy string
+}
+
+func (e *MissingBinaryError) Error() string {
+	if e.RequiredBy == "" {
+		return fmt.Sprintf("%s is not found on PATH", e.Binary)
+	}
+	return fmt.Sprintf("%s is not found on PATH, but is required by %s", e.Binary, e.RequiredBy)
+}
+
+func (e *MissingBinaryError) ExitCode() int {
+	return ExitCodeMissingBinary
+}
+
+// InvalidTargetError is returned when a string can't be turned into a target
+type InvalidTargetError struct {
+	Input string
+	Msg   string
+}
+
+func (e *InvalidTargetError) Error() string {
+	return fmt.Sprintf("Invalid target \"%s\": %s", e.Input, e.Msg)
+}
+
+func (e *InvalidTargetError) ExitCode() int {
+	return ExitCodeInvalidTarget
+}
+
+// NoTargetsError is returned when there are no targets left to execute on
+type NoTargetsError struct {
+	Input string
+}
+
+func (e *NoTargetsError) Error() string {
+	return fmt.Sprintf("No targets found for %s", e.Input)
+}
+
+func (e *NoTargetsError) ExitCode() int {
+	return ExitCodeNoTargets
+}
+
+// CommandError is returned when an external command fails
+type CommandError struct {
+	Argv   []string
+	Err    error
+	Output []byte
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%s failed: %s", e.Argv, e.Err)
+	if len(e.Output) > 0 {
+		msg += "\nOutput:\n" + string(e.Output)
+	}
+	return msg
+}
+
+func (e *CommandError) ExitCode() int {
+	return ExitCodeCommand
+}
+
+// InvalidOutputError is returned when the output of an external command can't be parsed
+type InvalidOutputError struct {
+	Source string
+	Err    error
+	Output []byte
+}
+
+func (e *InvalidOutputError) Error() string {
+	return fmt.Sprintf("Failed to parse output of %s: %s", e.Source, e.Err)
+}
+
+func (e *InvalidOutputError) ExitCode() int {
+	return ExitCodeInvalidOutput
+}
+
+// UsageError is returned when easyssh is invoked incorrectly, for example without a command where one is required
+type UsageError struct {
+	Msg string
+}
+
+func UsageErrorf(msg string, args ...interface{}) *UsageError {
+	return &UsageError{Msg: fmt.Sprintf(msg, args...)}
+}
+
+func (e *UsageError) Error() string {
+	return e.Msg
+}
+
+func (e *UsageError) ExitCode() int {
+	return ExitCodeUsage
+}
+
+/*
+Suggest returns the candidate closest to name, if it's close enough to be a likely typo. Returns "" otherwise.
+*/
+func Suggest(name string, candidates []string) string {
+	sorted := append([]string{}, candidates...)
+	sort.Strings(sorted)
+	maxDistance := len(name) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	best, bestDistance := "", maxDistance+1
+	for _, candidate := range sorted {
+		if d := levenshtein(strings.ToLower(name), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+	return m
+}
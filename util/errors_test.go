@@ -0,0 +1,74 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseErrorMessage(t *testing.T) {
+	cases := []struct {
+		err      *ParseError
+		expected string
+	}{
+		{ParseErrorf("Unknown %s", "x"), "Unknown x"},
+		{&ParseError{Msg: "Unknown x", Expr: "(x)", Pos: -1}, "Unknown x in (x)"},
+		{&ParseError{Msg: "Unknown x", Expr: "(x)", Pos: 3}, "Unknown x in (x) at position 3"},
+		{&ParseError{Msg: "Unknown x", Expr: "(x)", Pos: 0, Suggestion: "y"}, "Unknown x in (x) at position 0; did you mean y?"},
+	}
+	for _, c := range cases {
+		if c.err.Error() != c.expected {
+			t.Errorf("Expected %q, got %q", c.expected, c.err.Error())
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected int
+	}{
+		{errors.New("foo"), ExitCodeGeneric},
+		{ParseErrorf("foo"), ExitCodeParse},
+		{ConfigErrorf("foo"), ExitCodeConfig},
+		{&MissingBinaryError{Binary: "foo"}, ExitCodeMissingBinary},
+		{&InvalidTargetError{Input: "foo"}, ExitCodeInvalidTarget},
+		{&NoTargetsError{Input: "foo"}, ExitCodeNoTargets},
+		{&CommandError{Argv: []string{"foo"}, Err: errors.New("bar")}, ExitCodeCommand},
+		{&InvalidOutputError{Source: "foo", Err: errors.New("bar")}, ExitCodeInvalidOutput},
+		{UsageErrorf("foo"), ExitCodeUsage},
+	}
+	for _, c := range cases {
+		if actual := ExitCode(c.err); actual != c.expected {
+			t.Errorf("Exit code of %#v is %d, expected %d", c.err, actual, c.expected)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"knife", "first-matching", "fixed", "separated-by", "comma-separated"}
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"knif", "knife"},
+		{"Knife", "knife"},
+		{"frist-matching", "first-matching"},
+		{"fixd", "fixed"},
+		{"comma-seperated", "comma-separated"},
+		{"foobar", ""},
+		{"x", ""},
+	}
+	for _, c := range cases {
+		if actual := Suggest(c.name, candidates); actual != c.expected {
+			t.Errorf("Suggest(%q) returned %q, expected %q", c.name, actual, c.expected)
+		}
+	}
+}
+
+func TestCommandErrorIncludesOutput(t *testing.T) {
+	err := &CommandError{Argv: []string{"knife", "search"}, Err: errors.New("exit status 1"), Output: []byte("ERROR: oops")}
+	expected := "[knife search] failed: exit status 1\nOutput:\nERROR: oops"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
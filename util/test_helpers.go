@@ -23,33 +23,65 @@ func ExpectPanic(t mock.TestingT, expectedErr interface{}, f func()) {
 	f()
 }
 
+/*
+ExpectError fails the test if err is nil, or if expectedErr is not empty and err.Error() is different.
+*/
+func ExpectError(t mock.TestingT, expectedErr string, err error) {
+	if err == nil {
+		t.Errorf("Expected error \"%s\", got no error", expectedErr)
+		return
+	}
+	if expectedErr != "" && err.Error() != expectedErr {
+		t.Errorf("Expected error \"%s\", got \"%s\" instead", expectedErr, err.Error())
+	}
+}
+
+// ExpectNoError fails the test if err is not nil
+func ExpectNoError(t mock.TestingT, err error) {
+	if err != nil {
+		t.Errorf("Expected no error, got \"%s\"", err.Error())
+	}
+}
+
 type MockCommandRunner struct {
 	mock.Mock
 }
 
-func (r *MockCommandRunner) CombinedOutputWithStdinOrPanic(stdin io.Reader, name string, args []string) []byte {
+func (r *MockCommandRunner) CombinedOutputWithStdin(stdin io.Reader, name string, args []string) ([]byte, error) {
 	ret := r.Called(stdin, name, args)
-	return ret.Get(0).([]byte)
+	return ret.Get(0).([]byte), ret.Error(1)
 }
-func (r *MockCommandRunner) CombinedOutputOrPanic(name string, args []string) []byte {
+func (r *MockCommandRunner) CombinedOutput(name string, args []string) ([]byte, error) {
 	ret := r.Called(name, args)
-	return ret.Get(0).([]byte)
+	return ret.Get(0).([]byte), ret.Error(1)
 }
 func (r *MockCommandRunner) Outputs(name string, args []string) CommandRunnerOutputs {
 	ret := r.Called(name, args)
 	return ret.Get(0).(CommandRunnerOutputs)
 }
+func (r *MockCommandRunner) OutputsWithStdin(stdin io.Reader, name string, args []string) CommandRunnerOutputs {
+	ret := r.Called(stdin, name, args)
+	return ret.Get(0).(CommandRunnerOutputs)
+}
 
 type MockInteractiveCommandRunner struct {
 	mock.Mock
 }
 
-func (r *MockInteractiveCommandRunner) Run(job InteractiveCommandRunnerJob) {
-	r.Called(job)
+func (r *MockInteractiveCommandRunner) Run(job InteractiveCommandRunnerJob) error {
+	return mockError(r.Called(job))
+}
+
+func (r *MockInteractiveCommandRunner) RunParallel(jobs []InteractiveCommandRunnerJob) error {
+	return mockError(r.Called(jobs))
 }
 
-func (r *MockInteractiveCommandRunner) RunParallel(jobs []InteractiveCommandRunnerJob) {
-	r.Called(jobs)
+// mockError returns the first return value set up for the call as an error, or nil if none was set up
+func mockError(ret mock.Arguments) error {
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret.Error(0)
 }
 
 type MockLogger struct {
@@ -164,6 +196,12 @@ func (m *MockLogger) ExpectDebugf(format string, args ...interface{}) *mock.Call
 func (m *MockLogger) ExpectInfof(format string, args ...interface{}) *mock.Call {
 	return m.On("Infof", append([]interface{}{format}, args...)...).Times(1)
 }
+func (m *MockLogger) ExpectWarningf(format string, args ...interface{}) *mock.Call {
+	return m.On("Warningf", append([]interface{}{format}, args...)...).Times(1)
+}
+func (m *MockLogger) ExpectErrorf(format string, args ...interface{}) *mock.Call {
+	return m.On("Errorf", append([]interface{}{format}, args...)...).Times(1)
+}
 
 type DummyError struct {
 	Msg string
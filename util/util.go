@@ -1,6 +1,7 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -10,6 +11,8 @@ import (
 	"bytes"
 
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/alexcesaro/log"
 	"github.com/alexcesaro/log/golog"
@@ -19,67 +22,137 @@ func Panicf(msg string, args ...interface{}) {
 	panic(fmt.Sprintf(msg, args...))
 }
 
-func LookPathOrAbort(binaryName string) string {
+func LookPath(binaryName string) (string, error) {
 	var binary, lookErr = exec.LookPath(binaryName)
 	if lookErr != nil {
-		Panicf(lookErr.Error())
+		return "", &MissingBinaryError{Binary: binaryName}
 	}
-	return binary
+	return binary, nil
 }
 
-func RequireOnPath(requiredBy interface{}, binaryName string) {
+func RequireOnPath(requiredBy interface{}, binaryName string) error {
 	_, lookErr := exec.LookPath(binaryName)
 	if lookErr != nil {
-		Panicf("%s is not found on PATH, but is required by %s", binaryName, requiredBy)
+		return &MissingBinaryError{Binary: binaryName, RequiredBy: fmt.Sprint(requiredBy)}
 	}
+	return nil
 }
 
-func RequireNoArguments(e interface{}, args []interface{}) {
+func RequireNoArguments(e interface{}, args []interface{}) error {
 	if len(args) > 0 {
-		Panicf("%s doesn't take any arguments, got %d: %s", e, len(args), args)
+		return ParseErrorf("%s doesn't take any arguments, got %d: %s", e, len(args), args)
 	}
+	return nil
 }
 
-func RequireArguments(e interface{}, n int, args []interface{}) {
+func RequireArguments(e interface{}, n int, args []interface{}) error {
 	if len(args) != n {
-		Panicf("%s requires exactly %d argument(s), got %d: %s", e, n, len(args), args)
+		return ParseErrorf("%s requires exactly %d argument(s), got %d: %s", e, n, len(args), args)
 	}
+	return nil
 }
 
-func RequireArgumentsAtLeast(e interface{}, n int, args []interface{}) {
+func RequireArgumentsAtLeast(e interface{}, n int, args []interface{}) error {
 	if len(args) < n {
-		Panicf("%s requires at least %d argument(s), got %d: %s", e, n, len(args), args)
+		return ParseErrorf("%s requires at least %d argument(s), got %d: %s", e, n, len(args), args)
 	}
+	return nil
+}
+
+func RequireArgumentsAtMost(e interface{}, n int, args []interface{}) error {
+	if len(args) > n {
+		return ParseErrorf("%s takes at most %d argument(s), got %d: %s", e, n, len(args), args)
+	}
+	return nil
 }
 
 var Logger log.Logger = golog.New(os.Stdout, log.Info)
 
 type CommandRunner interface {
-	CombinedOutputWithStdinOrPanic(stdin io.Reader, name string, args []string) []byte
-	CombinedOutputOrPanic(name string, args []string) []byte
+	CombinedOutputWithStdin(stdin io.Reader, name string, args []string) ([]byte, error)
+	CombinedOutput(name string, args []string) ([]byte, error)
 	Outputs(name string, args []string) CommandRunnerOutputs
+	OutputsWithStdin(stdin io.Reader, name string, args []string) CommandRunnerOutputs
 }
 
-type RealCommandRunner struct{}
+/*
+RealCommandRunner runs commands with os/exec. If Context is set, the commands still running when it's done are killed.
+*/
+type RealCommandRunner struct {
+	Context context.Context
+}
+
+func (c RealCommandRunner) command(name string, args []string) *exec.Cmd {
+	if c.Context == nil {
+		return exec.Command(name, args...)
+	}
+	return exec.CommandContext(c.Context, name, args...)
+}
 
-func combinedOutputOrPanic(cmd *exec.Cmd) []byte {
+func combinedOutput(cmd *exec.Cmd) ([]byte, error) {
 	Logger.Debugf("Executing, bailing out if exits with non-zero: %s", cmd.Args)
 	output, err := cmd.CombinedOutput()
 	if err == nil {
-		return output
+		return output, nil
 	}
-	panic(err.Error() + "\n" + string(output))
+	return output, &CommandError{Argv: cmd.Args, Err: err, Output: output}
 }
 
-func (c RealCommandRunner) CombinedOutputWithStdinOrPanic(stdin io.Reader, name string, args []string) []byte {
-	cmd := exec.Command(name, args...)
+func (c RealCommandRunner) CombinedOutputWithStdin(stdin io.Reader, name string, args []string) ([]byte, error) {
+	cmd := c.command(name, args)
 	cmd.Stdin = stdin
 	cmd.Env = os.Environ()
-	return combinedOutputOrPanic(cmd)
+	return combinedOutput(cmd)
 }
 
-func (c RealCommandRunner) CombinedOutputOrPanic(name string, args []string) []byte {
-	return combinedOutputOrPanic(exec.Command(name, args...))
+func (c RealCommandRunner) CombinedOutput(name string, args []string) ([]byte, error) {
+	return combinedOutput(c.command(name, args))
+}
+
+/*
+CancelableCommandRunner runs commands like RealCommandRunner, killing the ones still running when the context last
+passed to SetContext is done. It's safe to use from multiple goroutines.
+*/
+type CancelableCommandRunner struct {
+	mutex sync.Mutex
+	ctx   context.Context
+}
+
+// SetContext makes the commands started from now on killed when ctx is done
+func (c *CancelableCommandRunner) SetContext(ctx context.Context) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.ctx = ctx
+}
+
+// Context returns the context last passed to SetContext, or context.Background() if there was none
+func (c *CancelableCommandRunner) Context() context.Context {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+func (c *CancelableCommandRunner) runner() RealCommandRunner {
+	return RealCommandRunner{Context: c.Context()}
+}
+
+func (c *CancelableCommandRunner) CombinedOutputWithStdin(stdin io.Reader, name string, args []string) ([]byte, error) {
+	return c.runner().CombinedOutputWithStdin(stdin, name, args)
+}
+
+func (c *CancelableCommandRunner) CombinedOutput(name string, args []string) ([]byte, error) {
+	return c.runner().CombinedOutput(name, args)
+}
+
+func (c *CancelableCommandRunner) Outputs(name string, args []string) CommandRunnerOutputs {
+	return c.runner().Outputs(name, args)
+}
+
+func (c *CancelableCommandRunner) OutputsWithStdin(stdin io.Reader, name string, args []string) CommandRunnerOutputs {
+	return c.runner().OutputsWithStdin(stdin, name, args)
 }
 
 type CommandRunnerOutputs struct {
@@ -106,6 +179,10 @@ func multicast(c chan int, input io.Reader, outputs ...io.Writer) {
 }
 
 func (c RealCommandRunner) Outputs(name string, args []string) CommandRunnerOutputs {
+	return c.OutputsWithStdin(nil, name, args)
+}
+
+func (c RealCommandRunner) OutputsWithStdin(stdin io.Reader, name string, args []string) CommandRunnerOutputs {
 	var (
 		err            error
 		stderrPipe     io.ReadCloser
@@ -115,12 +192,15 @@ func (c RealCommandRunner) Outputs(name string, args []string) CommandRunnerOutp
 		stdoutPipe     io.ReadCloser
 		outputs        CommandRunnerOutputs
 	)
-	cmd := exec.Command(name, args...)
+	cmd := c.command(name, args)
+	cmd.Stdin = stdin
 	if stderrPipe, err = cmd.StderrPipe(); err != nil {
-		Panicf(err.Error())
+		outputs.Error = err
+		return outputs
 	}
 	if stdoutPipe, err = cmd.StdoutPipe(); err != nil {
-		Panicf(err.Error())
+		outputs.Error = err
+		return outputs
 	}
 
 	stdoutChannel := make(chan int)
@@ -151,9 +231,9 @@ func (c RealCommandRunner) Outputs(name string, args []string) CommandRunnerOutp
 }
 
 type InteractiveCommandRunnerJob struct {
-	Interactive bool
-	Label       string
-	Argv        []string
+	Interactive bool     `json:"interactive"`
+	Label       string   `json:"label"`
+	Argv        []string `json:"argv"`
 }
 
 func (job InteractiveCommandRunnerJob) Command() *exec.Cmd {
@@ -172,36 +252,68 @@ func (job InteractiveCommandRunnerJob) Command() *exec.Cmd {
 }
 
 type InteractiveCommandRunner interface {
-	Run(job InteractiveCommandRunnerJob)
-	RunParallel(jobs []InteractiveCommandRunnerJob)
+	Run(job InteractiveCommandRunnerJob) error
+	RunParallel(jobs []InteractiveCommandRunnerJob) error
 }
 
 type RealInteractiveCommandRunner struct{}
 
-func (e RealInteractiveCommandRunner) Run(job InteractiveCommandRunnerJob) {
-	job.Argv[0] = LookPathOrAbort(job.Argv[0])
+func (e RealInteractiveCommandRunner) Run(job InteractiveCommandRunnerJob) error {
+	binary, err := LookPath(job.Argv[0])
+	if err != nil {
+		return err
+	}
+	job.Argv[0] = binary
 	Logger.Infof("Executing %s", job.Argv)
 	cmd := job.Command()
-	err := cmd.Run()
-	if err != nil {
-		Panicf("%s failed: %s", cmd.Args, err)
+	if err := cmd.Run(); err != nil {
+		return &CommandError{Argv: cmd.Args, Err: err}
 	}
+	return nil
 }
 
-func (e RealInteractiveCommandRunner) RunParallel(jobs []InteractiveCommandRunnerJob) {
+/*
+RunParallel starts all jobs, and waits for all of them to finish. Failures are logged as they happen; if any job
+failed, a CommandError describing the first failure is returned.
+*/
+func (e RealInteractiveCommandRunner) RunParallel(jobs []InteractiveCommandRunnerJob) error {
 	cmds := make([]*exec.Cmd, len(jobs))
 	for i, job := range jobs {
-		job.Argv[0] = LookPathOrAbort(job.Argv[0])
+		binary, err := LookPath(job.Argv[0])
+		if err != nil {
+			return err
+		}
+		job.Argv[0] = binary
 		cmds[i] = job.Command()
-		Logger.Debugf("Executing %s", cmds[i].Args)
-		cmds[i].Start()
 	}
+	var firstErr error
+	failed := 0
 	for _, cmd := range cmds {
-		err := cmd.Wait()
-		if err != nil {
+		Logger.Debugf("Executing %s", cmd.Args)
+		if err := cmd.Start(); err != nil {
 			Logger.Errorf("%s: %s", cmd.Args, err)
+			failed++
+			if firstErr == nil {
+				firstErr = &CommandError{Argv: cmd.Args, Err: err}
+			}
+		}
+	}
+	for _, cmd := range cmds {
+		if cmd.Process == nil {
+			continue
 		}
+		if err := cmd.Wait(); err != nil {
+			Logger.Errorf("%s: %s", cmd.Args, err)
+			failed++
+			if firstErr == nil {
+				firstErr = &CommandError{Argv: cmd.Args, Err: err}
+			}
+		}
+	}
+	if failed > 1 {
+		Logger.Errorf("%d of %d parallel jobs failed", failed, len(jobs))
 	}
+	return firstErr
 }
 
 func makeCommandLogged(prefix string, cmd *exec.Cmd) {
@@ -227,10 +339,45 @@ func (w prefixedLogWriterProxy) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-func ByteToStringArray(input []interface{}) []string {
+func ByteToStringArray(input []interface{}) ([]string, error) {
 	output := make([]string, len(input))
 	for i, item := range input {
-		output[i] = string(item.([]byte))
+		str, err := StringArg(item)
+		if err != nil {
+			return nil, err
+		}
+		output[i] = str
+	}
+	return output, nil
+}
+
+// StringArg returns the string in an S-Expression atom, or a ParseError if arg is a list
+func StringArg(arg interface{}) (string, error) {
+	bytes, ok := arg.([]byte)
+	if !ok {
+		return "", ParseErrorf("Expected a string, got a list: %s", arg)
+	}
+	return string(bytes), nil
+}
+
+// DurationArg parses an S-Expression atom like 10m or 1h30m into a positive duration, or returns a ParseError
+func DurationArg(arg interface{}) (time.Duration, error) {
+	str, err := StringArg(arg)
+	if err != nil {
+		return 0, err
+	}
+	duration, err := time.ParseDuration(str)
+	if err != nil || duration <= 0 {
+		return 0, ParseErrorf("Expected a positive duration like 10m or 1h30m, got %s", str)
+	}
+	return duration, nil
+}
+
+// ListArg returns the items of an S-Expression list, or a ParseError if arg is an atom
+func ListArg(arg interface{}) ([]interface{}, error) {
+	list, ok := arg.([]interface{})
+	if !ok {
+		return nil, ParseErrorf("Expected a definition, got a string: %s", arg)
 	}
-	return output
+	return list, nil
 }